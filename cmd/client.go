@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/discord"
+	"github.com/erindatkinson/emoji-archiver/internal/mattermost"
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
+	"github.com/erindatkinson/emoji-archiver/internal/rocketchat"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+	"github.com/erindatkinson/emoji-archiver/internal/zulip"
+)
+
+var apiURL, workspaceURL, proxyURL, userAgent string
+var httpTimeout time.Duration
+var rateLimits map[string]int
+var retries int
+var platformName, mattermostURL, mattermostToken string
+var discordGuild, discordToken string
+var rocketchatURL, rocketchatUserID, rocketchatToken string
+var zulipURL, zulipEmail, zulipAPIKey string
+
+/*
+newBackend
+
+Creates a client for the emoji platform. On slack name is the subdomain,
+elsewhere it only names the export directory.
+*/
+func newBackend(ctx context.Context, platformName, name, browser, profile string) (platform.Backend, error) {
+	if err := platform.Validate(platformName); err != nil {
+		return nil, err
+	}
+	switch platformName {
+	case platform.Mattermost:
+		client, err := newMattermostClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	case platform.Discord:
+		client, err := newDiscordClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	case platform.RocketChat:
+		client, err := newRocketChatClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	case platform.Zulip:
+		client, err := newZulipClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+	client, err := newSlackClient(ctx, name, browser, profile)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// newSlackClient creates a client for the subdomain using the configured credential source and http settings
+func newSlackClient(ctx context.Context, subdomain, browser, profile string) (*slack.Client, error) {
+	if subdomain == "" {
+		return nil, errMissingConfig
+	}
+	provider, err := credentialProvider(browser, profile)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := slackClientOptions()
+	if err != nil {
+		return nil, err
+	}
+	return slack.NewSlackClient(ctx, subdomain, provider, opts...)
+}
+
+func slackClientOptions() ([]slack.ClientOption, error) {
+	client, err := newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []slack.ClientOption{
+		slack.WithHTTPClient(client),
+	}
+	if apiURL != "" {
+		opts = append(opts, slack.WithAPIURL(apiURL))
+	}
+	if workspaceURL != "" {
+		opts = append(opts, slack.WithWorkspaceURL(workspaceURL))
+	}
+	if userAgent != "" {
+		opts = append(opts, slack.WithUserAgent(userAgent))
+	}
+	for method, perMinute := range rateLimits {
+		opts = append(opts, slack.WithRateLimit(method, perMinute))
+	}
+	if retries >= 0 {
+		opts = append(opts, slack.WithRetries(retries))
+	}
+	return opts, nil
+}
+
+// backendOptions sets up the clients of platforms other than slack from the http flags
+func backendOptions() ([]platform.Option, error) {
+	client, err := newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	opts := []platform.Option{
+		platform.WithHTTPClient(client),
+	}
+	if userAgent != "" {
+		opts = append(opts, platform.WithUserAgent(userAgent))
+	}
+	if retries >= 0 {
+		opts = append(opts, platform.WithRetries(retries))
+	}
+	return opts, nil
+}
+
+// newMattermostClient creates a client for the configured mattermost server and access token
+func newMattermostClient(ctx context.Context) (*mattermost.Client, error) {
+	opts, err := backendOptions()
+	if err != nil {
+		return nil, err
+	}
+	return mattermost.NewClient(ctx, mattermostURL, mattermostToken, opts...)
+}
+
+// newDiscordClient creates a client for the configured discord guild and bot token
+func newDiscordClient(ctx context.Context) (*discord.Client, error) {
+	opts, err := backendOptions()
+	if err != nil {
+		return nil, err
+	}
+	return discord.NewClient(ctx, discordGuild, discordToken, opts...)
+}
+
+// newRocketChatClient creates a client for the configured rocket.chat server and access token
+func newRocketChatClient(ctx context.Context) (*rocketchat.Client, error) {
+	opts, err := backendOptions()
+	if err != nil {
+		return nil, err
+	}
+	return rocketchat.NewClient(ctx, rocketchatURL, rocketchatUserID, rocketchatToken, opts...)
+}
+
+// newZulipClient creates a client for the configured zulip organization and api key
+func newZulipClient(ctx context.Context) (*zulip.Client, error) {
+	opts, err := backendOptions()
+	if err != nil {
+		return nil, err
+	}
+	return zulip.NewClient(ctx, zulipURL, zulipEmail, zulipAPIKey, opts...)
+}
+
+// defaultExportName names exports from platforms without subdomains after the server's host or guild
+func defaultExportName(platformName, name string) string {
+	if name != "" {
+		return name
+	}
+	serverURL := ""
+	switch platformName {
+	case platform.Mattermost:
+		serverURL = mattermostURL
+	case platform.RocketChat:
+		serverURL = rocketchatURL
+	case platform.Zulip:
+		serverURL = zulipURL
+	case platform.Discord:
+		return discordGuild
+	}
+	if server, err := url.Parse(serverURL); err == nil {
+		return server.Hostname()
+	}
+	return ""
+}
+
+// newHTTPClient returns an http client using the configured proxy and timeout
+func newHTTPClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxyURL != "" {
+		proxy, err := url.Parse(proxyURL)
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("invalid proxy url"), err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	return &http.Client{Timeout: httpTimeout, Transport: transport}, nil
+}
+
+// configDuration parses a duration from the config or env, falling back to the default if unset or invalid
+func configDuration(value string, fallback time.Duration) time.Duration {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return fallback
+	}
+	return duration
+}
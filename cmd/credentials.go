@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+)
+
+const (
+	credentialsBrowser = "browser"
+	credentialsToken   = "token"
+	credentialsFile    = "file"
+	credentialsKeyring = "keyring"
+)
+
+var credentialSource, xoxd, xoxc, tokenFile string
+
+// credentialProvider builds the provider picked with --credentials, browser and
+// profile are passed in so sync can look up each team's cookie separately
+func credentialProvider(browser, profile string) (slack.CredentialProvider, error) {
+	switch credentialSource {
+	case credentialsBrowser, "":
+		return slack.BrowserCredentials{Browser: browser, Profile: profile}, nil
+	case credentialsToken:
+		return slack.StaticCredentials{XOXD: xoxd, XOXC: xoxc}, nil
+	case credentialsFile:
+		if tokenFile == "" {
+			return nil, fmt.Errorf("--token-file is required for file credentials")
+		}
+		return slack.FileCredentials{Path: tokenFile}, nil
+	case credentialsKeyring:
+		return slack.KeyringCredentials{}, nil
+	default:
+		return nil, fmt.Errorf("unknown credential source %q, expected one of browser, token, file, keyring", credentialSource)
+	}
+}
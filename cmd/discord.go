@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/cache"
+	"github.com/erindatkinson/emoji-archiver/internal/discord"
+	"github.com/erindatkinson/emoji-archiver/internal/images"
+	"github.com/erindatkinson/emoji-archiver/internal/journal"
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/samber/lo"
+)
+
+// importedName is what an emoji from the export will be called once imported into the platform
+func importedName(platformName, name string) string {
+	if platformName == platform.Discord {
+		return discord.EmojiName(name)
+	}
+	return name
+}
+
+/*
+planDiscordImport
+
+Discord caps how many static and, separately, animated emoji a guild can
+hold, so rather than uploading until the guild fills up this works out
+up front which emoji fit, taking them in --priority order. Discord has no
+aliases so each alias becomes a copy of its target's image, and every gif
+is counted as animated since that's how discord files them. Images over
+the size limit are shrunk first with --fix. Emoji that don't fit are
+recorded as skipped.
+*/
+func planDiscordImport(ctx context.Context, logger *slog.Logger, client *discord.Client, importDir string, manifest *cache.Manifest, files []os.DirEntry, aliases []cache.ManifestEntry, results *emojiResults) ([]discord.Placement, error) {
+	free, err := client.FreeSlots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if importStaticSlots >= 0 {
+		free.Static = importStaticSlots
+	}
+	if importAnimatedSlots >= 0 {
+		free.Animated = importAnimatedSlots
+	}
+	logger.Info("free discord slots", "static", free.Static, "animated", free.Animated)
+
+	candidates := make([]discord.Candidate, 0, len(files)+len(aliases))
+	stagingDir := filepath.Join(importStagingDir, subdomain)
+	for _, file := range files {
+		name := strings.Split(file.Name(), ".")[0]
+		candidate, err := discordCandidate(manifest, name, filepath.Join(importDir, file.Name()), stagingDir)
+		if err != nil {
+			logger.Error("unable to read image", "error", err, "file", file.Name())
+			results.add(name, resultFailed, err.Error())
+			continue
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	for _, alias := range aliases {
+		target, ok := lo.Find(files, func(file os.DirEntry) bool {
+			return strings.Split(file.Name(), ".")[0] == alias.AliasFor
+		})
+		if !ok {
+			results.add(alias.Name, resultSkipped, fmt.Sprintf("alias target :%s: isn't in the export", alias.AliasFor))
+			continue
+		}
+		candidate, err := discordCandidate(manifest, alias.AliasFor, filepath.Join(importDir, target.Name()), stagingDir)
+		if err != nil {
+			logger.Error("unable to read alias target", "error", err, "emoji", alias.Name, "target", alias.AliasFor)
+			results.add(alias.Name, resultFailed, err.Error())
+			continue
+		}
+		candidate.Name = discord.EmojiName(alias.Name)
+		candidate.Source = alias.Name
+		candidate.AliasFor = alias.AliasFor
+		candidate.Created = alias.Created
+		candidates = append(candidates, candidate)
+	}
+
+	placements, err := discord.Plan(candidates, free, importPriority)
+	if err != nil {
+		return nil, err
+	}
+	for _, placement := range placements {
+		if !placement.Fits {
+			results.add(placement.Source, resultSkipped, placement.Reason)
+		}
+	}
+	return placements, nil
+}
+
+// discordCandidate describes an image from the export, taking its upload time from the manifest when it's there
+func discordCandidate(manifest *cache.Manifest, name, fPath, stagingDir string) (discord.Candidate, error) {
+	report, err := images.ValidateFile(fPath, platform.ImageLimits(platform.Discord))
+	if err != nil {
+		return discord.Candidate{}, err
+	}
+	if report.Format == "" {
+		return discord.Candidate{}, errors.New(violationSummary(report))
+	}
+	if importFix && report.Size > discord.MaxFileSize && report.Fixable() {
+		fixed, err := images.FixFile(report, stagingDir, platform.ImageLimits(platform.Discord))
+		if err != nil {
+			return discord.Candidate{}, fmt.Errorf("unable to fix: %w", err)
+		}
+		fPath = fixed
+		if info, err := os.Stat(fixed); err == nil {
+			report.Size = info.Size()
+		}
+	}
+
+	candidate := discord.Candidate{
+		Name:     discord.EmojiName(name),
+		Source:   name,
+		Path:     fPath,
+		Size:     report.Size,
+		Animated: report.Format == "gif",
+	}
+	if entry, ok := manifest.Get(name); ok && entry.Created > 0 {
+		candidate.Created = entry.Created
+	} else if info, err := os.Stat(fPath); err == nil {
+		candidate.Created = info.ModTime().Unix()
+	}
+	return candidate, nil
+}
+
+// discordImportTasks uploads every placement that fits, journaled under the emoji's name in the export
+func discordImportTasks(client *discord.Client, placements []discord.Placement, hashes map[string]string) []importTask {
+	fits := lo.Filter(placements, func(placement discord.Placement, index int) bool {
+		return placement.Fits
+	})
+	return lo.Map(fits, func(placement discord.Placement, index int) importTask {
+		detail := ""
+		if placement.AliasFor != "" {
+			detail = "copy of " + placement.AliasFor
+		}
+		if placement.Name != placement.Source {
+			detail = strings.TrimPrefix(detail+", uploaded as "+placement.Name, ", ")
+		}
+		return importTask{
+			entry:  journal.Entry{Name: placement.Source, File: placement.Path, SHA256: hashes[placement.Source]},
+			detail: detail,
+			run: func(ctx context.Context) error {
+				return client.ImportEmoji(ctx, placement.Name, placement.Path)
+			},
+		}
+	})
+}
+
+func renderDiscordPlan(w io.Writer, placements []discord.Placement) {
+	t := table.NewWriter()
+	t.SetStyle(table.StyleRounded)
+	t.SetOutputMirror(w)
+	t.AppendHeader(table.Row{"Emoji", "Discord name", "Kind", "Size", "Created", "Action", "Reason"})
+	uploads := 0
+	for _, placement := range placements {
+		kind := "static"
+		if placement.Animated {
+			kind = "animated"
+		}
+		if placement.AliasFor != "" {
+			kind += ", alias for " + placement.AliasFor
+		}
+		action := "dropped"
+		if placement.Fits {
+			action = "upload"
+			uploads++
+		}
+		created := ""
+		if placement.Created > 0 {
+			created = time.Unix(placement.Created, 0).UTC().Format(time.DateOnly)
+		}
+		t.AppendRow(table.Row{
+			placement.Source, placement.Name, kind,
+			fmt.Sprintf("%dKB", (placement.Size+1023)/1024), created, action, placement.Reason,
+		})
+	}
+	t.AppendFooter(table.Row{"", "", "", "", "", "Total", fmt.Sprintf("%d uploads, %d dropped", uploads, len(placements)-uploads)})
+	t.Render()
+}
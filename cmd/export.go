@@ -4,18 +4,40 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"os"
 	"path"
-	"slices"
+	"path/filepath"
+	"time"
 
 	"github.com/erindatkinson/emoji-archiver/internal/cache"
 	"github.com/erindatkinson/emoji-archiver/internal/slack"
+	"github.com/erindatkinson/emoji-archiver/internal/storage"
 	"github.com/erindatkinson/emoji-archiver/internal/utilities"
 	"github.com/gammazero/workerpool"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 )
 
-var concurrency int
+var (
+	concurrency int
+	exportSince string
+	exportForce bool
+
+	exportMoveRemoved bool
+
+	exportFormat, exportOutput string
+
+	exportGit          bool
+	exportGitCommitPer string
+
+	exportPack                           string
+	exportPackTitle, exportPackSrc       string
+	exportPackInclude, exportPackExclude []string
+)
 
 // exportCmd represents the export command
 var exportCmd = &cobra.Command{
@@ -23,60 +45,297 @@ var exportCmd = &cobra.Command{
 	Short: "Pull all emoji from a given slack team",
 	Run: func(cmd *cobra.Command, args []string) {
 		logger := utilities.ContextLogger(cmd.Context())
-		if browser == "" || profile == "" || subdomain == "" {
+		if subdomain == "" {
 			logger.Error("error reading configs from env, config, or flags")
 			return
 		}
 
-		logger.Info("creating export directory")
-		exportDir := path.Join(directory, subdomain)
-		os.MkdirAll(exportDir, 0755)
-
-		client, err := slack.NewSlackClient(cmd.Context(), browser, profile, subdomain)
+		client, err := newBackend(cmd.Context(), platformName, subdomain, browser, profile)
 		if err != nil {
-			logger.Error("unable to create slack client", "error", err)
+			logger.Error("unable to create client", "error", err, "platform", platformName)
 			return
 		}
 		logger.Debug("client setup complete")
 		logger.Info("retrieving list of current emoji")
-		currentEmoji, err := client.ListEmoji()
+		currentEmoji, err := client.ListEmoji(cmd.Context())
 		if err != nil {
 			logger.Error("error retrieving current emoji list", "error", err)
 			return
 		}
+		if exportPack != "" {
+			logger.Info("writing emoji pack", "pack", exportPack)
+			if err := writePack(logger, currentEmoji); err != nil {
+				logger.Error("unable to write emoji pack", "error", err)
+			}
+			return
+		}
+		_, _, toS3 := storage.ParseS3URL(exportOutput)
+		toS3 = toS3 || exportFormat == storage.FormatS3
+		if exportGit && (toS3 || exportFormat != storage.FormatDir || exportOutput != "") {
+			logger.Error("--git only works when exporting into a directory")
+			return
+		}
+		if exportGitCommitPer != gitCommitPerRun && exportGitCommitPer != gitCommitPerEmoji {
+			logger.Error("--git-commit-per must be run or emoji", "git_commit_per", exportGitCommitPer)
+			return
+		}
+		if (toS3 || exportFormat != storage.FormatDir) && (exportSince != "" || exportMoveRemoved) {
+			logger.Error("--since and --move-removed only work when exporting into a directory, archives and buckets always hold every emoji")
+			return
+		}
+		if toS3 {
+			logger.Info("exporting emojis into s3")
+			failed, err := exportToS3(cmd.Context(), logger, client, currentEmoji, exportOutput)
+			if err != nil {
+				logger.Error("unable to export to s3", "error", err)
+				return
+			}
+			logger.Info("exported to s3", "failed", failed)
+			return
+		}
+		if exportFormat != storage.FormatDir {
+			output := lo.CoalesceOrEmpty(exportOutput, archivePath(subdomain, exportFormat))
+			logger.Info("exporting emojis into archive", "archive", output)
+			os.MkdirAll(filepath.Dir(output), 0755)
+			archive, err := storage.CreateArchive(output, exportFormat)
+			if err != nil {
+				logger.Error("unable to create archive", "error", err)
+				return
+			}
+			failed, err := exportToStorage(cmd.Context(), logger, client, currentEmoji, archive, cache.NewManifest(), nil)
+			if err := errors.Join(err, archive.Close()); err != nil {
+				logger.Error("unable to write archive", "error", err)
+				return
+			}
+			logger.Info("wrote archive", "archive", output, "failed", failed)
+			return
+		}
+
+		logger.Info("creating export directory")
+		exportDir := path.Join(directory, subdomain)
+		os.MkdirAll(exportDir, 0755)
+
 		logger.Info("listing downloaded emojis from filesystem")
 		cached, err := cache.ListDownloadedEmojis(exportDir)
 		if err != nil {
 			logger.Error("unable to get cached emojis", "error", err)
 			return
 		}
+		cachedByName := lo.KeyBy(cached, func(e cache.EmojiItem) string {
+			return e.Name
+		})
+
+		manifest, err := cache.LoadManifest(exportDir)
+		if err != nil {
+			logger.Error("unable to load manifest", "error", err)
+			return
+		}
+
+		since, err := parseSince(exportSince, manifest)
+		if err != nil {
+			logger.Error("invalid --since", "error", err)
+			return
+		}
+		if since > 0 {
+			logger.Info("only exporting emoji created since", "since", time.Unix(since, 0).Format(time.RFC3339))
+		}
 
+		changes := &gitChanges{}
 		logger.Info("exporting emojis")
 		wp := workerpool.New(concurrency)
 		for _, emoji := range currentEmoji {
+			if cmd.Context().Err() != nil {
+				break
+			}
 			request := emoji
 			wp.Submit(func() {
 				loopLog := logger.With("name", request.Name)
-				if slices.ContainsFunc(cached, func(e cache.EmojiItem) bool {
-					return e.Name == request.Name
-				}) {
-					loopLog.Debug("already downloaded, skipping")
+				if cmd.Context().Err() != nil {
+					return
+				}
+				if request.IsAlias == 1 {
+					// aliases point at another emoji's image, so they're recorded
+					// in the manifest instead of downloaded as duplicates
+					loopLog.Debug("recording alias", "alias_for", request.AliasFor)
+					if entry, ok := manifest.Get(request.Name); !ok || entry.RemovedAt != 0 {
+						changes.record(request, true)
+					}
+					manifest.RecordAlias(request)
 					return
 				}
 
+				item, cached := cachedByName[request.Name]
+				if request.Created < since {
+					loopLog.Debug("created before --since, skipping")
+					if cached {
+						if err := manifest.Record(request, exportDir, relativePath(exportDir, filepath.Join(item.Dir, item.Filename)), false); err != nil {
+							loopLog.Error("error updating manifest", "error", err)
+						}
+					}
+					return
+				}
+				if cached && !exportForce {
+					changed, reason := manifest.Changed(request)
+					if !changed {
+						loopLog.Debug("already downloaded, skipping")
+						if err := manifest.Record(request, exportDir, relativePath(exportDir, filepath.Join(item.Dir, item.Filename)), false); err != nil {
+							loopLog.Error("error updating manifest", "error", err)
+						}
+						return
+					}
+					loopLog.Info("emoji changed, downloading again", "reason", reason)
+				}
+
 				loopLog.Debug("exporting emoji")
-				if err := client.ExportEmoji(request, exportDir); err != nil {
+				filename, err := client.ExportEmoji(cmd.Context(), request, exportDir)
+				if interrupted(cmd.Context(), err) {
+					loopLog.Debug("export interrupted")
+					return
+				} else if err != nil {
 					loopLog.Error("error exporting", "error", err)
+					return
+				}
+				paths := []string{filename}
+				if oldPath := filepath.Join(item.Dir, item.Filename); cached && oldPath != filepath.Join(exportDir, filename) {
+					// the emoji changed format or lived in a subdirectory
+					os.Remove(oldPath)
+					paths = append(paths, relativePath(exportDir, oldPath))
+				}
+				changes.record(request, !cached, paths...)
+				if err := manifest.Record(request, exportDir, filename, true); err != nil {
+					loopLog.Error("error updating manifest", "error", err)
 				}
-
 			})
 		}
 
 		wp.StopWait()
+		if err := cmd.Context().Err(); err != nil {
+			logger.Warn("export interrupted, saving progress so far", "error", err)
+		} else {
+			manifest.Advance(currentEmoji)
+			changes.removed, err = recordRemovals(logger, exportDir, manifest, currentEmoji, cachedByName)
+			if err != nil {
+				logger.Error("unable to record removed emoji", "error", err)
+			}
+		}
+
+		logger.Info("writing manifest")
+		if err := manifest.Save(exportDir); err != nil {
+			logger.Error("unable to write manifest", "error", err)
+			return
+		}
+
+		if cmd.Context().Err() == nil {
+			logger.Info("taking snapshot")
+			if err := takeSnapshot(logger, exportDir, manifest); err != nil {
+				logger.Error("unable to take snapshot", "error", err)
+			}
+		}
+
+		if exportGit {
+			// an interrupted export is committed too, the next one picks up where it left off
+			logger.Info("committing export to git", "per", exportGitCommitPer)
+			if err := commitExport(context.WithoutCancel(cmd.Context()), logger, exportDir, exportGitCommitPer, changes); err != nil {
+				logger.Error("unable to commit export", "error", err)
+			}
+		}
 	},
 }
 
+/*
+recordRemovals
+
+Tombstones every emoji in the manifest that's no longer in slack, moving
+their images into the _removed area first with --move-removed so the
+tombstone records where they went. Returns the tombstones.
+*/
+func recordRemovals(logger *slog.Logger, exportDir string, manifest *cache.Manifest, live []slack.Emoji, cached map[string]cache.EmojiItem) ([]cache.ManifestEntry, error) {
+	removed := manifest.MarkRemoved(live, time.Now())
+	for i, entry := range removed {
+		loopLog := logger.With("name", entry.Name)
+		loopLog.Info("emoji was removed from slack", "uploaded_by", entry.UserDisplayName)
+		item, ok := cached[entry.Name]
+		if !exportMoveRemoved || entry.IsAlias || !ok {
+			continue
+		}
+		relative, err := cache.MoveToRemoved(exportDir, filepath.Join(item.Dir, item.Filename), time.Unix(entry.RemovedAt, 0))
+		if err != nil {
+			loopLog.Error("unable to move removed emoji", "error", err)
+			continue
+		}
+		manifest.SetFilename(entry.Name, relative)
+		removed[i].Filename = relative
+	}
+	return removed, cache.AppendTombstones(exportDir, removed)
+}
+
+// writePack writes the emoji matching --since, --include and --exclude to the --pack file
+func writePack(logger *slog.Logger, emoji []slack.Emoji) error {
+	if err := errors.Join(validatePackSrc(exportPackSrc), validatePatterns(append(exportPackInclude, exportPackExclude...))); err != nil {
+		return err
+	}
+	exportDir := path.Join(directory, subdomain)
+	manifest, err := cache.LoadManifest(exportDir)
+	if err != nil {
+		return err
+	}
+	since, err := parseSince(exportSince, manifest)
+	if err != nil {
+		return err
+	}
+	emoji = lo.Filter(emoji, func(e slack.Emoji, index int) bool {
+		return e.IsAlias == 1 || e.Created >= since
+	})
+	pack, err := buildPack(logger, lo.CoalesceOrEmpty(exportPackTitle, subdomain), emoji, exportPackInclude, exportPackExclude,
+		exportPackSrc, exportDir, exportPack)
+	if err != nil {
+		return err
+	}
+	if err := pack.Save(exportPack); err != nil {
+		return err
+	}
+	logger.Info("wrote emoji pack", "pack", exportPack, "emoji", len(pack.Emojis))
+	return nil
+}
+
+/*
+parseSince
+
+Turns the --since flag into a unix timestamp. It accepts a date, an
+RFC3339 time, a duration before now, or "last" for the high water mark
+recorded by the previous complete export. Empty means no cut off.
+*/
+func parseSince(value string, manifest *cache.Manifest) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if value == "last" {
+		return manifest.HighWaterMark, nil
+	}
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return parsed.Unix(), nil
+		}
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-duration).Unix(), nil
+	}
+	return 0, fmt.Errorf("unable to parse %q as a date, time or duration", value)
+}
+
 func init() {
 	rootCmd.AddCommand(exportCmd)
 	exportCmd.Flags().IntVar(&concurrency, "concurrency", 1, "concurrency to use to download")
+	exportCmd.Flags().StringVar(&exportSince, "since", "", "only export emoji created since a date (2006-01-02 or RFC3339), a duration ago (e.g. 720h), or \"last\" for the previous export")
+	exportCmd.Flags().BoolVar(&exportMoveRemoved, "move-removed", false, "move images of emoji removed from slack into the _removed folder")
+	exportCmd.Flags().StringVar(&exportFormat, "format", storage.FormatDir, "write the export as loose files (dir), into a single zip or tar.gz archive, or into an s3 bucket (s3)")
+	exportCmd.Flags().StringVar(&exportOutput, "output", "", "file to write a zip or tar.gz export to, defaults to <directory>/<subdomain>-<time>.<format>, or an s3://bucket/prefix url")
+	exportCmd.Flags().BoolVar(&exportGit, "git", false, "commit the export directory to git, initializing a repository if it isn't in one")
+	exportCmd.Flags().StringVar(&exportGitCommitPer, "git-commit-per", gitCommitPerRun, "with --git, make one commit per export run, or one per emoji authored by its uploader")
+	exportCmd.Flags().StringVar(&exportPack, "pack", "", "instead of downloading images, write an emoji pack yaml file listing the emoji")
+	exportCmd.Flags().StringVar(&exportPackTitle, "pack-title", "", "title of the emoji pack, defaults to the subdomain")
+	exportCmd.Flags().StringVar(&exportPackSrc, "pack-src", packSrcURL, "where the pack's images come from: their url on the platform (url), or the images in the export directory relative to the pack (file)")
+	exportCmd.Flags().StringSliceVar(&exportPackInclude, "include", nil, "with --pack, only include emoji with names matching these glob patterns")
+	exportCmd.Flags().StringSliceVar(&exportPackExclude, "exclude", nil, "with --pack, leave out emoji with names matching these glob patterns")
+	exportCmd.Flags().BoolVar(&exportForce, "force", false, "download every emoji again, even if it hasn't changed")
 }
@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/cache"
+	"github.com/erindatkinson/emoji-archiver/internal/gitrepo"
+	"github.com/erindatkinson/emoji-archiver/internal/history"
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+	"github.com/samber/lo"
+)
+
+// --git-commit-per values
+const (
+	gitCommitPerRun   = "run"
+	gitCommitPerEmoji = "emoji"
+)
+
+// gitChange is an emoji an export added or downloaded again, with the files it touched
+type gitChange struct {
+	emoji slack.Emoji
+	added bool
+	paths []string
+}
+
+// gitChanges collects what an export did from its workers so it can be committed afterwards
+type gitChanges struct {
+	mu      sync.Mutex
+	changes []gitChange
+	removed []cache.ManifestEntry
+}
+
+// record notes an emoji the export touched, paths are relative to the export directory
+func (c *gitChanges) record(emoji slack.Emoji, added bool, paths ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.changes = append(c.changes, gitChange{emoji: emoji, added: added, paths: paths})
+}
+
+/*
+commitExport
+
+Commits an export directory to git. Per run, everything goes into one
+commit listing what was added, changed and removed. Per emoji, each
+downloaded emoji gets its own commit authored by its uploader and dated
+when it was uploaded, and the removals and bookkeeping files follow in a
+final commit. The _history area is ignored, git keeps that history
+already.
+*/
+func commitExport(ctx context.Context, logger *slog.Logger, exportDir, per string, changes *gitChanges) error {
+	repo, err := gitrepo.Open(ctx, exportDir)
+	if err != nil {
+		return err
+	}
+	if err := repo.Ignore(ctx, history.Dir); err != nil {
+		return err
+	}
+
+	remaining := changes.changes
+	if per == gitCommitPerEmoji {
+		remaining = nil
+		for _, change := range changes.changes {
+			if len(change.paths) == 0 {
+				// aliases have no file of their own, they're listed in the final commit
+				remaining = append(remaining, change)
+				continue
+			}
+			paths, err := committablePaths(ctx, repo, exportDir, change.paths)
+			if err != nil {
+				return err
+			}
+			if err := repo.Add(ctx, paths...); err != nil {
+				return err
+			}
+			if staged, err := repo.Staged(ctx, paths...); err != nil {
+				return err
+			} else if !staged {
+				// downloaded again but the image came back the same
+				continue
+			}
+			verb := lo.Ternary(change.added, "Add", "Update")
+			message := fmt.Sprintf("%s :%s:\n\n%s\n", verb, change.emoji.Name, uploadedByLine(change.emoji.UserDisplayName, change.emoji.Created))
+			if err := repo.Commit(ctx, message, gitAuthor(change.emoji.UserDisplayName, change.emoji.UserID), uploadedAt(change.emoji.Created), paths...); err != nil {
+				return err
+			}
+			logger.Debug("committed emoji", "name", change.emoji.Name)
+		}
+	}
+
+	if err := repo.Add(ctx); err != nil {
+		return err
+	}
+	staged, err := repo.Staged(ctx)
+	if err != nil {
+		return err
+	}
+	if !staged {
+		logger.Info("nothing changed, not committing")
+		return nil
+	}
+
+	uploaders := lo.Uniq(append(
+		lo.Map(remaining, func(change gitChange, index int) gitrepo.Author {
+			return gitAuthor(change.emoji.UserDisplayName, change.emoji.UserID)
+		}),
+		lo.Map(changes.removed, func(entry cache.ManifestEntry, index int) gitrepo.Author {
+			return gitAuthor(entry.UserDisplayName, entry.UserID)
+		})...,
+	))
+	author := gitrepo.Author{}
+	if len(uploaders) == 1 {
+		author = uploaders[0]
+	}
+	if err := repo.Commit(ctx, exportCommitMessage(remaining, changes.removed), author, time.Time{}); err != nil {
+		return err
+	}
+	logger.Info("committed export", "dir", repo.Dir)
+	return nil
+}
+
+/*
+committablePaths
+
+Drops paths git can't be asked about: a file the export deleted (an emoji
+that changed format) that was never committed, as happens on the first
+--git run over an existing export
+*/
+func committablePaths(ctx context.Context, repo *gitrepo.Repo, exportDir string, paths []string) ([]string, error) {
+	committable := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if _, err := os.Stat(filepath.Join(exportDir, path)); err == nil {
+			committable = append(committable, path)
+			continue
+		}
+		tracked, err := repo.Tracked(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		if tracked {
+			committable = append(committable, path)
+		}
+	}
+	return committable, nil
+}
+
+// exportCommitMessage summarizes an export run for its commit
+func exportCommitMessage(changes []gitChange, removed []cache.ManifestEntry) string {
+	added, changed := lo.FilterReject(changes, func(change gitChange, index int) bool {
+		return change.added
+	})
+	sections := []string{}
+	section := func(title string, lines []string) {
+		if len(lines) > 0 {
+			sections = append(sections, title+":\n"+strings.Join(lines, "\n"))
+		}
+	}
+	changeLines := func(changes []gitChange) []string {
+		return lo.Map(changes, func(change gitChange, index int) string {
+			return fmt.Sprintf("  :%s: %s", change.emoji.Name, uploadedByLine(change.emoji.UserDisplayName, change.emoji.Created))
+		})
+	}
+	section("Added", changeLines(added))
+	section("Changed", changeLines(changed))
+	section("Removed", lo.Map(removed, func(entry cache.ManifestEntry, index int) string {
+		return fmt.Sprintf("  :%s: %s", entry.Name, uploadedByLine(entry.UserDisplayName, entry.Created))
+	}))
+
+	subject := fmt.Sprintf("Export %s: %d added, %d changed, %d removed", subdomain, len(added), len(changed), len(removed))
+	if len(sections) == 0 {
+		return subject + "\n"
+	}
+	return subject + "\n\n" + strings.Join(sections, "\n\n") + "\n"
+}
+
+// uploadedAt is when an emoji was uploaded, or zero when slack didn't say
+func uploadedAt(created int64) time.Time {
+	if created == 0 {
+		return time.Time{}
+	}
+	return time.Unix(created, 0)
+}
+
+func uploadedByLine(uploader string, created int64) string {
+	line := "uploaded by " + lo.CoalesceOrEmpty(uploader, "unknown")
+	if created != 0 {
+		line += " on " + time.Unix(created, 0).Format(time.DateOnly)
+	}
+	return line
+}
+
+// gitAuthor attributes a commit to an uploader, the email only has to be unique to them
+func gitAuthor(displayName, userID string) gitrepo.Author {
+	if userID == "" {
+		return gitrepo.Author{}
+	}
+	domain := subdomain
+	if platformName == platform.Slack {
+		domain += ".slack.com"
+	}
+	return gitrepo.Author{
+		Name:  lo.CoalesceOrEmpty(displayName, userID),
+		Email: fmt.Sprintf("%s@%s", userID, domain),
+	}
+}
+
+// relativePath makes an export path relative to the export directory for git
+func relativePath(exportDir, fPath string) string {
+	if relative, err := filepath.Rel(exportDir, fPath); err == nil {
+		return relative
+	}
+	return fPath
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/cache"
+	"github.com/erindatkinson/emoji-archiver/internal/history"
+	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history [from] [to]",
+	Short: "List export snapshots, or show what changed between two of them",
+	Long: `List the snapshots taken by each directory export, zip, tar.gz and s3
+exports don't take any. Given one snapshot id, show what changed between it
+and the latest snapshot, given two, what changed between them.`,
+	Args:          cobra.MaximumNArgs(2),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := utilities.ContextLogger(cmd.Context())
+		store := history.Open(path.Join(directory, subdomain))
+
+		if len(args) == 0 {
+			ids, err := store.List()
+			if err != nil {
+				logger.Error("unable to list snapshots", "error", err)
+				return err
+			}
+			snapshots := make([]*history.Snapshot, 0, len(ids))
+			for _, id := range ids {
+				snapshot, err := store.Load(id)
+				if err != nil {
+					logger.Error("unable to load snapshot", "error", err, "id", id)
+					return err
+				}
+				snapshots = append(snapshots, snapshot)
+			}
+			renderSnapshots(os.Stdout, snapshots)
+			return nil
+		}
+
+		to := "latest"
+		if len(args) == 2 {
+			to = args[1]
+		}
+		before, err := store.Load(args[0])
+		if err != nil {
+			logger.Error("unable to load snapshot", "error", err, "id", args[0])
+			return err
+		}
+		after, err := store.Load(to)
+		if err != nil {
+			logger.Error("unable to load snapshot", "error", err, "id", to)
+			return err
+		}
+		renderChanges(os.Stdout, history.Diff(before, after))
+		return nil
+	},
+}
+
+/*
+takeSnapshot
+
+Records the emoji in the manifest that are still in slack as a new
+snapshot, adding their images to the blob store. Nothing is written when
+nothing changed since the last snapshot.
+*/
+func takeSnapshot(logger *slog.Logger, exportDir string, manifest *cache.Manifest) error {
+	store := history.Open(exportDir)
+	downloaded, err := cache.ListDownloadedEmojis(exportDir)
+	if err != nil {
+		return err
+	}
+	files := lo.KeyBy(downloaded, func(emoji cache.EmojiItem) string {
+		return emoji.Name
+	})
+
+	snapshot := history.NewSnapshot(time.Now())
+	for _, entry := range manifest.Entries() {
+		if entry.RemovedAt != 0 {
+			continue
+		}
+		record := history.Entry{
+			Name:            entry.Name,
+			AliasFor:        entry.AliasFor,
+			Created:         entry.Created,
+			UserID:          entry.UserID,
+			UserDisplayName: entry.UserDisplayName,
+		}
+		if !entry.IsAlias {
+			if entry.SHA256 == "" {
+				logger.Debug("no image recorded for emoji, leaving it out of the snapshot", "name", entry.Name)
+				continue
+			}
+			fPath := filepath.Join(exportDir, entry.Filename)
+			if _, err := os.Stat(fPath); errors.Is(err, fs.ErrNotExist) {
+				if item, ok := files[entry.Name]; ok {
+					fPath = filepath.Join(item.Dir, item.Filename)
+				}
+			}
+			record.SHA256 = entry.SHA256
+			record.Ext = filepath.Ext(fPath)
+			if err := store.PutBlob(fPath, record.SHA256, record.Ext); err != nil {
+				logger.Error("unable to store image", "error", err, "name", entry.Name)
+			}
+		}
+		snapshot.Emoji[entry.Name] = record
+	}
+
+	ids, err := store.List()
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		latest, err := store.Load(ids[len(ids)-1])
+		if err != nil {
+			return err
+		}
+		if len(history.Diff(latest, snapshot)) == 0 {
+			logger.Info("no changes since the last snapshot", "id", latest.ID)
+			return nil
+		}
+	}
+	if err := store.Save(snapshot); err != nil {
+		return err
+	}
+	logger.Info("saved snapshot", "id", snapshot.ID, "count", len(snapshot.Emoji))
+	return nil
+}
+
+func renderSnapshots(w io.Writer, snapshots []*history.Snapshot) {
+	t := table.NewWriter()
+	t.SetStyle(table.StyleRounded)
+	t.SetOutputMirror(w)
+	t.AppendHeader(table.Row{"Snapshot", "Taken", "Emoji", "Added", "Removed", "Changed"})
+	var previous *history.Snapshot
+	for _, snapshot := range snapshots {
+		kinds := lo.CountValuesBy(history.Diff(previous, snapshot), func(change history.Change) string {
+			return change.Kind
+		})
+		t.AppendRow(table.Row{
+			snapshot.ID,
+			formatUnix(snapshot.TakenAt),
+			len(snapshot.Emoji),
+			kinds[history.ChangeAdded],
+			kinds[history.ChangeRemoved],
+			kinds[history.ChangeImage] + kinds[history.ChangeRetargeted],
+		})
+		previous = snapshot
+	}
+	t.AppendFooter(table.Row{"", "Total", fmt.Sprintf("%d snapshots", len(snapshots))})
+	t.Render()
+}
+
+func renderChanges(w io.Writer, changes []history.Change) {
+	t := table.NewWriter()
+	t.SetStyle(table.StyleRounded)
+	t.SetOutputMirror(w)
+	t.AppendHeader(table.Row{"Emoji", "Change", "Detail"})
+	for _, change := range changes {
+		detail := ""
+		switch change.Kind {
+		case history.ChangeAdded:
+			detail = "uploaded by " + change.After.UserDisplayName
+		case history.ChangeRemoved:
+			detail = "uploaded by " + change.Before.UserDisplayName
+		case history.ChangeImage:
+			detail = fmt.Sprintf("%.8s -> %.8s", change.Before.SHA256, change.After.SHA256)
+		case history.ChangeRetargeted:
+			detail = fmt.Sprintf("%s -> %s", change.Before.AliasFor, change.After.AliasFor)
+		}
+		if change.Kind == history.ChangeAdded && change.After.AliasFor != "" {
+			detail = "alias for " + change.After.AliasFor
+		}
+		t.AppendRow(table.Row{change.Name, change.Kind, detail})
+	}
+	t.AppendFooter(table.Row{"", "Total", fmt.Sprintf("%d changes", len(changes))})
+	t.Render()
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+}
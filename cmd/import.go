@@ -4,83 +4,411 @@ Copyright © 2025 Erin Atkinson
 package cmd
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/erindatkinson/emoji-archiver/internal/cache"
+	"github.com/erindatkinson/emoji-archiver/internal/discord"
+	"github.com/erindatkinson/emoji-archiver/internal/images"
+	"github.com/erindatkinson/emoji-archiver/internal/journal"
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
 	"github.com/erindatkinson/emoji-archiver/internal/slack"
 	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+	"github.com/gammazero/workerpool"
 	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 )
 
-var importDryRun bool
+var (
+	importDryRun           bool
+	importFix              bool
+	importStagingDir       string
+	importViolationsReport string
+	importRetryFailed      bool
+	importConcurrency      int
+	importFrom             string
+	importPriority         string
+	importStaticSlots      int
+	importAnimatedSlots    int
+)
+
+const importProgressInterval = 10 * time.Second
 
 // importCmd represents the import command
 var importCmd = &cobra.Command{
-	Use:   "import",
-	Short: "Add a collection of emoji to a given slack team",
-	Run: func(cmd *cobra.Command, args []string) {
+	Use:           "import",
+	Short:         "Add a collection of emoji to a given slack team",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		logger := utilities.ContextLogger(cmd.Context())
-		if browser == "" || profile == "" || subdomain == "" {
-			slog.Error("error reading configs from env, config, or flags")
-			return
+		if subdomain == "" {
+			logger.Error("error reading configs from env, config, or flags")
+			return errMissingConfig
+		}
+
+		importDir, cleanup, err := importSource(cmd.Context(), logger, importFrom, path.Join(directory, subdomain))
+		if err != nil {
+			logger.Error("unable to read emoji to import", "error", err)
+			return err
 		}
+		defer cleanup()
 
-		importDir := path.Join(directory, subdomain)
-		client, err := slack.NewSlackClient(cmd.Context(), browser, profile, subdomain)
+		client, err := newBackend(cmd.Context(), platformName, subdomain, browser, profile)
 		if err != nil {
-			logger.Error("error creating slack client", "error", err)
-			return
+			logger.Error("error creating client", "error", err, "platform", platformName)
+			return err
 		}
 
 		files, err := os.ReadDir(importDir)
 		if err != nil {
 			logger.Error("error reading files", "error", err)
-			return
+			return err
 		}
 		logger.Info("found emojis to import", "count", len(files))
 
-		emojis, err := client.ListEmoji()
+		emojis, err := client.ListEmoji(cmd.Context())
 		if err != nil {
 			logger.Error("error listing emojis", "err", err)
-			return
+			return err
 		}
 		logger.Info("found existing emojis", "count", len(emojis))
 
+		manifest, err := cache.LoadManifest(importDir)
+		if err != nil {
+			logger.Error("error loading manifest", "error", err)
+			return err
+		}
+		aliases := manifest.Aliases()
+		logger.Info("found aliases in manifest", "count", len(aliases))
+
+		results := &emojiResults{}
 		filteredFiles := lo.Filter(files, func(item os.DirEntry, index int) bool {
-			if item.Name() == ".DS_Store" {
+			if item.IsDir() || cache.IsMetadataFile(item.Name()) {
 				return false
 			}
 
 			splits := strings.Split(item.Name(), ".")
+			if entry, ok := manifest.Get(splits[0]); ok && entry.IsAlias {
+				// exports from before aliases were tracked may contain a copy
+				// of the target's image, the alias is recreated below instead
+				logger.Debug("filtering out alias file", "emoji", splits[0])
+				return false
+			}
 			_, ok := lo.Find(emojis, func(emoji slack.Emoji) bool {
-				return splits[0] == emoji.Name
+				return importedName(platformName, splits[0]) == emoji.Name
 			})
 			if ok {
 				logger.Debug("filtering out file", "emoji", splits[0])
+				results.add(splits[0], resultSkipped, "already exists")
 			}
 			return !ok
 		})
+		logger.Info("emojis not in slack", "count", len(filteredFiles))
+
+		jrnl, err := journal.Open(journalPath(platformName, subdomain))
+		if err != nil {
+			logger.Error("error opening import journal", "error", err)
+			return err
+		}
+		defer jrnl.Close()
+
+		hashes := make(map[string]string)
+		filteredFiles = lo.Filter(filteredFiles, func(item os.DirEntry, index int) bool {
+			name := strings.Split(item.Name(), ".")[0]
+			hash, err := cache.HashFile(filepath.Join(importDir, item.Name()))
+			if err != nil {
+				logger.Error("unable to hash file", "error", err, "file", item.Name())
+				results.add(name, resultFailed, err.Error())
+				return false
+			}
+			hashes[name] = hash
+			if attempt, reason := resumeFromJournal(jrnl, name, hash); !attempt {
+				logger.Debug("filtering out file from journal", "emoji", name, "reason", reason)
+				results.add(name, resultSkipped, reason)
+				return false
+			}
+			return true
+		})
 		logger.Info("emojis to upload", "count", len(filteredFiles))
 
-		if !importDryRun {
-			for _, file := range filteredFiles {
-				splits := strings.Split(file.Name(), ".")
-				if err := client.ImportEmoji(splits[0], filepath.Join(importDir, file.Name())); err != nil {
-					logger.Error("error importing", "error", err)
-					return
-				}
+		filteredAliases := lo.Filter(aliases, func(item cache.ManifestEntry, index int) bool {
+			_, ok := lo.Find(emojis, func(emoji slack.Emoji) bool {
+				return importedName(platformName, item.Name) == emoji.Name
+			})
+			if ok {
+				logger.Debug("filtering out alias", "emoji", item.Name)
+				results.add(item.Name, resultSkipped, "already exists")
+			}
+			if ok {
+				return false
+			}
+			if attempt, reason := resumeFromJournal(jrnl, item.Name, ""); !attempt {
+				logger.Debug("filtering out alias from journal", "emoji", item.Name, "reason", reason)
+				results.add(item.Name, resultSkipped, reason)
+				return false
 			}
+			return true
+		})
+		logger.Info("aliases to create", "count", len(filteredAliases))
+
+		var uploadTasks, aliasTasks []importTask
+		if discordClient, ok := client.(*discord.Client); ok {
+			logger.Info("planning discord import", "priority", importPriority)
+			placements, err := planDiscordImport(cmd.Context(), logger, discordClient, importDir, manifest, filteredFiles, filteredAliases, results)
+			if err != nil {
+				logger.Error("unable to plan discord import", "error", err)
+				return err
+			}
+			renderDiscordPlan(os.Stdout, placements)
+			// aliases are copies of their target's image on discord, so they're uploads too
+			uploadTasks = discordImportTasks(discordClient, placements, hashes)
 		} else {
+			logger.Info("validating images")
+			uploads, reports := validateImports(logger, importDir, filteredFiles, results)
+			logger.Info("images passing validation", "count", len(uploads), "violations", len(reports))
+			if importViolationsReport != "" {
+				if err := writeViolationsReport(importViolationsReport, reports); err != nil {
+					logger.Error("unable to write violations report", "error", err)
+					return err
+				}
+			}
+
+			uploadTasks = lo.Map(uploads, func(upload emojiFile, index int) importTask {
+				return importTask{
+					entry: journal.Entry{Name: upload.Name, File: upload.Path, SHA256: hashes[upload.Name]},
+					run: func(ctx context.Context) error {
+						return client.ImportEmoji(ctx, upload.Name, upload.Path)
+					},
+				}
+			})
+			aliasTasks = lo.Map(filteredAliases, func(alias cache.ManifestEntry, index int) importTask {
+				return importTask{
+					entry:  journal.Entry{Name: alias.Name},
+					detail: "alias for " + alias.AliasFor,
+					run: func(ctx context.Context) error {
+						return client.AddAlias(ctx, alias.Name, alias.AliasFor)
+					},
+				}
+			})
+		}
+
+		if importDryRun {
 			logger.Info("skipping import due to dry-run")
+			return nil
+		}
+
+		tracker := newProgress("import progress", len(uploadTasks)+len(aliasTasks))
+		stopProgress := tracker.watch(cmd.Context(), logger, importProgressInterval)
+		var aborted atomic.Bool
+		runImportTasks(cmd.Context(), logger, importConcurrency, jrnl, results, tracker, &aborted, uploadTasks)
+		// aliases go last so that the emoji they point at already exist
+		runImportTasks(cmd.Context(), logger, importConcurrency, jrnl, results, tracker, &aborted, aliasTasks)
+		stopProgress()
+		tracker.report(logger)
+
+		results.render(os.Stdout)
+		if err := cmd.Context().Err(); err != nil {
+			logger.Error("import interrupted, rerun to pick up where it left off", "error", err)
+			return err
 		}
+		if failed := results.count(resultFailed); failed > 0 {
+			return fmt.Errorf("%d emoji failed to import", failed)
+		}
+		return nil
 	},
 }
 
+/*
+validateImports
+
+Checks each file against the target platform's emoji limits, returning the files that
+can be uploaded (fixed copies in the staging directory when --fix is set)
+along with the reports for every file that had violations
+*/
+func validateImports(logger *slog.Logger, importDir string, files []os.DirEntry, results *emojiResults) ([]emojiFile, []images.Report) {
+	uploads := make([]emojiFile, 0, len(files))
+	reports := make([]images.Report, 0)
+	stagingDir := filepath.Join(importStagingDir, subdomain)
+	limits := platform.ImageLimits(platformName)
+	for _, file := range files {
+		report, err := images.ValidateFile(filepath.Join(importDir, file.Name()), limits)
+		if err != nil {
+			logger.Error("unable to read image", "error", err, "file", file.Name())
+			results.add(file.Name(), resultFailed, err.Error())
+			continue
+		}
+		if report.Valid() {
+			uploads = append(uploads, emojiFile{Name: report.Name, Path: report.Path})
+			continue
+		}
+
+		loopLog := logger.With("emoji", report.Name, "violations", report.Violations)
+		if importFix && report.Fixable() {
+			fixed, err := images.FixFile(report, stagingDir, limits)
+			if err != nil {
+				loopLog.Error("unable to fix image, skipping", "error", err)
+				results.add(report.Name, resultSkipped, "unable to fix: "+err.Error())
+			} else {
+				loopLog.Info("fixed image", "path", fixed)
+				report.FixedPath = fixed
+				uploads = append(uploads, emojiFile{Name: report.Name, Path: fixed})
+			}
+		} else {
+			loopLog.Warn("image is invalid, skipping")
+			results.add(report.Name, resultSkipped, violationSummary(report))
+		}
+		reports = append(reports, report)
+	}
+	return uploads, reports
+}
+
+// importTask is one upload or alias for the import workers
+type importTask struct {
+	entry  journal.Entry
+	detail string
+	run    func(ctx context.Context) error
+}
+
+/*
+runImportTasks
+
+Runs tasks on a pool of workers and waits for them to finish. Workers
+share the slack client's rate limiter, so a 429 seen by one pauses the
+rest. Each attempt is journaled unless it was interrupted or there's no
+journal, and an auth error stops any remaining tasks from starting.
+*/
+func runImportTasks(ctx context.Context, logger *slog.Logger, workers int, jrnl *journal.Journal, results *emojiResults, tracker *progress, aborted *atomic.Bool, tasks []importTask) {
+	wp := workerpool.New(max(1, workers))
+	for _, task := range tasks {
+		wp.Submit(func() {
+			defer tracker.increment()
+			name := task.entry.Name
+			if aborted.Load() || ctx.Err() != nil {
+				results.add(name, resultSkipped, "import aborted")
+				return
+			}
+			err := task.run(ctx)
+			if interrupted(ctx, err) {
+				results.add(name, resultSkipped, "import aborted")
+				return
+			}
+			if jrnl != nil {
+				recordAttempt(logger, jrnl, task.entry, err)
+			}
+			if err != nil {
+				logger.Error("error importing", "error", err, "emoji", name)
+				results.add(name, resultFailed, err.Error())
+				if errors.Is(err, slack.ErrAuth) {
+					aborted.Store(true)
+				}
+				return
+			}
+			logger.Debug("imported", "emoji", name)
+			results.add(name, resultSucceeded, task.detail)
+		})
+	}
+	wp.StopWait()
+}
+
+// journalPath is where the import journal for a subdomain is kept, each platform other than slack gets its own
+func journalPath(platformName, subdomain string) string {
+	if platformName != platform.Slack {
+		return filepath.Join(directory, ".journal", subdomain+"."+platformName+".jsonl")
+	}
+	return filepath.Join(directory, ".journal", subdomain+".jsonl")
+}
+
+/*
+resumeFromJournal
+
+Decides whether an emoji should be attempted given its last recorded
+attempt. Unchanged emoji that already succeeded are never retried, and
+ones that failed for good are only retried with --retry-failed, which in
+turn skips anything that didn't fail last time. Retryable failures are
+attempted again on every run.
+*/
+func resumeFromJournal(jrnl *journal.Journal, name, hash string) (bool, string) {
+	entry, ok := jrnl.Lookup(name)
+	previouslyFailed := ok && (entry.Outcome == journal.OutcomeFailed || entry.Outcome == journal.OutcomeRetryable)
+	switch {
+	case importRetryFailed && !previouslyFailed:
+		return false, "only retrying previous failures"
+	case importRetryFailed:
+		return true, ""
+	case ok && entry.SHA256 == hash && entry.Outcome == journal.OutcomeSucceeded:
+		return false, "imported in a previous run"
+	case ok && entry.SHA256 == hash && entry.Outcome == journal.OutcomeFailed:
+		return false, fmt.Sprintf("failed in a previous run (%s), use --retry-failed", entry.Error)
+	}
+	return true, ""
+}
+
+// recordAttempt journals an import, failures are only sticky when retrying can't fix them
+func recordAttempt(logger *slog.Logger, jrnl *journal.Journal, entry journal.Entry, err error) {
+	switch {
+	case err == nil:
+		entry.Outcome = journal.OutcomeSucceeded
+	case permanentFailure(err):
+		entry.Outcome = journal.OutcomeFailed
+		entry.Error = err.Error()
+	default:
+		entry.Outcome = journal.OutcomeRetryable
+		entry.Error = err.Error()
+	}
+	if err := jrnl.Record(entry); err != nil {
+		logger.Error("unable to write to import journal", "error", err, "emoji", entry.Name)
+	}
+}
+
+// permanentFailure reports whether an upload failed in a way that trying again won't fix
+func permanentFailure(err error) bool {
+	for _, kind := range []error{slack.ErrBadImage, slack.ErrInvalidName, slack.ErrTooLarge, slack.ErrNameTaken} {
+		if errors.Is(err, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+func violationSummary(report images.Report) string {
+	messages := lo.Map(report.Violations, func(item images.Violation, index int) string {
+		return item.Message
+	})
+	return strings.Join(messages, "; ")
+}
+
+func writeViolationsReport(fPath string, reports []images.Report) error {
+	fp, err := os.Create(fPath)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	encoder := json.NewEncoder(fp)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(reports)
+}
+
 func init() {
 	rootCmd.AddCommand(importCmd)
+	importCmd.Flags().StringVar(&importFrom, "from", "", "import from a zip or tar.gz archive, an s3://bucket/prefix url, or an emoji pack yaml file or url instead of the export directory")
 	importCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "do a dry run")
+	importCmd.Flags().IntVar(&importConcurrency, "concurrency", 4, "how many uploads to run at once")
+	importCmd.Flags().BoolVar(&importFix, "fix", false, "downsize and recompress images that are over slack's limits before uploading")
+	importCmd.Flags().StringVar(&importStagingDir, "staging-dir", filepath.Join(os.TempDir(), "emoji-archiver"), "directory to write fixed images into")
+	importCmd.Flags().BoolVar(&importRetryFailed, "retry-failed", false, "only retry emoji that failed in a previous run")
+	importCmd.Flags().StringVar(&importViolationsReport, "violations-report", "", "write a json report of images that break slack's limits to this file")
+	importCmd.Flags().StringVar(&importPriority, "priority", discord.PriorityNewest, fmt.Sprintf("on discord, which emoji get slots first when they don't all fit: %s", strings.Join(discord.Priorities, ", ")))
+	importCmd.Flags().IntVar(&importStaticSlots, "discord-static-slots", -1, "plan a discord import as if the guild had this many free static slots, instead of asking discord")
+	importCmd.Flags().IntVar(&importAnimatedSlots, "discord-animated-slots", -1, "plan a discord import as if the guild had this many free animated slots, instead of asking discord")
 }
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/erindatkinson/emoji-archiver/internal/cache"
+	"github.com/erindatkinson/emoji-archiver/internal/emojipack"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+	"github.com/gammazero/workerpool"
+	"github.com/samber/lo"
+)
+
+const (
+	packSrcURL  = "url"
+	packSrcFile = "file"
+)
+
+/*
+extractPack
+
+Fetches every image in an emoji pack into dir alongside a manifest
+recording the pack's aliases, so a pack imports just like an export
+directory. Images that can't be fetched are logged and left out.
+*/
+func extractPack(ctx context.Context, logger *slog.Logger, location, dir string) (int, error) {
+	client, err := newHTTPClient()
+	if err != nil {
+		return 0, err
+	}
+	pack, err := emojipack.Load(ctx, client, location)
+	if err != nil {
+		return 0, err
+	}
+	logger.Info("read emoji pack", "title", pack.Title, "emoji", len(pack.Emojis))
+
+	manifest := cache.NewManifest()
+	var fetched atomic.Int64
+	wp := workerpool.New(max(1, importConcurrency))
+	for _, emoji := range pack.Emojis {
+		wp.Submit(func() {
+			if ctx.Err() != nil {
+				return
+			}
+			data, filename, err := pack.Fetch(ctx, client, emoji)
+			if err == nil && !filepath.IsLocal(filename) {
+				err = fmt.Errorf("refusing to extract %q outside of the import directory", filename)
+			}
+			if err == nil {
+				err = os.WriteFile(filepath.Join(dir, filename), data, 0644)
+			}
+			if err != nil {
+				logger.Error("unable to fetch emoji from pack, skipping", "error", err, "emoji", emoji.Name, "src", emoji.Src)
+				return
+			}
+			manifest.RecordData(slack.Emoji{Name: emoji.Name}, filename, data)
+			for _, alias := range emoji.Aliases {
+				manifest.RecordAlias(slack.Emoji{Name: alias, IsAlias: 1, AliasFor: emoji.Name})
+			}
+			fetched.Add(1)
+		})
+	}
+	wp.StopWait()
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return int(fetched.Load()), manifest.Save(dir)
+}
+
+/*
+buildPack
+
+Turns a platform's emoji into a pack, leaving out any that don't match the
+include and exclude patterns. Aliases are listed under the emoji they
+point at. Each src is the emoji's url on the platform, or with srcMode
+file the path from the pack to its image in the export directory, in
+which case emoji that haven't been exported yet are left out.
+*/
+func buildPack(logger *slog.Logger, title string, emoji []slack.Emoji, include, exclude []string, srcMode, exportDir, packPath string) (*emojipack.Pack, error) {
+	var cachedByName map[string]cache.EmojiItem
+	if srcMode == packSrcFile {
+		cached, err := cache.ListDownloadedEmojis(exportDir)
+		if err != nil {
+			return nil, err
+		}
+		cachedByName = lo.KeyBy(cached, func(e cache.EmojiItem) string {
+			return e.Name
+		})
+	}
+
+	aliases := make(map[string][]string)
+	for _, alias := range emoji {
+		if alias.IsAlias == 1 && matchesPatterns(alias.Name, include, exclude) {
+			aliases[alias.AliasFor] = append(aliases[alias.AliasFor], alias.Name)
+		}
+	}
+
+	pack := &emojipack.Pack{Title: title, Emojis: []emojipack.Emoji{}}
+	for _, e := range emoji {
+		if e.IsAlias == 1 || !matchesPatterns(e.Name, include, exclude) {
+			continue
+		}
+		src := e.URL
+		if srcMode == packSrcFile {
+			item, ok := cachedByName[e.Name]
+			if !ok {
+				logger.Warn("emoji hasn't been exported, leaving it out of the pack", "emoji", e.Name)
+				continue
+			}
+			relative, err := filepath.Rel(filepath.Dir(packPath), filepath.Join(item.Dir, item.Filename))
+			if err != nil {
+				return nil, err
+			}
+			src = filepath.ToSlash(relative)
+		}
+		names := aliases[e.Name]
+		slices.Sort(names)
+		pack.Emojis = append(pack.Emojis, emojipack.Emoji{Name: e.Name, Src: src, Aliases: names})
+	}
+	slices.SortFunc(pack.Emojis, func(a, b emojipack.Emoji) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return pack, nil
+}
+
+// validatePackSrc checks --pack-src names a known src mode
+func validatePackSrc(mode string) error {
+	if mode != packSrcURL && mode != packSrcFile {
+		return fmt.Errorf("--pack-src must be %s or %s", packSrcURL, packSrcFile)
+	}
+	return nil
+}
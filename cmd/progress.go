@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// progress counts finished items across workers and logs how far along a run is
+type progress struct {
+	label   string
+	total   int
+	done    atomic.Int64
+	started time.Time
+}
+
+func newProgress(label string, total int) *progress {
+	return &progress{label: label, total: total, started: time.Now()}
+}
+
+func (p *progress) increment() {
+	p.done.Add(1)
+}
+
+/*
+report
+
+Logs the count done so far, the rate and an estimate of the time left
+based on the average time per item since the run started
+*/
+func (p *progress) report(logger *slog.Logger) {
+	done := int(p.done.Load())
+	elapsed := time.Since(p.started)
+	args := []any{"done", done, "total", p.total, "elapsed", elapsed.Round(time.Second)}
+	if done > 0 && p.total > 0 {
+		remaining := time.Duration(float64(elapsed) / float64(done) * float64(p.total-done))
+		args = append(args,
+			"percent", done*100/p.total,
+			"per_minute", int(float64(done)/elapsed.Minutes()),
+			"eta", remaining.Round(time.Second),
+		)
+	}
+	logger.Info(p.label, args...)
+}
+
+// watch reports progress on an interval until stop is called or ctx is done
+func (p *progress) watch(ctx context.Context, logger *slog.Logger, every time.Duration) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.report(logger)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-finished
+	}
+}
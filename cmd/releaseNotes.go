@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
 	"github.com/erindatkinson/emoji-archiver/internal/slack"
 	"github.com/erindatkinson/emoji-archiver/internal/templates"
 	"github.com/erindatkinson/emoji-archiver/internal/utilities"
@@ -28,13 +29,22 @@ var releaseNotesCmd = &cobra.Command{
 
 	Run: func(cmd *cobra.Command, args []string) {
 		logger := utilities.ContextLogger(cmd.Context())
-		if browser == "" || profile == "" || subdomain == "" {
+		if subdomain == "" {
 			logger.Error("error reading configs from env, config, or flags")
 			return
 		}
 
-		client, err := slack.NewSlackClient(cmd.Context(), browser, profile, subdomain)
-		emojis, err := client.ListEmoji()
+		if platformName != platform.Slack {
+			logger.Error("release notes can only be posted to slack", "platform", platformName)
+			return
+		}
+
+		client, err := newSlackClient(cmd.Context(), subdomain, browser, profile)
+		if err != nil {
+			logger.Error("unable to create slack client", "error", err)
+			return
+		}
+		emojis, err := client.ListEmoji(cmd.Context())
 		if err != nil {
 			logger.Error("unable to retrieve emoji list")
 			return
@@ -65,7 +75,7 @@ var releaseNotesCmd = &cobra.Command{
 		}
 		if !releaseNotesDryRun {
 			logger.Info("sending chanel header message")
-			resp, err := client.PostMessage(channel, header, nil)
+			resp, err := client.PostMessage(cmd.Context(), channel, header, nil)
 			if err != nil {
 				logger.Error("unable to post message", "error", err)
 				return
@@ -81,7 +91,7 @@ var releaseNotesCmd = &cobra.Command{
 			}
 
 			logger.Info("sending ranks")
-			_, err = client.PostMessage(channel, ranks, &thread)
+			_, err = client.PostMessage(cmd.Context(), channel, ranks, &thread)
 			if err != nil {
 				logger.Error("unable to post ranks to thread", "error", err)
 				return
@@ -98,7 +108,7 @@ var releaseNotesCmd = &cobra.Command{
 					markdown = message
 				}
 
-				_, err = client.PostMessage(channel, markdown, &thread)
+				_, err = client.PostMessage(cmd.Context(), channel, markdown, &thread)
 				if err != nil {
 					logger.Error("unable to post followup message", "error", err)
 					return
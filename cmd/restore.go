@@ -0,0 +1,292 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/cache"
+	"github.com/erindatkinson/emoji-archiver/internal/history"
+	"github.com/erindatkinson/emoji-archiver/internal/journal"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+)
+
+var (
+	restoreAt          string
+	restoreDryRun      bool
+	restoreConcurrency int
+)
+
+// restoreUpload is an archived emoji image to upload again
+type restoreUpload struct {
+	entry cache.ManifestEntry
+	path  string
+}
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:           "restore",
+	Short:         "Re-create the emoji that existed at a point in time from the export directory",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := utilities.ContextLogger(cmd.Context())
+		if subdomain == "" {
+			logger.Error("error reading configs from env, config, or flags")
+			return errMissingConfig
+		}
+		exportDir := path.Join(directory, subdomain)
+
+		manifest, err := cache.LoadManifest(exportDir)
+		if err != nil {
+			logger.Error("unable to load manifest", "error", err)
+			return err
+		}
+		at, err := parseSince(restoreAt, manifest)
+		if err != nil {
+			logger.Error("invalid --at", "error", err)
+			return err
+		}
+		if at == 0 {
+			at = time.Now().Unix()
+		}
+
+		var wanted []cache.ManifestEntry
+		if len(manifest.Emoji) == 0 {
+			logger.Warn("no manifest in the export directory, restoring every downloaded image")
+			wanted, err = cache.EntriesFromFiles(exportDir)
+		} else {
+			var tombstones []cache.ManifestEntry
+			tombstones, err = cache.LoadTombstones(exportDir)
+			wanted = cache.EmojiAt(manifest, tombstones, time.Unix(at, 0))
+		}
+		if err != nil {
+			logger.Error("unable to read the archive", "error", err)
+			return err
+		}
+		logger.Info("emoji in the archive at the time", "count", len(wanted), "at", time.Unix(at, 0).Format(time.RFC3339))
+
+		downloaded, err := cache.ListDownloadedEmojis(exportDir)
+		if err != nil {
+			logger.Error("unable to list downloaded emoji", "error", err)
+			return err
+		}
+
+		client, err := newBackend(cmd.Context(), platformName, subdomain, browser, profile)
+		if err != nil {
+			logger.Error("error creating client", "error", err, "platform", platformName)
+			return err
+		}
+		current, err := client.ListEmoji(cmd.Context())
+		if err != nil {
+			logger.Error("error listing emojis", "error", err)
+			return err
+		}
+
+		results := &emojiResults{}
+		uploads, aliases := planRestore(exportDir, wanted, current, downloaded, results)
+		logger.Info("planned restore", "uploads", len(uploads), "aliases", len(aliases), "conflicts", results.count(resultConflict))
+		if restoreDryRun {
+			renderRestorePlan(os.Stdout, uploads, aliases)
+			results.render(os.Stdout)
+			return nil
+		}
+
+		uploadTasks := lo.Map(uploads, func(upload restoreUpload, index int) importTask {
+			return importTask{
+				entry:  journal.Entry{Name: upload.entry.Name},
+				detail: uploadedBy(upload.entry),
+				run: func(ctx context.Context) error {
+					return client.ImportEmoji(ctx, upload.entry.Name, upload.path)
+				},
+			}
+		})
+		aliasTasks := lo.Map(aliases, func(alias cache.ManifestEntry, index int) importTask {
+			return importTask{
+				entry:  journal.Entry{Name: alias.Name},
+				detail: "alias for " + alias.AliasFor,
+				run: func(ctx context.Context) error {
+					return client.AddAlias(ctx, alias.Name, alias.AliasFor)
+				},
+			}
+		})
+
+		tracker := newProgress("restore progress", len(uploadTasks)+len(aliasTasks))
+		stopProgress := tracker.watch(cmd.Context(), logger, importProgressInterval)
+		var aborted atomic.Bool
+		runImportTasks(cmd.Context(), logger, restoreConcurrency, nil, results, tracker, &aborted, uploadTasks)
+		runImportTasks(cmd.Context(), logger, restoreConcurrency, nil, results, tracker, &aborted, aliasTasks)
+		stopProgress()
+		tracker.report(logger)
+
+		results.render(os.Stdout)
+		if err := cmd.Context().Err(); err != nil {
+			logger.Error("restore interrupted, rerun to pick up where it left off", "error", err)
+			return err
+		}
+		if failed := results.count(resultFailed); failed > 0 {
+			return fmt.Errorf("%d emoji failed to restore", failed)
+		}
+		return nil
+	},
+}
+
+/*
+planRestore
+
+Compares the archived emoji against what's in slack now. Emoji that still
+exist are skipped, names now used by something else are conflicts, and
+everything else is split into images to upload and aliases to create
+afterwards. Images missing from the archive, or replaced there by a later
+version, are failures.
+*/
+func planRestore(exportDir string, wanted []cache.ManifestEntry, current []slack.Emoji, downloaded []cache.EmojiItem, results *emojiResults) ([]restoreUpload, []cache.ManifestEntry) {
+	live := lo.KeyBy(current, func(emoji slack.Emoji) string {
+		return emoji.Name
+	})
+	files := lo.KeyBy(downloaded, func(emoji cache.EmojiItem) string {
+		return emoji.Name
+	})
+	restoring := make(map[string]bool)
+
+	uploads := make([]restoreUpload, 0)
+	aliases := make([]cache.ManifestEntry, 0)
+	for _, entry := range wanted {
+		if emoji, ok := live[entry.Name]; ok {
+			if conflict := restoreConflict(entry, emoji); conflict != "" {
+				results.add(entry.Name, resultConflict, conflict)
+			} else {
+				results.add(entry.Name, resultSkipped, "already exists")
+			}
+			continue
+		}
+		if entry.IsAlias {
+			aliases = append(aliases, entry)
+			continue
+		}
+
+		fPath, err := archivedImage(exportDir, entry, files)
+		if err != nil {
+			results.add(entry.Name, resultFailed, err.Error())
+			continue
+		}
+		uploads = append(uploads, restoreUpload{entry: entry, path: fPath})
+		restoring[entry.Name] = true
+	}
+
+	// aliases can only point at emoji that will exist once the uploads are done
+	aliases = lo.Filter(aliases, func(alias cache.ManifestEntry, index int) bool {
+		target, ok := live[alias.AliasFor]
+		if (ok && target.IsAlias != 1) || restoring[alias.AliasFor] {
+			return true
+		}
+		results.add(alias.Name, resultFailed, fmt.Sprintf("alias target %s isn't in slack or being restored", alias.AliasFor))
+		return false
+	})
+	return uploads, aliases
+}
+
+// restoreConflict describes how the emoji now in slack differs from the archived one, or is empty if they match
+func restoreConflict(entry cache.ManifestEntry, emoji slack.Emoji) string {
+	switch {
+	case entry.IsAlias && emoji.IsAlias == 1 && entry.AliasFor != emoji.AliasFor:
+		return fmt.Sprintf("was an alias for %s, now an alias for %s", entry.AliasFor, emoji.AliasFor)
+	case entry.IsAlias && emoji.IsAlias != 1:
+		return fmt.Sprintf("was an alias for %s, now an image", entry.AliasFor)
+	case !entry.IsAlias && emoji.IsAlias == 1:
+		return "now an alias for " + emoji.AliasFor
+	case !entry.IsAlias && entry.Created != 0 && entry.Created != emoji.Created:
+		return "a different emoji now has this name, uploaded by " + emoji.UserDisplayName
+	}
+	return ""
+}
+
+/*
+archivedImage
+
+Finds an archived emoji's image, at its recorded path or wherever it was
+downloaded to, and checks it's still the version the manifest recorded.
+When it's gone or was replaced by a later version, the copy kept in the
+history blob store is used instead.
+*/
+func archivedImage(exportDir string, entry cache.ManifestEntry, files map[string]cache.EmojiItem) (string, error) {
+	blob, err := history.Open(exportDir).BlobPath(entry.SHA256, filepath.Ext(entry.Filename))
+	if err != nil {
+		blob = ""
+	} else if _, err := os.Stat(blob); err != nil {
+		blob = ""
+	}
+
+	fPath := filepath.Join(exportDir, entry.Filename)
+	if _, err := os.Stat(fPath); errors.Is(err, fs.ErrNotExist) {
+		item, ok := files[entry.Name]
+		switch {
+		case blob != "":
+			return blob, nil
+		case !ok || entry.RemovedAt != 0:
+			return "", errors.New("image isn't in the archive")
+		}
+		fPath = filepath.Join(item.Dir, item.Filename)
+	} else if err != nil {
+		return "", err
+	}
+
+	if entry.SHA256 == "" {
+		return fPath, nil
+	}
+	hash, err := cache.HashFile(fPath)
+	if err != nil {
+		return "", err
+	}
+	if hash != entry.SHA256 {
+		if blob != "" {
+			return blob, nil
+		}
+		return "", errors.New("archived image was replaced by a later version")
+	}
+	return fPath, nil
+}
+
+func uploadedBy(entry cache.ManifestEntry) string {
+	if entry.UserDisplayName == "" {
+		return ""
+	}
+	detail := "originally uploaded by " + entry.UserDisplayName
+	if entry.Created != 0 {
+		detail += " on " + time.Unix(entry.Created, 0).Format(time.DateOnly)
+	}
+	return detail
+}
+
+func renderRestorePlan(w io.Writer, uploads []restoreUpload, aliases []cache.ManifestEntry) {
+	t := table.NewWriter()
+	t.SetStyle(table.StyleRounded)
+	t.SetOutputMirror(w)
+	t.AppendHeader(table.Row{"Emoji", "Action", "Detail"})
+	for _, upload := range uploads {
+		t.AppendRow(table.Row{upload.entry.Name, "upload", uploadedBy(upload.entry)})
+	}
+	for _, alias := range aliases {
+		t.AppendRow(table.Row{alias.Name, "alias", "alias for " + alias.AliasFor})
+	}
+	t.AppendFooter(table.Row{"", "Total", fmt.Sprintf("%d uploads, %d aliases", len(uploads), len(aliases))})
+	t.Render()
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+	restoreCmd.Flags().StringVar(&restoreAt, "at", "", "restore the emoji that existed at a date (2006-01-02 or RFC3339), a duration ago (e.g. 720h), or \"last\" for the previous export, defaults to now")
+	restoreCmd.Flags().BoolVar(&restoreDryRun, "dry-run", false, "print the plan without uploading anything")
+	restoreCmd.Flags().IntVar(&restoreConcurrency, "concurrency", 4, "how many uploads to run at once")
+}
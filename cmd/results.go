@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+const (
+	resultSucceeded = "succeeded"
+	resultSkipped   = "skipped"
+	resultFailed    = "failed"
+	resultConflict  = "conflict"
+)
+
+// interrupted reports whether err came from ctrl-c or --timeout ending the run rather than
+// the emoji itself, a single request timing out under --http-timeout is an ordinary failure
+func interrupted(ctx context.Context, err error) bool {
+	return err != nil && ctx.Err() != nil
+}
+
+type emojiResult struct {
+	Name   string
+	Status string
+	Reason string
+}
+
+// emojiResults collects the outcome for each emoji in a run, it's safe for use from workers
+type emojiResults struct {
+	mu      sync.Mutex
+	results []emojiResult
+}
+
+func (r *emojiResults) add(name, status, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, emojiResult{Name: name, Status: status, Reason: reason})
+}
+
+func (r *emojiResults) count(status string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := 0
+	for _, result := range r.results {
+		if result.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// render writes a table of every result grouped by status, followed by the totals
+func (r *emojiResults) render(w io.Writer) {
+	r.mu.Lock()
+	sorted := slices.Clone(r.results)
+	r.mu.Unlock()
+
+	order := map[string]int{resultSucceeded: 0, resultSkipped: 1, resultConflict: 2, resultFailed: 3}
+	slices.SortFunc(sorted, func(a, b emojiResult) int {
+		return cmp.Or(cmp.Compare(order[a.Status], order[b.Status]), cmp.Compare(a.Name, b.Name))
+	})
+
+	t := table.NewWriter()
+	t.SetStyle(table.StyleRounded)
+	t.SetOutputMirror(w)
+	t.AppendHeader(table.Row{"Emoji", "Result", "Reason"})
+	for _, result := range sorted {
+		t.AppendRow(table.Row{result.Name, result.Status, result.Reason})
+	}
+	summary := fmt.Sprintf("%d succeeded, %d skipped, %d failed",
+		r.count(resultSucceeded), r.count(resultSkipped), r.count(resultFailed))
+	if conflicts := r.count(resultConflict); conflicts > 0 {
+		summary += fmt.Sprintf(", %d conflicts", conflicts)
+	}
+	t.AppendFooter(table.Row{"", "Total", summary})
+	t.Render()
+}
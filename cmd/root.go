@@ -4,30 +4,61 @@ Copyright © 2025 Erin Atkinson
 package cmd
 
 import (
+	"context"
+	"errors"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
 	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var browser, profile, subdomain, channel, directory, logLevel string
 
+var (
+	timeout       time.Duration
+	cancelTimeout context.CancelFunc
+)
+
+var errMissingConfig = errors.New("missing subdomain")
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "emoji-archiver",
 	Short: "A tool to bulk import and export slack emojis",
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		subdomain = defaultExportName(platformName, subdomain)
 		logger := utilities.NewLogger(logLevel,
 			"subdomain", subdomain,
 			"root-directory", directory,
 		)
-		cmd.SetContext(utilities.ToContext(cmd.Context(), logger))
+		ctx := utilities.ToContext(cmd.Context(), logger)
+		if timeout > 0 {
+			ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		}
+		cmd.SetContext(ctx)
 	},
 }
 
 func Execute() {
-	err := rootCmd.Execute()
+	// the first ctrl-c cancels the context so work can wind down cleanly,
+	// after that signals go back to their default behaviour
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if cancelTimeout != nil {
+		cancelTimeout()
+	}
 	if err != nil {
 		os.Exit(1)
 	}
@@ -35,17 +66,49 @@ func Execute() {
 
 func init() {
 	initConfig()
-	rootCmd.PersistentFlags().StringVarP(&subdomain, "subdomain", "s", utilities.ConfigOrEnv("slack", "subdomain"), "what subdomain to pull a slack token for")
+	rootCmd.PersistentFlags().StringVarP(&subdomain, "subdomain", "s", utilities.ConfigOrEnv("slack", "subdomain"), "what subdomain to pull a slack token for, on other platforms the name of the export (defaults to the server's host or guild id)")
+	rootCmd.PersistentFlags().StringVar(&platformName, "platform", lo.CoalesceOrEmpty(utilities.ConfigOrEnv("emoji", "platform"), platform.Slack), "chat platform to export from or import into: slack, mattermost, discord, rocketchat or zulip")
+	rootCmd.PersistentFlags().StringVar(&mattermostURL, "mattermost-url", utilities.ConfigOrEnv("mattermost", "url"), "url of the mattermost server for --platform mattermost")
+	rootCmd.PersistentFlags().StringVar(&mattermostToken, "mattermost-token", utilities.ConfigOrEnv("mattermost", "token"), "personal access token for --platform mattermost")
+	rootCmd.PersistentFlags().StringVar(&discordGuild, "discord-guild", utilities.ConfigOrEnv("discord", "guild"), "id of the guild (server) for --platform discord")
+	rootCmd.PersistentFlags().StringVar(&discordToken, "discord-token", utilities.ConfigOrEnv("discord", "token"), "bot token for --platform discord, the bot needs the Manage Expressions permission")
+	rootCmd.PersistentFlags().StringVar(&rocketchatURL, "rocketchat-url", utilities.ConfigOrEnv("rocketchat", "url"), "url of the rocket.chat server for --platform rocketchat")
+	rootCmd.PersistentFlags().StringVar(&rocketchatUserID, "rocketchat-user-id", utilities.ConfigOrEnv("rocketchat", "user_id"), "id of the user the --rocketchat-token belongs to")
+	rootCmd.PersistentFlags().StringVar(&rocketchatToken, "rocketchat-token", utilities.ConfigOrEnv("rocketchat", "token"), "personal access token for --platform rocketchat")
+	rootCmd.PersistentFlags().StringVar(&zulipURL, "zulip-url", utilities.ConfigOrEnv("zulip", "url"), "url of the zulip organization for --platform zulip")
+	rootCmd.PersistentFlags().StringVar(&zulipEmail, "zulip-email", utilities.ConfigOrEnv("zulip", "email"), "email of the bot or user the --zulip-api-key belongs to")
+	rootCmd.PersistentFlags().StringVar(&zulipAPIKey, "zulip-api-key", utilities.ConfigOrEnv("zulip", "api_key"), "api key for --platform zulip")
 	rootCmd.PersistentFlags().StringVarP(&directory, "directory", "d", "./emojis/", "base directory to use")
 	rootCmd.PersistentFlags().StringVarP(&logLevel, "log-level", "l", "info", "log-level to use")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "stop the command after this long, e.g. 30m (no limit by default)")
 	rootCmd.PersistentFlags().StringVarP(&browser, "browser", "b", utilities.ConfigOrEnv("slack", "browser"), "browser to look for token")
 	rootCmd.PersistentFlags().StringVarP(&profile, "profile", "p", utilities.ConfigOrEnv("slack", "profile"), "profile to look for token")
+	rootCmd.PersistentFlags().StringVar(&credentialSource, "credentials", utilities.ConfigOrEnv("slack", "credentials"), "where to get slack tokens from: browser (default), token, file, or keyring")
+	rootCmd.PersistentFlags().StringVar(&xoxd, "xoxd", utilities.ConfigOrEnv("slack", "xoxd"), "xoxd token (the d cookie) to use with --credentials token")
+	rootCmd.PersistentFlags().StringVar(&xoxc, "xoxc", utilities.ConfigOrEnv("slack", "xoxc"), "xoxc token to use with --credentials token, requested with the xoxd token if not set")
+	rootCmd.PersistentFlags().StringVar(&tokenFile, "token-file", utilities.ConfigOrEnv("slack", "token_file"), "json file of tokens keyed by subdomain to use with --credentials file")
+	rootCmd.PersistentFlags().StringVar(&apiURL, "api-url", utilities.ConfigOrEnv("slack", "api_url"), "base url for the slack web api, defaults to https://slack.com/api")
+	rootCmd.PersistentFlags().StringVar(&workspaceURL, "workspace-url", utilities.ConfigOrEnv("slack", "workspace_url"), "workspace url, %s is replaced with the subdomain, defaults to https://%s.slack.com")
+	rootCmd.PersistentFlags().StringVar(&proxyURL, "proxy", networkSetting("proxy", "proxy"), "proxy url for requests to every platform, HTTPS_PROXY is used if unset")
+	rootCmd.PersistentFlags().StringVar(&userAgent, "user-agent", networkSetting("user_agent", "user_agent"), "user agent to send with requests to every platform")
+	rootCmd.PersistentFlags().DurationVar(&httpTimeout, "http-timeout", configDuration(networkSetting("timeout", "http_timeout"), time.Minute), "timeout for each request to any platform")
+	rootCmd.PersistentFlags().StringToIntVar(&rateLimits, "rate-limit", nil, "override requests per minute for a slack method, e.g. emoji.add=20 (0 disables the limit)")
+	rootCmd.PersistentFlags().IntVar(&retries, "retries", -1, "how many times to retry throttled or failed requests to any platform, defaults to 5")
+	rootCmd.PersistentFlags().StringVar(&s3Endpoint, "s3-endpoint", utilities.ConfigOrEnv("s3", "endpoint"), "url of an s3 compatible store like minio, defaults to aws")
+	rootCmd.PersistentFlags().StringVar(&s3Region, "s3-region", lo.CoalesceOrEmpty(utilities.ConfigOrEnv("s3", "region"), os.Getenv("AWS_REGION")), "s3 region, defaults to us-east-1")
+	rootCmd.PersistentFlags().StringVar(&s3Bucket, "s3-bucket", utilities.ConfigOrEnv("s3", "bucket"), "bucket for --format s3 exports")
+	rootCmd.PersistentFlags().StringVar(&s3Prefix, "s3-prefix", utilities.ConfigOrEnv("s3", "prefix"), "prefix for --format s3 exports, the subdomain is added to it")
 	// releaseNotes channel is entered here since it has to be post initConfig for ConfigOrEnv to work, but calling
 	// initConfig multiple times causes a panic
 	releaseNotesCmd.Flags().StringVarP(&channel, "channel", "c", utilities.ConfigOrEnv("slack", "channel"), "channel to post to")
 
 }
 
+// networkSetting reads a key from the network section of the config, or the slack section it used to be in
+func networkSetting(key, slackKey string) string {
+	return lo.CoalesceOrEmpty(utilities.ConfigOrEnv("network", key), utilities.ConfigOrEnv("slack", slackKey))
+}
+
 // initConfig reads in config file
 func initConfig() {
 	viper.SetConfigName(".config")
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/gallery"
+	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+	"github.com/spf13/cobra"
+)
+
+var serveAddr string
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:           "serve",
+	Short:         "Browse an export directory in a local web gallery",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := utilities.ContextLogger(cmd.Context())
+		exportDir := path.Join(directory, subdomain)
+		if _, err := os.Stat(exportDir); err != nil {
+			logger.Error("unable to read export directory", "error", err)
+			return err
+		}
+
+		handler, err := gallery.New(cmd.Context(), exportDir)
+		if err != nil {
+			logger.Error("unable to load gallery", "error", err)
+			return err
+		}
+
+		listener, err := net.Listen("tcp", serveAddr)
+		if err != nil {
+			logger.Error("unable to listen", "error", err, "addr", serveAddr)
+			return err
+		}
+		server := &http.Server{
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		// shut down once ctrl-c cancels the context
+		go func() {
+			<-cmd.Context().Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(ctx)
+		}()
+
+		logger.Info("serving gallery", "url", "http://"+listener.Addr().String(), "dir", exportDir)
+		if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("server failed", "error", err)
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:8080", "address to serve the gallery on")
+}
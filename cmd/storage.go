@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/cache"
+	"github.com/erindatkinson/emoji-archiver/internal/emojipack"
+	"github.com/erindatkinson/emoji-archiver/internal/images"
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+	"github.com/erindatkinson/emoji-archiver/internal/storage"
+	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+	"github.com/gammazero/workerpool"
+	"github.com/samber/lo"
+)
+
+/*
+exportToStorage
+
+Streams emoji from slack straight into a storage writer on a pool of
+--concurrency workers, then writes a manifest of what was stored next to
+them. Emoji already in existing are left alone and keep whatever entry the
+manifest had. The manifest is written even when the export is interrupted
+so the emoji stored so far are accounted for.
+*/
+func exportToStorage(ctx context.Context, logger *slog.Logger, client platform.Backend, emoji []slack.Emoji, store storage.Writer, manifest *cache.Manifest, existing map[string]bool) (int64, error) {
+	var failed atomic.Int64
+	wp := workerpool.New(max(1, concurrency))
+	for _, request := range emoji {
+		if ctx.Err() != nil {
+			break
+		}
+		wp.Submit(func() {
+			loopLog := logger.With("name", request.Name)
+			if ctx.Err() != nil {
+				return
+			}
+			if request.IsAlias == 1 {
+				loopLog.Debug("recording alias", "alias_for", request.AliasFor)
+				manifest.RecordAlias(request)
+				return
+			}
+			if existing[request.Name] {
+				loopLog.Debug("already stored, skipping")
+				return
+			}
+
+			loopLog.Debug("exporting emoji")
+			data, filename, err := downloadEmoji(ctx, client, request)
+			if err == nil {
+				err = store.Put(ctx, filename, data)
+			}
+			if interrupted(ctx, err) {
+				loopLog.Debug("export interrupted")
+				return
+			} else if err != nil {
+				loopLog.Error("error exporting", "error", err)
+				failed.Add(1)
+				return
+			}
+			manifest.RecordData(request, filename, data)
+		})
+	}
+	wp.StopWait()
+
+	buf := new(bytes.Buffer)
+	if err := manifest.Encode(buf); err != nil {
+		return failed.Load(), err
+	}
+	return failed.Load(), store.Put(context.WithoutCancel(ctx), cache.ManifestFilename, buf.Bytes())
+}
+
+// downloadEmoji reads an emoji's image into memory, checking it decodes before it's stored anywhere
+func downloadEmoji(ctx context.Context, client platform.Backend, emoji slack.Emoji) ([]byte, string, error) {
+	body, filename, err := client.DownloadEmoji(ctx, emoji)
+	if err != nil {
+		return nil, "", err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := images.Verify(data); err != nil {
+		return nil, "", errors.Join(fmt.Errorf("downloaded image for %s is corrupt", emoji.Name), err)
+	}
+	return data, filename, nil
+}
+
+/*
+exportToS3
+
+Exports into an s3 bucket. The bucket's listing and manifest stand in for
+the export directory: emoji already stored are skipped unless they've
+changed since or --force is set.
+*/
+func exportToS3(ctx context.Context, logger *slog.Logger, client platform.Backend, emoji []slack.Emoji, uri string) (int64, error) {
+	store, err := newS3Store(uri)
+	if err != nil {
+		return 0, err
+	}
+	names, err := store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	manifest := cache.NewManifest()
+	if slices.Contains(names, cache.ManifestFilename) {
+		data, err := store.Get(ctx, cache.ManifestFilename)
+		if err != nil {
+			return 0, err
+		}
+		if manifest, err = cache.DecodeManifest(bytes.NewReader(data)); err != nil {
+			return 0, err
+		}
+	}
+
+	stored := make(map[string]bool)
+	for _, name := range names {
+		if !cache.IsMetadataFile(path.Base(name)) {
+			stored[strings.Split(path.Base(name), ".")[0]] = true
+		}
+	}
+	existing := make(map[string]bool)
+	for _, e := range emoji {
+		changed, _ := manifest.Changed(e)
+		existing[e.Name] = stored[e.Name] && !changed && !exportForce
+	}
+	logger.Info("found emoji already in the bucket", "count", len(stored))
+	return exportToStorage(ctx, logger, client, emoji, store, manifest, existing)
+}
+
+/*
+newS3Store
+
+Connects to the bucket and prefix in an s3://bucket/prefix url, or when
+it's empty the configured bucket under <s3-prefix>/<subdomain>.
+Credentials come from the s3 config section or the usual AWS_ env vars.
+*/
+func newS3Store(uri string) (*storage.S3, error) {
+	bucket, prefix, ok := storage.ParseS3URL(uri)
+	if !ok {
+		if uri != "" {
+			return nil, fmt.Errorf("%q isn't an s3://bucket/prefix url", uri)
+		}
+		bucket, prefix = s3Bucket, path.Join(s3Prefix, subdomain)
+	}
+	httpClient, err := newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	return storage.NewS3(storage.S3Config{
+		Endpoint:     s3Endpoint,
+		Region:       s3Region,
+		Bucket:       bucket,
+		Prefix:       prefix,
+		AccessKey:    lo.CoalesceOrEmpty(utilities.ConfigOrEnv("s3", "access_key_id"), os.Getenv("AWS_ACCESS_KEY_ID")),
+		SecretKey:    lo.CoalesceOrEmpty(utilities.ConfigOrEnv("s3", "secret_access_key"), os.Getenv("AWS_SECRET_ACCESS_KEY")),
+		SessionToken: lo.CoalesceOrEmpty(utilities.ConfigOrEnv("s3", "session_token"), os.Getenv("AWS_SESSION_TOKEN")),
+	}, httpClient)
+}
+
+/*
+importSource
+
+Returns the directory to import from. When --from names an archive, an
+s3://bucket/prefix url or an emoji pack its files are copied into a temp
+directory first, and cleanup removes it again.
+*/
+func importSource(ctx context.Context, logger *slog.Logger, from, defaultDir string) (string, func(), error) {
+	if from == "" {
+		return defaultDir, func() {}, nil
+	}
+	if emojipack.IsPack(from) {
+		return importPackSource(ctx, logger, from)
+	}
+
+	var reader storage.Reader
+	var err error
+	if _, _, ok := storage.ParseS3URL(from); ok {
+		reader, err = newS3Store(from)
+	} else {
+		reader, err = storage.OpenArchive(from)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	dir, err := os.MkdirTemp("", "emoji-archiver-import-*")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() {
+		os.RemoveAll(dir)
+	}
+
+	count, err := storage.Extract(ctx, reader, dir)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	logger.Info("read emoji to import", "from", from, "files", count)
+	return dir, cleanup, nil
+}
+
+// importPackSource fetches an emoji pack into a temp directory
+func importPackSource(ctx context.Context, logger *slog.Logger, from string) (string, func(), error) {
+	dir, err := os.MkdirTemp("", "emoji-archiver-import-*")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() {
+		os.RemoveAll(dir)
+	}
+
+	count, err := extractPack(ctx, logger, from, dir)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	logger.Info("read emoji to import", "from", from, "files", count)
+	return dir, cleanup, nil
+}
+
+var s3Endpoint, s3Region, s3Bucket, s3Prefix string
+
+// archivePath is the default file an archive export is written to
+func archivePath(subdomain, format string) string {
+	return filepath.Join(directory, fmt.Sprintf("%s-%s.%s", subdomain, time.Now().Format("20060102-150405"), format))
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+	"github.com/spf13/cobra"
+)
+
+// storeCredentialsCmd represents the store-credentials command
+var storeCredentialsCmd = &cobra.Command{
+	Use:   "store-credentials",
+	Short: "Save the tokens for a slack subdomain into the OS keyring for use with --credentials keyring",
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := utilities.ContextLogger(cmd.Context())
+		if subdomain == "" {
+			logger.Error("error reading configs from env, config, or flags")
+			return
+		}
+		if credentialSource == credentialsKeyring {
+			logger.Error("pick a different --credentials source to read the tokens to store from")
+			return
+		}
+
+		provider, err := credentialProvider(browser, profile)
+		if err != nil {
+			logger.Error("unable to set up credentials", "error", err)
+			return
+		}
+		creds, err := provider.Credentials(cmd.Context(), subdomain)
+		if err != nil {
+			logger.Error("unable to read credentials", "error", err)
+			return
+		}
+
+		if err := slack.SaveToKeyring(subdomain, creds); err != nil {
+			logger.Error("unable to save credentials to keyring", "error", err)
+			return
+		}
+		logger.Info("saved credentials to keyring", "service", slack.KeyringService)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(storeCredentialsCmd)
+}
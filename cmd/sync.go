@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncFrom, syncFromBrowser, syncFromProfile string
+	syncTo, syncToBrowser, syncToProfile       string
+	syncInclude, syncExclude                   []string
+	syncDryRun                                 bool
+	syncFromPlatform, syncToPlatform           string
+)
+
+// syncCmd represents the sync command
+var syncCmd = &cobra.Command{
+	Use:           "sync",
+	Short:         "Copy emoji that are missing from one slack team into another",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		syncFromPlatform = lo.CoalesceOrEmpty(syncFromPlatform, platformName)
+		syncToPlatform = lo.CoalesceOrEmpty(syncToPlatform, platformName)
+		syncFrom = defaultExportName(syncFromPlatform, lo.CoalesceOrEmpty(syncFrom, subdomain))
+		syncTo = defaultExportName(syncToPlatform, syncTo)
+		logger := utilities.ContextLogger(cmd.Context()).With("from", syncFrom, "to", syncTo)
+		if syncFrom == "" || syncTo == "" {
+			logger.Error("both a source and destination subdomain are required")
+			return errMissingConfig
+		}
+		if syncToPlatform == platform.Discord {
+			// discord's names and slots have to be planned, which import does from an export
+			logger.Error("emoji can't be synced into discord, export them and import the export instead")
+			return errors.New("discord isn't supported as a sync destination")
+		}
+		if err := validatePatterns(append(syncInclude, syncExclude...)); err != nil {
+			logger.Error("invalid pattern", "error", err)
+			return err
+		}
+
+		source, err := newBackend(cmd.Context(), syncFromPlatform, syncFrom, lo.CoalesceOrEmpty(syncFromBrowser, browser), lo.CoalesceOrEmpty(syncFromProfile, profile))
+		if err != nil {
+			logger.Error("unable to create source client", "error", err, "platform", syncFromPlatform)
+			return err
+		}
+		destination, err := newBackend(cmd.Context(), syncToPlatform, syncTo, lo.CoalesceOrEmpty(syncToBrowser, browser), lo.CoalesceOrEmpty(syncToProfile, profile))
+		if err != nil {
+			logger.Error("unable to create destination client", "error", err, "platform", syncToPlatform)
+			return err
+		}
+
+		logger.Info("listing source emoji")
+		sourceEmoji, err := source.ListEmoji(cmd.Context())
+		if err != nil {
+			logger.Error("unable to list source emoji", "error", err)
+			return err
+		}
+		logger.Info("listing destination emoji")
+		destinationEmoji, err := destination.ListEmoji(cmd.Context())
+		if err != nil {
+			logger.Error("unable to list destination emoji", "error", err)
+			return err
+		}
+
+		uploads, aliases, orphans := planSync(sourceEmoji, destinationEmoji)
+		logger.Info("planned sync", "uploads", len(uploads), "aliases", len(aliases), "skipped_aliases", len(orphans))
+		if syncDryRun {
+			renderSyncPlan(os.Stdout, uploads, aliases, orphans)
+			return nil
+		}
+
+		results := &emojiResults{}
+		for _, alias := range orphans {
+			results.add(alias.Name, resultSkipped, orphanReason(alias))
+		}
+		for _, emoji := range uploads {
+			if cmd.Context().Err() != nil {
+				results.add(emoji.Name, resultSkipped, "sync interrupted")
+				continue
+			}
+			if err := copyEmoji(cmd.Context(), logger, source, destination, emoji); interrupted(cmd.Context(), err) {
+				results.add(emoji.Name, resultSkipped, "sync interrupted")
+				continue
+			} else if err != nil {
+				logger.Error("unable to copy emoji", "error", err, "emoji", emoji.Name)
+				results.add(emoji.Name, resultFailed, err.Error())
+				continue
+			}
+			results.add(emoji.Name, resultSucceeded, "")
+		}
+
+		// aliases go last so that the emoji they point at already exist
+		for _, alias := range aliases {
+			if cmd.Context().Err() != nil {
+				results.add(alias.Name, resultSkipped, "sync interrupted")
+				continue
+			}
+			if err := destination.AddAlias(cmd.Context(), alias.Name, alias.AliasFor); interrupted(cmd.Context(), err) {
+				results.add(alias.Name, resultSkipped, "sync interrupted")
+				continue
+			} else if err != nil {
+				logger.Error("unable to create alias", "error", err, "emoji", alias.Name, "alias_for", alias.AliasFor)
+				results.add(alias.Name, resultFailed, err.Error())
+				continue
+			}
+			results.add(alias.Name, resultSucceeded, "alias for "+alias.AliasFor)
+		}
+
+		results.render(os.Stdout)
+		if err := cmd.Context().Err(); err != nil {
+			logger.Error("sync interrupted", "error", err)
+			return err
+		}
+		if failed := results.count(resultFailed); failed > 0 {
+			return fmt.Errorf("%d emoji failed to sync", failed)
+		}
+		return nil
+	},
+}
+
+/*
+planSync
+
+Works out which source emoji are missing from the destination and pass the
+include/exclude patterns, split into images to upload and aliases to
+create once the uploads are done. Aliases whose target is neither being
+uploaded nor already in the destination are returned separately, creating
+them would only fail.
+*/
+func planSync(source, destination []slack.Emoji) ([]slack.Emoji, []slack.Emoji, []slack.Emoji) {
+	existing := lo.SliceToMap(destination, func(emoji slack.Emoji) (string, bool) {
+		return emoji.Name, true
+	})
+
+	missing := lo.Filter(source, func(emoji slack.Emoji, index int) bool {
+		return !existing[emoji.Name] && matchesPatterns(emoji.Name, syncInclude, syncExclude)
+	})
+
+	aliases, uploads := lo.FilterReject(missing, func(emoji slack.Emoji, index int) bool {
+		return emoji.IsAlias == 1
+	})
+	uploading := lo.SliceToMap(uploads, func(emoji slack.Emoji) (string, bool) {
+		return emoji.Name, true
+	})
+	aliases, orphans := lo.FilterReject(aliases, func(alias slack.Emoji, index int) bool {
+		return uploading[alias.AliasFor] || existing[alias.AliasFor]
+	})
+	return uploads, aliases, orphans
+}
+
+// matchesPatterns reports whether name matches an include pattern (or there are none) and no exclude patterns
+func matchesPatterns(name string, include, exclude []string) bool {
+	for _, pattern := range exclude {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+	if len(include) == 0 {
+		return true
+	}
+	for _, pattern := range include {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func validatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Join(fmt.Errorf("bad pattern %q", pattern), err)
+		}
+	}
+	return nil
+}
+
+// copyEmoji streams an emoji's image from the source team into the destination team
+func copyEmoji(ctx context.Context, logger *slog.Logger, source, destination platform.Backend, emoji slack.Emoji) error {
+	logger.Debug("copying emoji", "emoji", emoji.Name)
+	body, filename, err := source.DownloadEmoji(ctx, emoji)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	return destination.UploadEmoji(ctx, emoji.Name, filename, data)
+}
+
+func renderSyncPlan(w io.Writer, uploads, aliases, orphans []slack.Emoji) {
+	t := table.NewWriter()
+	t.SetStyle(table.StyleRounded)
+	t.SetOutputMirror(w)
+	t.AppendHeader(table.Row{"Emoji", "Action", "Detail"})
+	for _, emoji := range uploads {
+		t.AppendRow(table.Row{emoji.Name, "upload", "uploaded by " + emoji.UserDisplayName})
+	}
+	for _, alias := range aliases {
+		t.AppendRow(table.Row{alias.Name, "alias", "alias for " + alias.AliasFor})
+	}
+	for _, alias := range orphans {
+		t.AppendRow(table.Row{alias.Name, "skip", orphanReason(alias)})
+	}
+	t.AppendFooter(table.Row{"", "Total", fmt.Sprintf("%d uploads, %d aliases, %d skipped", len(uploads), len(aliases), len(orphans))})
+	t.Render()
+}
+
+func orphanReason(alias slack.Emoji) string {
+	return fmt.Sprintf("alias for %s, which isn't being synced", alias.AliasFor)
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	syncCmd.Flags().StringVar(&syncFrom, "from", "", "subdomain to copy emoji from, defaults to --subdomain")
+	syncCmd.Flags().StringVar(&syncFromBrowser, "from-browser", "", "browser to look for the source token in, defaults to --browser")
+	syncCmd.Flags().StringVar(&syncFromProfile, "from-profile", "", "profile to look for the source token in, defaults to --profile")
+	syncCmd.Flags().StringVar(&syncTo, "to", "", "subdomain to copy emoji into")
+	syncCmd.Flags().StringVar(&syncFromPlatform, "from-platform", "", "platform to copy emoji from, defaults to --platform")
+	syncCmd.Flags().StringVar(&syncToPlatform, "to-platform", "", "platform to copy emoji into, defaults to --platform")
+	syncCmd.Flags().StringVar(&syncToBrowser, "to-browser", "", "browser to look for the destination token in, defaults to --browser")
+	syncCmd.Flags().StringVar(&syncToProfile, "to-profile", "", "profile to look for the destination token in, defaults to --profile")
+	syncCmd.Flags().StringSliceVar(&syncInclude, "include", nil, "only sync emoji with names matching these glob patterns")
+	syncCmd.Flags().StringSliceVar(&syncExclude, "exclude", nil, "don't sync emoji with names matching these glob patterns")
+	syncCmd.Flags().BoolVar(&syncDryRun, "dry-run", false, "print the plan without copying anything")
+}
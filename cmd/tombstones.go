@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/cache"
+	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+)
+
+var tombstonesSince string
+
+// tombstonesCmd represents the tombstones command
+var tombstonesCmd = &cobra.Command{
+	Use:           "tombstones",
+	Short:         "List emoji that exports have seen removed from slack",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := utilities.ContextLogger(cmd.Context())
+		exportDir := path.Join(directory, subdomain)
+
+		manifest, err := cache.LoadManifest(exportDir)
+		if err != nil {
+			logger.Error("unable to load manifest", "error", err)
+			return err
+		}
+		since, err := parseSince(tombstonesSince, manifest)
+		if err != nil {
+			logger.Error("invalid --since", "error", err)
+			return err
+		}
+
+		tombstones, err := cache.LoadTombstones(exportDir)
+		if err != nil {
+			logger.Error("unable to read tombstones", "error", err)
+			return err
+		}
+		tombstones = lo.Filter(tombstones, func(item cache.ManifestEntry, index int) bool {
+			return item.RemovedAt >= since
+		})
+		renderTombstones(os.Stdout, tombstones)
+		return nil
+	},
+}
+
+func renderTombstones(w io.Writer, tombstones []cache.ManifestEntry) {
+	t := table.NewWriter()
+	t.SetStyle(table.StyleRounded)
+	t.SetOutputMirror(w)
+	t.AppendHeader(table.Row{"Emoji", "Removed", "Last Seen", "Uploaded By", "File"})
+	for _, tombstone := range tombstones {
+		file := tombstone.Filename
+		if tombstone.IsAlias {
+			file = "alias for " + tombstone.AliasFor
+		}
+		t.AppendRow(table.Row{
+			tombstone.Name,
+			formatUnix(tombstone.RemovedAt),
+			formatUnix(tombstone.LastSeen),
+			tombstone.UserDisplayName,
+			file,
+		})
+	}
+	t.AppendFooter(table.Row{"", "", "", "Total", fmt.Sprintf("%d removed", len(tombstones))})
+	t.Render()
+}
+
+// formatUnix formats a unix timestamp for tables, leaving unknown (zero) times blank
+func formatUnix(timestamp int64) string {
+	if timestamp == 0 {
+		return ""
+	}
+	return time.Unix(timestamp, 0).Format(time.DateTime)
+}
+
+func init() {
+	rootCmd.AddCommand(tombstonesCmd)
+	tombstonesCmd.Flags().StringVar(&tombstonesSince, "since", "", "only list emoji removed since a date (2006-01-02 or RFC3339) or a duration ago (e.g. 720h)")
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/erindatkinson/emoji-archiver/internal/cache"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+)
+
+var verifyDryRun bool
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:           "verify",
+	Short:         "Check the export directory for corrupt or missing emoji and download them again",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := utilities.ContextLogger(cmd.Context())
+		exportDir := path.Join(directory, subdomain)
+
+		removed, err := cache.RemoveTempFiles(exportDir)
+		if err != nil {
+			logger.Error("unable to remove temp files", "error", err)
+			return err
+		}
+		logger.Debug("removed temp files", "count", removed)
+
+		manifest, err := cache.LoadManifest(exportDir)
+		if err != nil {
+			logger.Error("unable to load manifest", "error", err)
+			return err
+		}
+
+		logger.Info("verifying downloaded emoji")
+		problems, err := cache.VerifyDownloaded(exportDir, manifest)
+		if err != nil {
+			logger.Error("unable to verify emoji", "error", err)
+			return err
+		}
+		logger.Info("found problems", "count", len(problems))
+
+		results := &emojiResults{}
+		if verifyDryRun || len(problems) == 0 {
+			for _, problem := range problems {
+				results.add(problem.Name, resultSkipped, problem.Reason)
+			}
+			results.render(os.Stdout)
+			return nil
+		}
+
+		client, err := newBackend(cmd.Context(), platformName, subdomain, browser, profile)
+		if err != nil {
+			logger.Error("unable to create client", "error", err, "platform", platformName)
+			return err
+		}
+		currentEmoji, err := client.ListEmoji(cmd.Context())
+		if err != nil {
+			logger.Error("error retrieving current emoji list", "error", err)
+			return err
+		}
+		live := lo.KeyBy(currentEmoji, func(emoji slack.Emoji) string {
+			return emoji.Name
+		})
+
+		for _, problem := range problems {
+			loopLog := logger.With("name", problem.Name, "problem", problem.Reason)
+			emoji, ok := live[problem.Name]
+			if !ok || emoji.IsAlias == 1 {
+				loopLog.Warn("emoji is no longer in slack, unable to download again")
+				results.add(problem.Name, resultFailed, problem.Reason+", no longer in slack")
+				continue
+			}
+
+			loopLog.Info("downloading again")
+			filename, err := client.ExportEmoji(cmd.Context(), emoji, exportDir)
+			if err != nil {
+				loopLog.Error("unable to download again", "error", err)
+				results.add(problem.Name, resultFailed, err.Error())
+				continue
+			}
+			if filename != problem.Filename && !problem.Missing {
+				// the emoji has changed format since it was first downloaded
+				os.Remove(filepath.Join(exportDir, problem.Filename))
+			}
+			if err := manifest.Record(emoji, exportDir, filename, true); err != nil {
+				loopLog.Error("error updating manifest", "error", err)
+			}
+			results.add(problem.Name, resultSucceeded, "downloaded again, "+problem.Reason)
+		}
+
+		if err := manifest.Save(exportDir); err != nil {
+			logger.Error("unable to write manifest", "error", err)
+			return err
+		}
+
+		results.render(os.Stdout)
+		if failed := results.count(resultFailed); failed > 0 {
+			return fmt.Errorf("%d emoji could not be repaired", failed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	verifyCmd.Flags().BoolVar(&verifyDryRun, "dry-run", false, "only report problems, don't download anything")
+}
@@ -0,0 +1,72 @@
+/*
+Package apitest holds what the in memory platform test servers and the
+tests run against them share, so each server only has to speak its
+platform's wire format.
+*/
+package apitest
+
+import (
+	"net/http"
+	"sync"
+)
+
+/*
+Faults
+
+Queues faults for a test server's endpoints and counts the requests each
+endpoint receives. Servers embed it and set Write to send a fault the way
+their platform would.
+*/
+type Faults[F any] struct {
+	// Write sends a fault as the response to a request
+	Write func(w http.ResponseWriter, fault F)
+
+	mu       sync.Mutex
+	queued   map[string][]F
+	requests map[string]int
+}
+
+// InjectFault queues a fault for the next request to the endpoint
+func (f *Faults[F]) InjectFault(endpoint string, fault F) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.queued == nil {
+		f.queued = make(map[string][]F)
+	}
+	f.queued[endpoint] = append(f.queued[endpoint], fault)
+}
+
+// Requests returns how many requests an endpoint has received, including faulted ones
+func (f *Faults[F]) Requests(endpoint string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[endpoint]
+}
+
+// Faulted counts a request to the endpoint and writes the fault queued for it, if there is one
+func (f *Faults[F]) Faulted(w http.ResponseWriter, endpoint string) bool {
+	fault, ok := f.next(endpoint)
+	if ok {
+		f.Write(w, fault)
+	}
+	return ok
+}
+
+//========== Private Methods ==========
+
+func (f *Faults[F]) next(endpoint string) (F, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.requests == nil {
+		f.requests = make(map[string]int)
+	}
+	f.requests[endpoint]++
+	var fault F
+	queue := f.queued[endpoint]
+	if len(queue) == 0 {
+		return fault, false
+	}
+	fault = queue[0]
+	f.queued[endpoint] = queue[1:]
+	return fault, true
+}
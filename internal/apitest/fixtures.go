@@ -0,0 +1,75 @@
+package apitest
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
+	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+	"github.com/stretchr/testify/require"
+)
+
+// Backoff keeps the retries of clients under test quick
+const (
+	BackoffBase = time.Millisecond
+	BackoffMax  = 10 * time.Millisecond
+)
+
+// Server is a test server a platform client of type C can connect to
+type Server[C any] interface {
+	Close()
+	// Connect creates a client pointed at the server with opts
+	Connect(ctx context.Context, opts ...platform.Option) (C, error)
+}
+
+/*
+NewClient
+
+Connects a client to a test server, logging only errors and retrying
+quickly, with opts applied last. The server is closed when the test
+finishes.
+*/
+func NewClient[C any](t testing.TB, server Server[C], opts ...platform.Option) C {
+	t.Cleanup(server.Close)
+	opts = append([]platform.Option{platform.WithBackoff(BackoffBase, BackoffMax)}, opts...)
+	client, err := server.Connect(Context(), opts...)
+	require.Nil(t, err)
+	return client
+}
+
+// Context carries a logger that only logs errors, so test output stays quiet
+func Context() context.Context {
+	return utilities.ToContext(context.Background(), utilities.NewLogger("error"))
+}
+
+// PNG is a blank 16x16 png
+func PNG(t testing.TB) []byte {
+	buf := new(bytes.Buffer)
+	require.Nil(t, png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 16, 16))))
+	return buf.Bytes()
+}
+
+// GIF is a blank 16x16 gif with a single frame
+func GIF(t testing.TB) []byte {
+	buf := new(bytes.Buffer)
+	require.Nil(t, gif.Encode(buf, blankFrame(), nil))
+	return buf.Bytes()
+}
+
+// AnimatedGIF is a blank 16x16 gif with two frames
+func AnimatedGIF(t testing.TB) []byte {
+	buf := new(bytes.Buffer)
+	frame := blankFrame()
+	require.Nil(t, gif.EncodeAll(buf, &gif.GIF{Image: []*image.Paletted{frame, frame}, Delay: []int{10, 10}}))
+	return buf.Bytes()
+}
+
+func blankFrame() *image.Paletted {
+	return image.NewPaletted(image.Rect(0, 0, 16, 16), color.Palette{color.Black, color.White})
+}
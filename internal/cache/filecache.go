@@ -9,13 +9,23 @@ import (
 	"strings"
 )
 
+/*
+ListDownloadedEmojis
+
+Walks an export directory for emoji images. Directories starting with an
+underscore (like _removed) or a dot hold the archive's own bookkeeping and
+are skipped.
+*/
 func ListDownloadedEmojis(emojiDir string) (emojis []EmojiItem, err error) {
 	emojis = make([]EmojiItem, 0)
 	err = filepath.WalkDir(emojiDir, func(fPath string, d fs.DirEntry, err error) error {
 		if fPath == emojiDir {
 			return nil
 		}
-		if d.Name() == ".DS_Store" {
+		if d.IsDir() && (strings.HasPrefix(d.Name(), "_") || strings.HasPrefix(d.Name(), ".")) {
+			return fs.SkipDir
+		}
+		if IsMetadataFile(d.Name()) {
 			return nil
 		}
 		if d.IsDir() {
@@ -41,6 +51,12 @@ func ListDownloadedEmojis(emojiDir string) (emojis []EmojiItem, err error) {
 	return
 }
 
+// IsMetadataFile reports whether a file in an export directory is bookkeeping
+// rather than an emoji image
+func IsMetadataFile(name string) bool {
+	return strings.HasPrefix(name, ".") || name == ManifestFilename || name == TombstonesFilename
+}
+
 func PaginateEmojiList(list []EmojiItem, docsDir string) []*EmojiPage {
 	pages := []*EmojiPage{}
 	count := 0
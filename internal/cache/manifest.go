@@ -0,0 +1,256 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+)
+
+const (
+	// ManifestVersion is bumped whenever the manifest layout changes in a way
+	// older binaries can't read
+	ManifestVersion = 1
+	// ManifestFilename is the name of the manifest inside an export directory
+	ManifestFilename = "manifest.json"
+)
+
+/*
+LoadManifest
+
+Reads the manifest from the given export directory, returning an empty
+manifest if one hasn't been written yet
+*/
+func LoadManifest(dir string) (*Manifest, error) {
+	fp, err := os.Open(filepath.Join(dir, ManifestFilename))
+	if errors.Is(err, fs.ErrNotExist) {
+		return NewManifest(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	return DecodeManifest(fp)
+}
+
+// NewManifest returns an empty manifest at the current version
+func NewManifest() *Manifest {
+	return &Manifest{
+		Version: ManifestVersion,
+		Emoji:   make(map[string]ManifestEntry),
+	}
+}
+
+// DecodeManifest reads a manifest written by Encode
+func DecodeManifest(r io.Reader) (*Manifest, error) {
+	manifest := &Manifest{}
+	if err := json.NewDecoder(r).Decode(manifest); err != nil {
+		return nil, errors.Join(fmt.Errorf("unable to parse manifest"), err)
+	}
+	if manifest.Version > ManifestVersion {
+		return nil, fmt.Errorf("manifest version %d is newer than supported version %d", manifest.Version, ManifestVersion)
+	}
+	if manifest.Emoji == nil {
+		manifest.Emoji = make(map[string]ManifestEntry)
+	}
+	manifest.Version = ManifestVersion
+	return manifest, nil
+}
+
+// Get returns the entry for the named emoji if there is one
+func (m *Manifest) Get(name string) (ManifestEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.Emoji[name]
+	return entry, ok
+}
+
+/*
+Record
+
+Stores the metadata for an emoji along with the size and hash of its
+file. The filename is relative to the export directory dir. A file that
+wasn't just downloaded reuses the existing entry's hash when its size
+hasn't changed, so repeated exports don't rehash the whole archive. A
+downloaded file is always hashed, since a replaced image keeps its
+filename and can easily keep its size too.
+*/
+func (m *Manifest) Record(emoji slack.Emoji, dir, filename string, downloaded bool) error {
+	fPath := filepath.Join(dir, filename)
+	info, err := os.Stat(fPath)
+	if err != nil {
+		return err
+	}
+
+	entry := entryFromEmoji(emoji)
+	entry.Filename = filename
+	entry.Size = info.Size()
+
+	existing, ok := m.Get(emoji.Name)
+	if !downloaded && ok && existing.Filename == entry.Filename && existing.Size == entry.Size && existing.SHA256 != "" {
+		entry.SHA256 = existing.SHA256
+	} else {
+		entry.SHA256, err = HashFile(fPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Emoji[emoji.Name] = entry
+	return nil
+}
+
+/*
+Changed
+
+Reports whether an emoji in slack differs from the version recorded in
+the manifest along with why. A different created time means the name was
+deleted and uploaded again, a different url means the image was replaced.
+Emoji with no entry (or a file-less one) aren't considered changed.
+*/
+func (m *Manifest) Changed(emoji slack.Emoji) (bool, string) {
+	entry, ok := m.Get(emoji.Name)
+	switch {
+	case !ok || entry.IsAlias:
+		return false, ""
+	case entry.Created != emoji.Created:
+		return true, "uploaded again"
+	case entry.URL != emoji.URL:
+		return true, "image changed"
+	}
+	return false, ""
+}
+
+// Advance moves the high water mark up to the newest created time among the emoji
+func (m *Manifest) Advance(emoji []slack.Emoji) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range emoji {
+		m.HighWaterMark = max(m.HighWaterMark, e.Created)
+	}
+}
+
+// RecordData stores the metadata for an emoji whose image was written somewhere other than a local file
+func (m *Manifest) RecordData(emoji slack.Emoji, filename string, data []byte) {
+	entry := entryFromEmoji(emoji)
+	entry.Filename = filename
+	entry.Size = int64(len(data))
+	sum := sha256.Sum256(data)
+	entry.SHA256 = hex.EncodeToString(sum[:])
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Emoji[emoji.Name] = entry
+}
+
+// RecordAlias stores the metadata for an alias, which has no file of its own
+func (m *Manifest) RecordAlias(emoji slack.Emoji) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Emoji[emoji.Name] = entryFromEmoji(emoji)
+}
+
+// Entries returns every entry in the manifest sorted by name, including removed emoji
+func (m *Manifest) Entries() []ManifestEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := make([]ManifestEntry, 0, len(m.Emoji))
+	for _, entry := range m.Emoji {
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b ManifestEntry) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return entries
+}
+
+// Aliases returns the alias entries in the manifest sorted by name, leaving out aliases that have since been removed
+func (m *Manifest) Aliases() []ManifestEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	aliases := make([]ManifestEntry, 0)
+	for _, entry := range m.Emoji {
+		if entry.IsAlias && entry.RemovedAt == 0 {
+			aliases = append(aliases, entry)
+		}
+	}
+	slices.SortFunc(aliases, func(a, b ManifestEntry) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return aliases
+}
+
+// Save atomically writes the manifest into the given export directory
+func (m *Manifest) Save(dir string) error {
+	fp, err := os.CreateTemp(dir, "."+ManifestFilename+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(fp.Name())
+
+	if err := m.Encode(fp); err != nil {
+		fp.Close()
+		return err
+	}
+	if err := fp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(fp.Name(), filepath.Join(dir, ManifestFilename))
+}
+
+// Encode stamps the update time and writes the manifest as indented json
+func (m *Manifest) Encode(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.UpdatedAt = time.Now().Unix()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(m); err != nil {
+		return errors.Join(fmt.Errorf("unable to write manifest"), err)
+	}
+	return nil
+}
+
+// HashFile returns the hex encoded sha256 of a file's contents
+func HashFile(fPath string) (string, error) {
+	fp, err := os.Open(fPath)
+	if err != nil {
+		return "", err
+	}
+	defer fp.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, fp); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func entryFromEmoji(emoji slack.Emoji) ManifestEntry {
+	return ManifestEntry{
+		Name:            emoji.Name,
+		URL:             emoji.URL,
+		Created:         emoji.Created,
+		UserID:          emoji.UserID,
+		UserDisplayName: emoji.UserDisplayName,
+		IsAlias:         emoji.IsAlias == 1,
+		AliasFor:        emoji.AliasFor,
+		Synonyms:        emoji.Synonyms,
+		IsBad:           emoji.IsBad,
+		LastSeen:        time.Now().Unix(),
+	}
+}
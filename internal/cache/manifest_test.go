@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/neko"
+)
+
+func TestManifest(t *testing.T) {
+	tests := neko.Modern(t)
+
+	tests.It("round trips through the export directory", func(t *testing.T) {
+		dir := t.TempDir()
+		fPath := filepath.Join(dir, "party-parrot.gif")
+		require.Nil(t, os.WriteFile(fPath, []byte("parrot"), 0644))
+
+		manifest, err := LoadManifest(dir)
+		require.Nil(t, err)
+		assert.Empty(t, manifest.Emoji)
+
+		emoji := slack.Emoji{
+			Name:            "party-parrot",
+			Created:         1700000000,
+			UserID:          "U123",
+			UserDisplayName: "parrot-fan",
+			Synonyms:        []string{"party-parrot", "parrot"},
+		}
+		require.Nil(t, manifest.Record(emoji, dir, "party-parrot.gif", true))
+		require.Nil(t, manifest.Save(dir))
+
+		loaded, err := LoadManifest(dir)
+		require.Nil(t, err)
+		entry, ok := loaded.Get("party-parrot")
+		require.True(t, ok)
+		assert.Equal(t, "party-parrot.gif", entry.Filename)
+		assert.Equal(t, int64(6), entry.Size)
+		assert.Equal(t, "U123", entry.UserID)
+		assert.Equal(t, "parrot-fan", entry.UserDisplayName)
+		assert.Equal(t, int64(1700000000), entry.Created)
+		// sha256("parrot")
+		assert.Equal(t, "4488b8b86b1ac061dbe37242297e5827dad889823fd1a5acaed43dec0108d048", entry.SHA256)
+	})
+
+	tests.It("rehashes a downloaded image that kept its name and size", func(t *testing.T) {
+		dir := t.TempDir()
+		fPath := filepath.Join(dir, "blob.png")
+		require.Nil(t, os.WriteFile(fPath, []byte("blob"), 0644))
+		manifest, err := LoadManifest(dir)
+		require.Nil(t, err)
+
+		emoji := slack.Emoji{Name: "blob", Created: 1700000000}
+		require.Nil(t, manifest.Record(emoji, dir, "blob.png", true))
+		original, _ := manifest.Get("blob")
+
+		require.Nil(t, os.WriteFile(fPath, []byte("blub"), 0644))
+		require.Nil(t, manifest.Record(emoji, dir, "blob.png", false))
+		skipped, _ := manifest.Get("blob")
+		assert.Equal(t, original.SHA256, skipped.SHA256)
+
+		require.Nil(t, manifest.Record(emoji, dir, "blob.png", true))
+		downloaded, _ := manifest.Get("blob")
+		hash, err := HashFile(fPath)
+		require.Nil(t, err)
+		assert.Equal(t, hash, downloaded.SHA256)
+		assert.NotEqual(t, original.SHA256, downloaded.SHA256)
+	})
+
+	tests.It("records filenames relative to the export directory", func(t *testing.T) {
+		dir := t.TempDir()
+		require.Nil(t, os.MkdirAll(filepath.Join(dir, "parrots"), 0755))
+		helpWritePNG(t, filepath.Join(dir, "parrots", "party-parrot.png"), 8)
+		manifest, err := LoadManifest(dir)
+		require.Nil(t, err)
+
+		filename := filepath.Join("parrots", "party-parrot.png")
+		require.Nil(t, manifest.Record(slack.Emoji{Name: "party-parrot"}, dir, filename, true))
+		entry, ok := manifest.Get("party-parrot")
+		require.True(t, ok)
+		assert.Equal(t, filename, entry.Filename)
+
+		problems, err := VerifyDownloaded(dir, manifest)
+		require.Nil(t, err)
+		assert.Empty(t, problems)
+	})
+
+	tests.It("records aliases without files and leaves out removed ones", func(t *testing.T) {
+		dir := t.TempDir()
+		manifest, err := LoadManifest(dir)
+		require.Nil(t, err)
+
+		manifest.RecordAlias(slack.Emoji{Name: "zz-parrot", IsAlias: 1, AliasFor: "party-parrot"})
+		manifest.RecordAlias(slack.Emoji{Name: "aa-parrot", IsAlias: 1, AliasFor: "party-parrot"})
+		manifest.RecordAlias(slack.Emoji{Name: "old-parrot", IsAlias: 1, AliasFor: "party-parrot"})
+		manifest.MarkRemoved([]slack.Emoji{{Name: "zz-parrot"}, {Name: "aa-parrot"}}, time.Unix(1800000000, 0))
+		require.Nil(t, manifest.Save(dir))
+
+		loaded, err := LoadManifest(dir)
+		require.Nil(t, err)
+		aliases := loaded.Aliases()
+		require.Len(t, aliases, 2)
+		assert.Equal(t, "aa-parrot", aliases[0].Name)
+		assert.Equal(t, "party-parrot", aliases[0].AliasFor)
+		assert.Empty(t, aliases[0].Filename)
+	})
+
+	tests.It("detects emoji that changed since they were recorded", func(t *testing.T) {
+		dir := t.TempDir()
+		fPath := filepath.Join(dir, "blob.png")
+		require.Nil(t, os.WriteFile(fPath, []byte("blob"), 0644))
+		manifest, err := LoadManifest(dir)
+		require.Nil(t, err)
+
+		emoji := slack.Emoji{Name: "blob", Created: 1700000000, URL: "https://emoji.slack-edge.com/T1/blob/aaaa.png"}
+		require.Nil(t, manifest.Record(emoji, dir, "blob.png", true))
+
+		changed, _ := manifest.Changed(emoji)
+		assert.False(t, changed)
+
+		reuploaded := emoji
+		reuploaded.Created = 1800000000
+		changed, reason := manifest.Changed(reuploaded)
+		assert.True(t, changed)
+		assert.Equal(t, "uploaded again", reason)
+
+		replaced := emoji
+		replaced.URL = "https://emoji.slack-edge.com/T1/blob/bbbb.gif"
+		changed, reason = manifest.Changed(replaced)
+		assert.True(t, changed)
+		assert.Equal(t, "image changed", reason)
+
+		changed, _ = manifest.Changed(slack.Emoji{Name: "unknown", Created: 1})
+		assert.False(t, changed)
+	})
+
+	tests.It("keeps the newest created time as the high water mark", func(t *testing.T) {
+		dir := t.TempDir()
+		manifest, err := LoadManifest(dir)
+		require.Nil(t, err)
+
+		manifest.Advance([]slack.Emoji{{Name: "a", Created: 200}, {Name: "b", Created: 100}})
+		manifest.Advance([]slack.Emoji{{Name: "c", Created: 150}})
+		require.Nil(t, manifest.Save(dir))
+
+		loaded, err := LoadManifest(dir)
+		require.Nil(t, err)
+		assert.Equal(t, int64(200), loaded.HighWaterMark)
+	})
+
+	tests.It("rejects manifests from newer versions", func(t *testing.T) {
+		dir := t.TempDir()
+		require.Nil(t, os.WriteFile(filepath.Join(dir, ManifestFilename), []byte(`{"version": 99}`), 0644))
+
+		_, err := LoadManifest(dir)
+		assert.NotNil(t, err)
+	})
+
+	tests.It("is not listed as a downloaded emoji", func(t *testing.T) {
+		dir := t.TempDir()
+		require.Nil(t, os.WriteFile(filepath.Join(dir, "blob.png"), []byte("blob"), 0644))
+		manifest, err := LoadManifest(dir)
+		require.Nil(t, err)
+		require.Nil(t, manifest.Save(dir))
+
+		emojis, err := ListDownloadedEmojis(dir)
+		require.Nil(t, err)
+		require.Len(t, emojis, 1)
+		assert.Equal(t, "blob", emojis[0].Name)
+	})
+
+	tests.Run()
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+/*
+EmojiAt
+
+Works out which emoji existed at a point in time from the manifest and the
+tombstone log. An emoji existed if it was created by then and hadn't been
+removed yet, and when a name was removed and uploaded again the version
+that was live at the time wins. Results are sorted by name.
+*/
+func EmojiAt(manifest *Manifest, tombstones []ManifestEntry, at time.Time) []ManifestEntry {
+	manifest.mu.Lock()
+	versions := slices.Clone(tombstones)
+	for _, entry := range manifest.Emoji {
+		versions = append(versions, entry)
+	}
+	manifest.mu.Unlock()
+
+	existed := make(map[string]ManifestEntry)
+	for _, version := range versions {
+		if version.Created > at.Unix() || (version.RemovedAt != 0 && version.RemovedAt <= at.Unix()) {
+			continue
+		}
+		if current, ok := existed[version.Name]; ok && current.Created >= version.Created {
+			continue
+		}
+		existed[version.Name] = version
+	}
+
+	entries := make([]ManifestEntry, 0, len(existed))
+	for _, entry := range existed {
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b ManifestEntry) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return entries
+}
+
+/*
+EntriesFromFiles
+
+Stands in for the manifest for archives exported before it existed, every
+downloaded image becomes an entry with no metadata beyond its file name
+*/
+func EntriesFromFiles(dir string) ([]ManifestEntry, error) {
+	emojis, err := ListDownloadedEmojis(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]ManifestEntry, 0, len(emojis))
+	for _, emoji := range emojis {
+		relative, err := filepath.Rel(dir, filepath.Join(emoji.Dir, emoji.Filename))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, ManifestEntry{Name: emoji.Name, Filename: relative})
+	}
+	return entries, nil
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/neko"
+)
+
+func TestEmojiAt(t *testing.T) {
+	tests := neko.Modern(t)
+
+	tests.It("picks the emoji that were live at the time", func(t *testing.T) {
+		manifest, err := LoadManifest(t.TempDir())
+		require.Nil(t, err)
+		manifest.Emoji = map[string]ManifestEntry{
+			"blob":   {Name: "blob", Filename: "blob.png", Created: 100},
+			"parrot": {Name: "parrot", Filename: "parrot.gif", Created: 300},
+			"gone":   {Name: "gone", Filename: "gone.png", Created: 100, RemovedAt: 250},
+		}
+		tombstones := []ManifestEntry{
+			{Name: "gone", Filename: "gone.png", Created: 100, RemovedAt: 250},
+			// parrot was removed and uploaded again with a different image
+			{Name: "parrot", Filename: "_removed/parrot.png", Created: 50, RemovedAt: 280},
+		}
+
+		names := func(entries []ManifestEntry) []string {
+			result := []string{}
+			for _, entry := range entries {
+				result = append(result, entry.Name+":"+entry.Filename)
+			}
+			return result
+		}
+		assert.Equal(t, []string{"blob:blob.png", "gone:gone.png", "parrot:_removed/parrot.png"}, names(EmojiAt(manifest, tombstones, time.Unix(200, 0))))
+		assert.Equal(t, []string{"blob:blob.png"}, names(EmojiAt(manifest, tombstones, time.Unix(290, 0))))
+		assert.Equal(t, []string{"blob:blob.png", "parrot:parrot.gif"}, names(EmojiAt(manifest, tombstones, time.Unix(400, 0))))
+	})
+
+	tests.It("lists files for archives without a manifest", func(t *testing.T) {
+		dir := t.TempDir()
+		require.Nil(t, os.MkdirAll(filepath.Join(dir, "b"), 0755))
+		require.Nil(t, os.WriteFile(filepath.Join(dir, "b", "blob.png"), []byte("blob"), 0644))
+
+		entries, err := EntriesFromFiles(dir)
+		require.Nil(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "blob", entries[0].Name)
+		assert.Equal(t, filepath.Join("b", "blob.png"), entries[0].Filename)
+	})
+
+	tests.Run()
+}
@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+)
+
+const (
+	// TombstonesFilename is the append-only log of removed emoji inside an export directory
+	TombstonesFilename = "tombstones.jsonl"
+	// RemovedDir is where images of removed emoji are moved to when asked
+	RemovedDir = "_removed"
+)
+
+/*
+MarkRemoved
+
+Compares the manifest against the emoji currently in slack, stamping the
+time on every entry that is no longer there and returning those entries
+sorted by name. Entries that were already marked aren't returned again,
+and an emoji that comes back gets a fresh entry when it's next recorded.
+*/
+func (m *Manifest) MarkRemoved(live []slack.Emoji, at time.Time) []ManifestEntry {
+	current := make(map[string]bool, len(live))
+	for _, emoji := range live {
+		current[emoji.Name] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	removed := make([]ManifestEntry, 0)
+	for name, entry := range m.Emoji {
+		if current[name] || entry.RemovedAt != 0 {
+			continue
+		}
+		entry.RemovedAt = at.Unix()
+		m.Emoji[name] = entry
+		removed = append(removed, entry)
+	}
+	slices.SortFunc(removed, func(a, b ManifestEntry) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return removed
+}
+
+// SetFilename updates where an entry's image lives, relative to the export directory
+func (m *Manifest) SetFilename(name, filename string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry, ok := m.Emoji[name]; ok {
+		entry.Filename = filename
+		m.Emoji[name] = entry
+	}
+}
+
+// AppendTombstones adds removed emoji to the tombstone log in an export directory
+func AppendTombstones(dir string, tombstones []ManifestEntry) error {
+	if len(tombstones) == 0 {
+		return nil
+	}
+	fp, err := os.OpenFile(filepath.Join(dir, TombstonesFilename), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	encoder := json.NewEncoder(fp)
+	for _, tombstone := range tombstones {
+		if err := encoder.Encode(tombstone); err != nil {
+			return errors.Join(fmt.Errorf("unable to write tombstone for %s", tombstone.Name), err)
+		}
+	}
+	return fp.Sync()
+}
+
+// LoadTombstones reads every tombstone recorded in an export directory, oldest first
+func LoadTombstones(dir string) ([]ManifestEntry, error) {
+	tombstones := make([]ManifestEntry, 0)
+	fp, err := os.Open(filepath.Join(dir, TombstonesFilename))
+	if errors.Is(err, fs.ErrNotExist) {
+		return tombstones, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	scanner := bufio.NewScanner(fp)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var tombstone ManifestEntry
+		if err := json.Unmarshal(scanner.Bytes(), &tombstone); err != nil {
+			return nil, errors.Join(fmt.Errorf("unable to parse tombstone on line %d", line), err)
+		}
+		tombstones = append(tombstones, tombstone)
+	}
+	return tombstones, scanner.Err()
+}
+
+/*
+MoveToRemoved
+
+Moves a removed emoji's image from fPath into a dated folder under the
+export directory's _removed area, returning the new path relative to the
+export directory
+*/
+func MoveToRemoved(dir, fPath string, removedAt time.Time) (string, error) {
+	relative := filepath.Join(RemovedDir, removedAt.Format(time.DateOnly), filepath.Base(fPath))
+	if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(relative)), 0755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(fPath, filepath.Join(dir, relative)); err != nil {
+		return "", err
+	}
+	return relative, nil
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/neko"
+)
+
+func TestTombstones(t *testing.T) {
+	tests := neko.Modern(t)
+
+	tests.It("marks emoji missing from slack as removed once", func(t *testing.T) {
+		dir := t.TempDir()
+		manifest, err := LoadManifest(dir)
+		require.Nil(t, err)
+		for _, name := range []string{"blob", "parrot"} {
+			fPath := filepath.Join(dir, name+".png")
+			helpWritePNG(t, fPath, 8)
+			require.Nil(t, manifest.Record(slack.Emoji{Name: name, UserDisplayName: "erin"}, dir, name+".png", true))
+		}
+		manifest.RecordAlias(slack.Emoji{Name: "blob-alias", IsAlias: 1, AliasFor: "blob"})
+
+		removedAt := time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC)
+		removed := manifest.MarkRemoved([]slack.Emoji{{Name: "parrot"}}, removedAt)
+		require.Len(t, removed, 2)
+		assert.Equal(t, "blob", removed[0].Name)
+		assert.Equal(t, "erin", removed[0].UserDisplayName)
+		assert.Equal(t, removedAt.Unix(), removed[0].RemovedAt)
+		assert.NotZero(t, removed[0].LastSeen)
+		assert.Equal(t, "blob-alias", removed[1].Name)
+
+		assert.Empty(t, manifest.MarkRemoved([]slack.Emoji{{Name: "parrot"}}, removedAt.Add(time.Hour)))
+	})
+
+	tests.It("appends to and reads the tombstone log", func(t *testing.T) {
+		dir := t.TempDir()
+		require.Nil(t, AppendTombstones(dir, []ManifestEntry{{Name: "blob", RemovedAt: 1}}))
+		require.Nil(t, AppendTombstones(dir, []ManifestEntry{{Name: "parrot", RemovedAt: 2}}))
+
+		tombstones, err := LoadTombstones(dir)
+		require.Nil(t, err)
+		require.Len(t, tombstones, 2)
+		assert.Equal(t, "blob", tombstones[0].Name)
+		assert.Equal(t, "parrot", tombstones[1].Name)
+	})
+
+	tests.It("moves removed images out of the listing", func(t *testing.T) {
+		dir := t.TempDir()
+		fPath := filepath.Join(dir, "blob.png")
+		helpWritePNG(t, fPath, 8)
+		require.Nil(t, AppendTombstones(dir, []ManifestEntry{{Name: "blob", RemovedAt: 1}}))
+
+		relative, err := MoveToRemoved(dir, fPath, time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC))
+		require.Nil(t, err)
+		assert.Equal(t, filepath.Join(RemovedDir, "2025-03-14", "blob.png"), relative)
+		_, err = os.Stat(filepath.Join(dir, relative))
+		assert.Nil(t, err)
+
+		emojis, err := ListDownloadedEmojis(dir)
+		require.Nil(t, err)
+		assert.Empty(t, emojis)
+	})
+
+	tests.Run()
+}
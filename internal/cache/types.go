@@ -1,5 +1,7 @@
 package cache
 
+import "sync"
+
 type EmojiItem struct {
 	Name     string
 	Filename string
@@ -14,3 +16,41 @@ type EmojiPage struct {
 	PrevPage string
 	Emojis   []EmojiItem
 }
+
+// Manifest is the versioned metadata record kept alongside an export
+type Manifest struct {
+	Version   int   `json:"version"`
+	UpdatedAt int64 `json:"updated_at"`
+	// HighWaterMark is the newest created time seen by the last complete export
+	HighWaterMark int64                    `json:"high_water_mark,omitempty"`
+	Emoji         map[string]ManifestEntry `json:"emoji"`
+
+	mu sync.Mutex
+}
+
+type ManifestEntry struct {
+	Name string `json:"name"`
+	// Filename is relative to the export directory
+	Filename        string   `json:"filename,omitempty"`
+	Size            int64    `json:"size,omitempty"`
+	SHA256          string   `json:"sha256,omitempty"`
+	URL             string   `json:"url"`
+	Created         int64    `json:"created"`
+	UserID          string   `json:"user_id"`
+	UserDisplayName string   `json:"user_display_name"`
+	IsAlias         bool     `json:"is_alias"`
+	AliasFor        string   `json:"alias_for,omitempty"`
+	Synonyms        []string `json:"synonyms,omitempty"`
+	IsBad           bool     `json:"is_bad"`
+	LastSeen        int64    `json:"last_seen,omitempty"`
+	RemovedAt       int64    `json:"removed_at,omitempty"`
+}
+
+// Problem is an emoji in an export directory that failed verification
+type Problem struct {
+	Name string
+	// Filename is relative to the export directory
+	Filename string
+	Reason   string
+	Missing  bool
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/erindatkinson/emoji-archiver/internal/images"
+)
+
+/*
+VerifyDownloaded
+
+Checks every image in an export directory can be decoded and, when the
+manifest knows about it, still has the recorded size and hash. Manifest
+entries whose image has gone missing are reported too.
+*/
+func VerifyDownloaded(emojiDir string, manifest *Manifest) ([]Problem, error) {
+	emojis, err := ListDownloadedEmojis(emojiDir)
+	if err != nil {
+		return nil, err
+	}
+
+	problems := make([]Problem, 0)
+	seen := make(map[string]bool)
+	for _, emoji := range emojis {
+		seen[emoji.Name] = true
+		fPath := filepath.Join(emoji.Dir, emoji.Filename)
+		relative, err := filepath.Rel(emojiDir, fPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := images.VerifyFile(fPath); err != nil {
+			problems = append(problems, Problem{Name: emoji.Name, Filename: relative, Reason: err.Error()})
+			continue
+		}
+
+		entry, ok := manifest.Get(emoji.Name)
+		if !ok || entry.Filename != relative || entry.SHA256 == "" {
+			continue
+		}
+		info, err := os.Stat(fPath)
+		if err != nil {
+			return nil, err
+		}
+		if info.Size() != entry.Size {
+			problems = append(problems, Problem{Name: emoji.Name, Filename: relative,
+				Reason: fmt.Sprintf("size is %d bytes, manifest has %d", info.Size(), entry.Size)})
+			continue
+		}
+		hash, err := HashFile(fPath)
+		if err != nil {
+			return nil, err
+		}
+		if hash != entry.SHA256 {
+			problems = append(problems, Problem{Name: emoji.Name, Filename: relative, Reason: "sha256 doesn't match manifest"})
+		}
+	}
+
+	manifest.mu.Lock()
+	for name, entry := range manifest.Emoji {
+		if !entry.IsAlias && entry.Filename != "" && entry.RemovedAt == 0 && !seen[name] {
+			problems = append(problems, Problem{Name: name, Filename: entry.Filename, Reason: "missing from export directory", Missing: true})
+		}
+	}
+	manifest.mu.Unlock()
+
+	slices.SortFunc(problems, func(a, b Problem) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return problems, nil
+}
+
+// RemoveTempFiles cleans up temp files left in an export directory by downloads that were killed
+func RemoveTempFiles(emojiDir string) (int, error) {
+	entries, err := os.ReadDir(emojiDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") && strings.Contains(entry.Name(), ".tmp-") {
+			if err := os.Remove(filepath.Join(emojiDir, entry.Name())); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+	}
+	return removed, nil
+}
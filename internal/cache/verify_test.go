@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/neko"
+)
+
+func helpWritePNG(t *testing.T, fPath string, size int) {
+	buf := new(bytes.Buffer)
+	require.Nil(t, png.Encode(buf, image.NewRGBA(image.Rect(0, 0, size, size))))
+	require.Nil(t, os.WriteFile(fPath, buf.Bytes(), 0644))
+}
+
+func TestVerifyDownloaded(t *testing.T) {
+	tests := neko.Modern(t)
+
+	tests.It("finds corrupt, changed and missing emoji", func(t *testing.T) {
+		dir := t.TempDir()
+		manifest, err := LoadManifest(dir)
+		require.Nil(t, err)
+
+		for _, name := range []string{"good", "changed", "missing"} {
+			fPath := filepath.Join(dir, name+".png")
+			helpWritePNG(t, fPath, 8)
+			require.Nil(t, manifest.Record(slack.Emoji{Name: name}, dir, name+".png", true))
+		}
+		require.Nil(t, os.WriteFile(filepath.Join(dir, "truncated.png"), []byte("\x89PNG"), 0644))
+		helpWritePNG(t, filepath.Join(dir, "changed.png"), 16)
+		require.Nil(t, os.Remove(filepath.Join(dir, "missing.png")))
+
+		problems, err := VerifyDownloaded(dir, manifest)
+		require.Nil(t, err)
+		require.Len(t, problems, 3)
+		assert.Equal(t, "changed", problems[0].Name)
+		assert.Contains(t, problems[0].Reason, "manifest")
+		assert.Equal(t, "missing", problems[1].Name)
+		assert.True(t, problems[1].Missing)
+		assert.Equal(t, "truncated", problems[2].Name)
+	})
+
+	tests.It("removes temp files from killed downloads", func(t *testing.T) {
+		dir := t.TempDir()
+		helpWritePNG(t, filepath.Join(dir, "good.png"), 8)
+		require.Nil(t, os.WriteFile(filepath.Join(dir, ".blob.png.tmp-1234"), []byte("partial"), 0644))
+
+		removed, err := RemoveTempFiles(dir)
+		require.Nil(t, err)
+		assert.Equal(t, 1, removed)
+		_, err = os.Stat(filepath.Join(dir, "good.png"))
+		assert.Nil(t, err)
+	})
+
+	tests.Run()
+}
@@ -0,0 +1,211 @@
+/*
+Package discord uploads emoji into, and reads them from, a Discord guild
+through the bot API, and plans which emoji fit in a guild's limited static
+and animated slots.
+*/
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/erindatkinson/emoji-archiver/internal/httpretry"
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+)
+
+const (
+	defaultURL    = "https://discord.com"
+	apiPath       = "/api/v10"
+	defaultCDNURL = "https://cdn.discordapp.com"
+	// discordEpoch is when snowflake ids start counting, in unix milliseconds
+	discordEpoch = 1420070400000
+)
+
+var _ platform.Backend = (*Client)(nil)
+
+type Client struct {
+	platform.HTTP
+	GuildID string
+	Token   string
+	// CDNURL is where emoji images are downloaded from
+	CDNURL string
+}
+
+/*
+NewClient
+
+Creates a client for a guild with a bot token, checking the bot can see
+the guild. A client pointed at another server with platform.WithURL
+downloads images from that server too.
+*/
+func NewClient(ctx context.Context, guildID, token string, opts ...platform.Option) (*Client, error) {
+	if guildID == "" {
+		return nil, errors.New("a discord guild id is required")
+	}
+	if token == "" {
+		return nil, errors.Join(errors.New("a discord bot token is required"), slack.ErrAuth)
+	}
+	client := &Client{GuildID: guildID, Token: token, CDNURL: defaultCDNURL}
+	client.HTTP = platform.HTTP{
+		URL:        defaultURL,
+		APIPath:    apiPath,
+		HTTPClient: http.DefaultClient,
+		Logger:     utilities.ContextLogger(ctx),
+		Retry:      newRetrier(),
+		Authorize:  client.authorize,
+		Upload:     client.UploadEmoji,
+	}
+	for _, opt := range opts {
+		opt(&client.HTTP)
+	}
+	if client.URL != defaultURL {
+		client.CDNURL = client.URL
+	}
+	if _, err := client.Guild(ctx); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// Guild looks up the guild's boost tier and current emoji
+func (c *Client) Guild(ctx context.Context) (Guild, error) {
+	guild := Guild{}
+	err := c.GetJSON(ctx, "/guilds/"+url.PathEscape(c.GuildID), &guild)
+	return guild, err
+}
+
+// FreeSlots works out how many more static and animated emoji the guild can take
+func (c *Client) FreeSlots(ctx context.Context) (Slots, error) {
+	guild, err := c.Guild(ctx)
+	if err != nil {
+		return Slots{}, err
+	}
+	free := SlotsForTier(guild.PremiumTier)
+	for _, e := range guild.Emojis {
+		if e.Animated {
+			free.Animated--
+		} else {
+			free.Static--
+		}
+	}
+	return Slots{Static: max(0, free.Static), Animated: max(0, free.Animated)}, nil
+}
+
+/*
+ListEmoji
+
+Lists the guild's emoji. Discord doesn't say when an emoji was uploaded,
+so Created comes from the timestamp inside its id. Discord has no aliases,
+so none are ever returned.
+*/
+func (c *Client) ListEmoji(ctx context.Context) ([]slack.Emoji, error) {
+	listed := []emoji{}
+	if err := c.GetJSON(ctx, "/guilds/"+url.PathEscape(c.GuildID)+"/emojis", &listed); err != nil {
+		return []slack.Emoji{}, err
+	}
+	result := make([]slack.Emoji, 0, len(listed))
+	for _, e := range listed {
+		ext := ".png"
+		if e.Animated {
+			ext = ".gif"
+		}
+		converted := slack.Emoji{
+			Name:    e.Name,
+			Created: snowflakeTime(e.ID),
+			URL:     c.CDNURL + "/emojis/" + url.PathEscape(e.ID) + ext,
+		}
+		if e.User != nil {
+			converted.UserID = e.User.ID
+			converted.UserDisplayName = e.User.Username
+			if e.User.GlobalName != "" {
+				converted.UserDisplayName = e.User.GlobalName
+			}
+		}
+		result = append(result, converted)
+	}
+	return result, nil
+}
+
+// UploadEmoji creates a new emoji from an image held in memory, sent as a data uri
+func (c *Client) UploadEmoji(ctx context.Context, name, filename string, image []byte) error {
+	c.Logger.Debug("importing emoji", "name", name)
+	body, err := json.Marshal(createEmoji{
+		Name:  name,
+		Image: "data:" + http.DetectContentType(image) + ";base64," + base64.StdEncoding.EncodeToString(image),
+		Roles: []string{},
+	})
+	if err != nil {
+		return err
+	}
+	apiPath := "/guilds/" + url.PathEscape(c.GuildID) + "/emojis"
+	resp, err := c.Do(ctx, apiPath, func() (*http.Request, error) {
+		req, err := c.NewRequest(ctx, http.MethodPost, apiPath, bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return req, err
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+/*
+AddAlias
+
+Discord has no aliases, so the alias is created as a copy of the target's
+image under the new name, taking up a slot of its own
+*/
+func (c *Client) AddAlias(ctx context.Context, name, target string) error {
+	c.Logger.Debug("adding alias as a copy", "name", name, "alias_for", target)
+	existing, err := c.ListEmoji(ctx)
+	if err != nil {
+		return err
+	}
+	for _, e := range existing {
+		if e.Name != target {
+			continue
+		}
+		data, filename, err := c.Download(ctx, e)
+		if err != nil {
+			return err
+		}
+		return c.UploadEmoji(ctx, name, filename, data)
+	}
+	return fmt.Errorf("unable to find %s to copy for alias %s", target, name)
+}
+
+//========== Private Methods ==========
+
+// authorize sends the bot token
+func (c *Client) authorize(req *http.Request) {
+	req.Header.Set("Authorization", "Bot "+c.Token)
+}
+
+// newRetrier retries requests the way discord asks
+func newRetrier() httpretry.Retrier {
+	retry := httpretry.New("discord")
+	retry.RetryAfter = retryAfter
+	retry.Error = decodeError
+	return retry
+}
+
+// snowflakeTime pulls the unix time out of a discord id
+func snowflakeTime(id string) int64 {
+	snowflake, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return (int64(snowflake>>22) + discordEpoch) / 1000
+}
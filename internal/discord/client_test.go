@@ -0,0 +1,131 @@
+package discord_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/apitest"
+	"github.com/erindatkinson/emoji-archiver/internal/discord"
+	"github.com/erindatkinson/emoji-archiver/internal/discord/discordtest"
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/neko"
+)
+
+func helpNewClient(t *testing.T, opts ...platform.Option) (*discord.Client, *discordtest.Server) {
+	server := discordtest.NewServer()
+	return apitest.NewClient(t, server, opts...), server
+}
+
+func TestClient(t *testing.T) {
+	tests := neko.Modern(t)
+
+	tests.It("rejects a bad token or guild", func(t *testing.T) {
+		server := discordtest.NewServer()
+		defer server.Close()
+		ctx := utilities.ToContext(t.Context(), utilities.NewLogger("error"))
+		_, err := discord.NewClient(ctx, discordtest.GuildID, "nope", server.ClientOptions()...)
+		assert.ErrorIs(t, err, slack.ErrAuth)
+		_, err = discord.NewClient(ctx, "404", discordtest.Token, server.ClientOptions()...)
+		assert.NotNil(t, err)
+	})
+
+	tests.It("counts free static and animated slots", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.PremiumTier = 1
+		server.AddEmoji("still", false, apitest.PNG(t))
+		server.AddEmoji("spin", true, apitest.AnimatedGIF(t))
+		server.AddEmoji("dance", true, apitest.AnimatedGIF(t))
+
+		free, err := client.FreeSlots(t.Context())
+		require.Nil(t, err)
+		assert.Equal(t, discord.Slots{Static: 99, Animated: 98}, free)
+	})
+
+	tests.It("lists and exports emoji", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.AddEmoji("still", false, apitest.PNG(t))
+		server.AddEmoji("spin", true, apitest.AnimatedGIF(t))
+
+		emoji, err := client.ListEmoji(t.Context())
+		require.Nil(t, err)
+		require.Len(t, emoji, 2)
+		assert.Equal(t, discordtest.BotName, emoji[0].UserDisplayName)
+		assert.InDelta(t, time.Now().Unix(), emoji[0].Created, 5)
+
+		dir := t.TempDir()
+		filename, err := client.ExportEmoji(t.Context(), emoji[1], dir)
+		require.Nil(t, err)
+		assert.Equal(t, "spin.gif", filename)
+		data, err := os.ReadFile(filepath.Join(dir, filename))
+		require.Nil(t, err)
+		assert.Equal(t, apitest.AnimatedGIF(t), data)
+	})
+
+	tests.It("uploads gifs as animated emoji", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		fPath := filepath.Join(t.TempDir(), "spin.gif")
+		require.Nil(t, os.WriteFile(fPath, apitest.AnimatedGIF(t), 0644))
+
+		require.Nil(t, client.ImportEmoji(t.Context(), "spin", fPath))
+		require.Nil(t, client.UploadEmoji(t.Context(), "still", "still.png", apitest.PNG(t)))
+		emoji := server.Emoji()
+		require.Len(t, emoji, 2)
+		assert.True(t, emoji[0].Animated)
+		assert.False(t, emoji[1].Animated)
+	})
+
+	tests.It("copies the target's image for an alias", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.AddEmoji("blob", false, apitest.PNG(t))
+
+		require.Nil(t, client.AddAlias(t.Context(), "blob_too", "blob"))
+		emoji := server.Emoji()
+		require.Len(t, emoji, 2)
+		assert.Equal(t, apitest.PNG(t), emoji[1].Image)
+		assert.NotNil(t, client.AddAlias(t.Context(), "missing_too", "missing"))
+	})
+
+	tests.It("maps json error codes onto error kinds", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		for range 50 {
+			server.AddEmoji("still", false, apitest.PNG(t))
+		}
+
+		err := client.UploadEmoji(t.Context(), "one_more", "one_more.png", apitest.PNG(t))
+		assert.ErrorIs(t, err, discord.ErrNoSlots)
+		err = client.UploadEmoji(t.Context(), "not-a-name", "spin.gif", apitest.AnimatedGIF(t))
+		assert.ErrorIs(t, err, slack.ErrInvalidName)
+		err = client.UploadEmoji(t.Context(), "huge", "huge.gif", append(apitest.AnimatedGIF(t), make([]byte, discord.MaxFileSize)...))
+		assert.ErrorIs(t, err, slack.ErrTooLarge)
+	})
+
+	tests.It("waits out a 429's retry_after", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.InjectFault(discordtest.MethodListEmoji, discordtest.Fault{Status: http.StatusTooManyRequests, RetryAfter: 0.2})
+		server.InjectFault(discordtest.MethodListEmoji, discordtest.Fault{Status: http.StatusBadGateway})
+
+		started := time.Now()
+		_, err := client.ListEmoji(t.Context())
+		require.Nil(t, err)
+		assert.GreaterOrEqual(t, time.Since(started), 200*time.Millisecond)
+		assert.Equal(t, 3, server.Requests(discordtest.MethodListEmoji))
+	})
+
+	tests.It("gives up once retries run out", func(t *testing.T) {
+		client, server := helpNewClient(t, platform.WithRetries(1))
+		for range 2 {
+			server.InjectFault(discordtest.MethodAddEmoji, discordtest.Fault{Status: http.StatusTooManyRequests})
+		}
+		err := client.UploadEmoji(t.Context(), "still", "still.png", apitest.PNG(t))
+		assert.ErrorIs(t, err, slack.ErrRateLimited)
+	})
+
+	tests.Run()
+}
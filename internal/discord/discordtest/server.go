@@ -0,0 +1,275 @@
+/*
+Package discordtest runs an in memory stand-in for the guild emoji parts
+of Discord's bot api and cdn, so the discord client and commands can be
+tested without a guild.
+*/
+package discordtest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/apitest"
+	"github.com/erindatkinson/emoji-archiver/internal/discord"
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
+)
+
+const (
+	// Token is the bot token the server accepts
+	Token = "discordtest-token"
+	// GuildID is the only guild the server knows
+	GuildID = "100000000000000001"
+	// BotID is the bot's user id, uploads are made as it
+	BotID = "100000000000000002"
+	// BotName is the bot's username
+	BotName = "discordtest"
+)
+
+// Endpoints that faults can be injected into
+const (
+	MethodGuild     = "guild"
+	MethodListEmoji = "emojis"
+	MethodAddEmoji  = "emojis/create"
+	MethodImage     = "image"
+)
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9_]{2,32}$`)
+
+// Fault replaces the next response from an endpoint with an error status
+// and, if Code is set, a json error body
+type Fault struct {
+	Status     int
+	Code       int
+	RetryAfter float64
+}
+
+// Emoji is an emoji stored in the guild
+type Emoji struct {
+	ID       string
+	Name     string
+	Animated bool
+	UserID   string
+	Image    []byte
+}
+
+type Server struct {
+	*httptest.Server
+
+	// PremiumTier is the guild's boost tier, it decides how many slots there are
+	PremiumTier int
+
+	mu    sync.Mutex
+	emoji []Emoji
+	users map[string]string
+	apitest.Faults[Fault]
+	nextID uint64
+}
+
+// NewServer starts a server, close it when done
+func NewServer() *Server {
+	s := &Server{
+		Faults: apitest.Faults[Fault]{Write: writeFault},
+		users:  map[string]string{BotID: BotName},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v10/guilds/{guild}", s.handleGuild)
+	mux.HandleFunc("GET /api/v10/guilds/{guild}/emojis", s.handleListEmoji)
+	mux.HandleFunc("POST /api/v10/guilds/{guild}/emojis", s.handleAddEmoji)
+	mux.HandleFunc("GET /emojis/{file}", s.handleImage)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// ClientOptions points a discord client at the server
+func (s *Server) ClientOptions() []platform.Option {
+	return []platform.Option{
+		platform.WithURL(s.URL),
+		platform.WithHTTPClient(s.Client()),
+	}
+}
+
+// Connect creates a discord client for the server's guild and bot token
+func (s *Server) Connect(ctx context.Context, opts ...platform.Option) (*discord.Client, error) {
+	return discord.NewClient(ctx, GuildID, Token, append(s.ClientOptions(), opts...)...)
+}
+
+// AddEmoji seeds the guild with an emoji, uploaded by the bot
+func (s *Server) AddEmoji(name string, animated bool, image []byte) Emoji {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addEmoji(name, animated, image)
+}
+
+// Emoji returns the guild's emoji
+func (s *Server) Emoji() []Emoji {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.emoji)
+}
+
+//========== Handlers ==========
+
+func (s *Server) handleGuild(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodGuild) || !s.authorized(w, r) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	apitest.WriteJSON(w, http.StatusOK, map[string]any{
+		"id":           GuildID,
+		"name":         "discordtest",
+		"premium_tier": s.PremiumTier,
+		"emojis":       s.responses(),
+	})
+}
+
+func (s *Server) handleListEmoji(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodListEmoji) || !s.authorized(w, r) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	apitest.WriteJSON(w, http.StatusOK, s.responses())
+}
+
+func (s *Server) handleAddEmoji(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodAddEmoji) || !s.authorized(w, r) {
+		return
+	}
+	body := struct {
+		Name  string `json:"name"`
+		Image string `json:"image"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, 50109, "The request body contains invalid JSON.", nil)
+		return
+	}
+	if !validName.MatchString(body.Name) {
+		writeError(w, http.StatusBadRequest, 50035, "Invalid Form Body", map[string]any{"name": map[string]any{}})
+		return
+	}
+	header, encoded, ok := strings.Cut(body.Image, ";base64,")
+	contentType := strings.TrimPrefix(header, "data:")
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if !ok || err != nil || !strings.HasPrefix(contentType, "image/") {
+		writeError(w, http.StatusBadRequest, 50035, "Invalid Form Body", map[string]any{"image": map[string]any{}})
+		return
+	}
+	if len(data) > discord.MaxFileSize {
+		writeError(w, http.StatusBadRequest, 50045, "File uploaded exceeds the maximum size", nil)
+		return
+	}
+	animated := contentType == "image/gif"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	used := 0
+	for _, e := range s.emoji {
+		if e.Animated == animated {
+			used++
+		}
+	}
+	slots := discord.SlotsForTier(s.PremiumTier)
+	if (animated && used >= slots.Animated) || (!animated && used >= slots.Static) {
+		writeError(w, http.StatusBadRequest, 30008, "Maximum number of emojis reached", nil)
+		return
+	}
+	created := s.addEmoji(body.Name, animated, data)
+	apitest.WriteJSON(w, http.StatusCreated, s.response(created))
+}
+
+func (s *Server) handleImage(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodImage) {
+		return
+	}
+	id, _, _ := strings.Cut(r.PathValue("file"), ".")
+	s.mu.Lock()
+	index := slices.IndexFunc(s.emoji, func(e Emoji) bool {
+		return e.ID == id
+	})
+	var data []byte
+	if index >= 0 {
+		data = s.emoji[index].Image
+	}
+	s.mu.Unlock()
+	if index < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	apitest.WriteImage(w, data)
+}
+
+//========== Helpers ==========
+
+// writeFault sends a fault the way discord reports errors
+func writeFault(w http.ResponseWriter, fault Fault) {
+	if fault.Status == http.StatusTooManyRequests {
+		apitest.WriteJSON(w, fault.Status, map[string]any{"message": "You are being rate limited.", "retry_after": fault.RetryAfter, "global": false})
+		return
+	}
+	writeError(w, fault.Status, fault.Code, http.StatusText(fault.Status), nil)
+}
+
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get("Authorization") != "Bot "+Token {
+		writeError(w, http.StatusUnauthorized, 0, "401: Unauthorized", nil)
+		return false
+	}
+	if r.PathValue("guild") != GuildID {
+		writeError(w, http.StatusNotFound, 10004, "Unknown Guild", nil)
+		return false
+	}
+	return true
+}
+
+// addEmoji expects the lock to be held, ids are snowflakes for the current time
+func (s *Server) addEmoji(name string, animated bool, image []byte) Emoji {
+	s.nextID++
+	snowflake := uint64(time.Now().UnixMilli()-1420070400000)<<22 | s.nextID
+	stored := Emoji{
+		ID:       strconv.FormatUint(snowflake, 10),
+		Name:     name,
+		Animated: animated,
+		UserID:   BotID,
+		Image:    image,
+	}
+	s.emoji = append(s.emoji, stored)
+	return stored
+}
+
+// responses expects the lock to be held
+func (s *Server) responses() []map[string]any {
+	responses := make([]map[string]any, 0, len(s.emoji))
+	for _, e := range s.emoji {
+		responses = append(responses, s.response(e))
+	}
+	return responses
+}
+
+// response expects the lock to be held
+func (s *Server) response(e Emoji) map[string]any {
+	return map[string]any{
+		"id":       e.ID,
+		"name":     e.Name,
+		"animated": e.Animated,
+		"user":     map[string]any{"id": e.UserID, "username": s.users[e.UserID]},
+	}
+}
+
+func writeError(w http.ResponseWriter, status, code int, message string, errors map[string]any) {
+	body := map[string]any{"code": code, "message": message}
+	if errors != nil {
+		body["errors"] = errors
+	}
+	apitest.WriteJSON(w, status, body)
+}
@@ -0,0 +1,61 @@
+package discord
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+)
+
+// ErrNoSlots is returned when a guild has no emoji slots left of the kind being uploaded
+var ErrNoSlots = errors.New("no emoji slots left")
+
+// errorCodes maps Discord's json error codes onto error kinds
+var errorCodes = map[int]error{
+	30008: ErrNoSlots,
+	50013: slack.ErrAuth,
+	50045: slack.ErrTooLarge,
+	50138: slack.ErrTooLarge,
+	50001: slack.ErrAuth,
+}
+
+// errorKind picks the kind for a discord error body, nil when it isn't recognised
+func errorKind(body apiError) error {
+	if body.Code == 50035 {
+		// invalid form body, the errors object says which field was wrong
+		fields := map[string]json.RawMessage{}
+		json.Unmarshal(body.Errors, &fields)
+		if _, ok := fields["name"]; ok {
+			return slack.ErrInvalidName
+		} else if _, ok := fields["image"]; ok {
+			return slack.ErrBadImage
+		}
+	}
+	return errorCodes[body.Code]
+}
+
+// retryAfter reads how long a 429 asks to wait from Retry-After or the retry_after in its body
+func retryAfter(resp *http.Response) time.Duration {
+	body := apiError{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if seconds, err := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64); err == nil {
+		body.RetryAfter = max(body.RetryAfter, seconds)
+	}
+	return time.Duration(math.Ceil(body.RetryAfter*1000)) * time.Millisecond
+}
+
+// decodeError reads the apiError discord sends with an error status
+func decodeError(path string, resp *http.Response) error {
+	body := apiError{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	code := ""
+	if body.Code != 0 {
+		code = "code " + strconv.Itoa(body.Code)
+	}
+	return platform.NewAPIError(resp, path, code, body.Message, errorKind(body))
+}
@@ -0,0 +1,148 @@
+package discord
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// MaxFileSize is the largest image in bytes Discord accepts for an emoji
+	MaxFileSize = 256 * 1024
+	// MaxNameLength and MinNameLength bound an emoji's name
+	MaxNameLength = 32
+	MinNameLength = 2
+)
+
+// Orders emoji are given slots in
+const (
+	PriorityNewest = "newest"
+	PriorityOldest = "oldest"
+	PriorityName   = "name"
+)
+
+// Priorities lists every supported priority
+var Priorities = []string{PriorityNewest, PriorityOldest, PriorityName}
+
+var invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// tierSlots is how many static, and separately how many animated, emoji a guild gets at each boost tier
+var tierSlots = map[int]int{0: 50, 1: 100, 2: 150, 3: 250}
+
+// Slots counts emoji slots, split between static and animated emoji
+type Slots struct {
+	Static   int
+	Animated int
+}
+
+// Candidate is an image that could be uploaded to a guild
+type Candidate struct {
+	// Name is what the emoji will be called in discord, Source what it was called before
+	Name     string
+	Source   string
+	Path     string
+	Size     int64
+	Animated bool
+	Created  int64
+	// AliasFor is set when the candidate is a copy of another emoji standing in for an alias
+	AliasFor string
+}
+
+// Placement is the planned outcome for a candidate
+type Placement struct {
+	Candidate
+	Fits   bool
+	Reason string
+}
+
+// SlotsForTier returns a guild's static and animated slots at a boost tier
+func SlotsForTier(tier int) Slots {
+	slots, ok := tierSlots[tier]
+	if !ok {
+		slots = tierSlots[max(0, min(tier, 3))]
+	}
+	return Slots{Static: slots, Animated: slots}
+}
+
+/*
+EmojiName
+
+Turns a name from another platform into one Discord accepts: letters,
+digits and underscores, between 2 and 32 characters long
+*/
+func EmojiName(name string) string {
+	name = invalidNameChars.ReplaceAllString(name, "_")
+	if len(name) > MaxNameLength {
+		name = name[:MaxNameLength]
+	}
+	if len(name) < MinNameLength {
+		name += strings.Repeat("_", MinNameLength-len(name))
+	}
+	return name
+}
+
+/*
+Plan
+
+Decides which candidates fit into the free slots. Candidates are taken in
+priority order, newest first by default, and each takes a static or an
+animated slot until that kind runs out. Anything over Discord's size limit
+or whose name clashes with an earlier candidate once renamed is dropped
+without using a slot. Placements are returned in priority order.
+*/
+func Plan(candidates []Candidate, free Slots, priority string) ([]Placement, error) {
+	compare, err := priorityOrder(priority)
+	if err != nil {
+		return nil, err
+	}
+	sorted := slices.Clone(candidates)
+	slices.SortStableFunc(sorted, func(a, b Candidate) int {
+		return cmp.Or(compare(a, b), strings.Compare(a.Name, b.Name))
+	})
+
+	placements := make([]Placement, 0, len(sorted))
+	placed := make(map[string]string)
+	for _, candidate := range sorted {
+		placement := Placement{Candidate: candidate}
+		remaining := &free.Static
+		kind := "static"
+		if candidate.Animated {
+			remaining = &free.Animated
+			kind = "animated"
+		}
+		switch {
+		case candidate.Size > MaxFileSize:
+			placement.Reason = fmt.Sprintf("%dKB is over discord's %dKB limit", (candidate.Size+1023)/1024, MaxFileSize/1024)
+		case placed[candidate.Name] != "":
+			placement.Reason = fmt.Sprintf("name clashes with :%s: once renamed", placed[candidate.Name])
+		case *remaining <= 0:
+			placement.Reason = fmt.Sprintf("no %s slots left", kind)
+		default:
+			*remaining--
+			placement.Fits = true
+			placed[candidate.Name] = candidate.Source
+		}
+		placements = append(placements, placement)
+	}
+	return placements, nil
+}
+
+func priorityOrder(priority string) (func(a, b Candidate) int, error) {
+	switch priority {
+	case PriorityNewest:
+		return func(a, b Candidate) int {
+			return cmp.Compare(b.Created, a.Created)
+		}, nil
+	case PriorityOldest:
+		return func(a, b Candidate) int {
+			return cmp.Compare(a.Created, b.Created)
+		}, nil
+	case PriorityName:
+		return func(a, b Candidate) int {
+			return 0
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown priority %q, expected one of %v", priority, Priorities)
+}
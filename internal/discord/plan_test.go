@@ -0,0 +1,80 @@
+package discord
+
+import (
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/neko"
+)
+
+func TestPlan(t *testing.T) {
+	tests := neko.Modern(t)
+
+	fitting := func(placements []Placement) []string {
+		return lo.FilterMap(placements, func(placement Placement, index int) (string, bool) {
+			return placement.Name, placement.Fits
+		})
+	}
+
+	tests.It("fills static and animated slots separately, newest first", func(t *testing.T) {
+		candidates := []Candidate{
+			{Name: "old", Created: 100},
+			{Name: "new", Created: 300},
+			{Name: "middle", Created: 200},
+			{Name: "spin", Created: 50, Animated: true},
+			{Name: "dance", Created: 60, Animated: true},
+		}
+		placements, err := Plan(candidates, Slots{Static: 2, Animated: 1}, PriorityNewest)
+		require.Nil(t, err)
+		assert.Equal(t, []string{"new", "middle", "dance"}, fitting(placements))
+
+		dropped, _ := lo.Find(placements, func(placement Placement) bool {
+			return placement.Name == "spin"
+		})
+		assert.Equal(t, "no animated slots left", dropped.Reason)
+	})
+
+	tests.It("orders by other priorities", func(t *testing.T) {
+		candidates := []Candidate{{Name: "b", Created: 100}, {Name: "c", Created: 300}, {Name: "a", Created: 200}}
+		placements, err := Plan(candidates, Slots{Static: 2}, PriorityOldest)
+		require.Nil(t, err)
+		assert.Equal(t, []string{"b", "a"}, fitting(placements))
+
+		placements, err = Plan(candidates, Slots{Static: 2}, PriorityName)
+		require.Nil(t, err)
+		assert.Equal(t, []string{"a", "b"}, fitting(placements))
+
+		_, err = Plan(candidates, Slots{}, "loudest")
+		assert.NotNil(t, err)
+	})
+
+	tests.It("drops oversized images and clashing names without using a slot", func(t *testing.T) {
+		candidates := []Candidate{
+			{Name: "huge", Size: MaxFileSize + 1, Created: 300},
+			{Name: "party_parrot", Source: "party-parrot", Created: 200},
+			{Name: "party_parrot", Source: "party_parrot", Created: 100},
+			{Name: "blob", Created: 50},
+		}
+		placements, err := Plan(candidates, Slots{Static: 2}, PriorityNewest)
+		require.Nil(t, err)
+		assert.Equal(t, []string{"party_parrot", "blob"}, fitting(placements))
+		assert.Equal(t, "257KB is over discord's 256KB limit", placements[0].Reason)
+		assert.Equal(t, "name clashes with :party-parrot: once renamed", placements[2].Reason)
+	})
+
+	tests.It("renames emoji to discord's rules", func(t *testing.T) {
+		assert.Equal(t, "party_parrot", EmojiName("party-parrot"))
+		assert.Equal(t, "a_", EmojiName("a"))
+		assert.Equal(t, "plus_one", EmojiName("plus+one"))
+		assert.Len(t, EmojiName("a_really_long_emoji_name_that_goes_on_and_on"), MaxNameLength)
+	})
+
+	tests.It("gives guilds more slots as they're boosted", func(t *testing.T) {
+		assert.Equal(t, Slots{Static: 50, Animated: 50}, SlotsForTier(0))
+		assert.Equal(t, Slots{Static: 250, Animated: 250}, SlotsForTier(3))
+	})
+
+	tests.Run()
+}
@@ -0,0 +1,38 @@
+package discord
+
+import "encoding/json"
+
+// Guild is the part of a guild that decides its emoji slots
+type Guild struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	PremiumTier int     `json:"premium_tier"`
+	Emojis      []emoji `json:"emojis"`
+}
+
+type emoji struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Animated bool   `json:"animated"`
+	User     *user  `json:"user,omitempty"`
+}
+
+type user struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	GlobalName string `json:"global_name,omitempty"`
+}
+
+type createEmoji struct {
+	Name  string   `json:"name"`
+	Image string   `json:"image"`
+	Roles []string `json:"roles"`
+}
+
+// apiError is the body discord sends with an error status
+type apiError struct {
+	Code       int             `json:"code"`
+	Message    string          `json:"message"`
+	Errors     json.RawMessage `json:"errors,omitempty"`
+	RetryAfter float64         `json:"retry_after,omitempty"`
+}
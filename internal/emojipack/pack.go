@@ -0,0 +1,223 @@
+/*
+Package emojipack reads and writes emoji packs, the YAML format used to
+share emoji collections: a title and a list of emoji, each with a name,
+the src its image can be fetched from, and any aliases.
+
+	title: parrots
+	emojis:
+	  - name: party-parrot
+	    src: https://example.com/party-parrot.gif
+	    aliases:
+	      - parrot
+*/
+package emojipack
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// maxImageSize caps how much of a src is read, no platform takes emoji anywhere near this big
+const maxImageSize = 16 * 1024 * 1024
+
+// validName covers the emoji names every platform accepts, it can't start with a dot so "." and ".." are out
+var validName = regexp.MustCompile(`^[0-9A-Za-z_+\-][0-9A-Za-z_+\-'. ]{0,99}$`)
+
+// Pack is a shareable collection of emoji
+type Pack struct {
+	Title  string  `yaml:"title"`
+	Emojis []Emoji `yaml:"emojis"`
+
+	// base is where the pack was loaded from, relative srcs are resolved against it
+	base string
+}
+
+// Emoji is one emoji in a pack
+type Emoji struct {
+	Name string `yaml:"name"`
+	// Src is an http(s) url, or a path relative to the pack file
+	Src     string   `yaml:"src"`
+	Aliases []string `yaml:"aliases,omitempty"`
+}
+
+// IsPack reports whether location looks like a pack file rather than a directory or archive
+func IsPack(location string) bool {
+	if parsed, err := url.Parse(location); err == nil && isHTTP(parsed) {
+		location = parsed.Path
+	}
+	ext := strings.ToLower(filepath.Ext(location))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+/*
+Load
+
+Reads a pack from a file or an http(s) url. Relative srcs in the pack are
+fetched from alongside it.
+*/
+func Load(ctx context.Context, client *http.Client, location string) (*Pack, error) {
+	var data []byte
+	var err error
+	if parsed, parseErr := url.Parse(location); parseErr == nil && isHTTP(parsed) {
+		data, err = get(ctx, client, location)
+	} else {
+		data, err = os.ReadFile(location)
+	}
+	if err != nil {
+		return nil, err
+	}
+	pack, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", location, err)
+	}
+	pack.base = location
+	return pack, nil
+}
+
+/*
+Decode
+
+Reads a pack and checks every emoji has a name and a src. Names and
+aliases become filenames when a pack is imported and packs are shared by
+strangers, so anything that isn't a plain emoji name is refused.
+*/
+func Decode(r io.Reader) (*Pack, error) {
+	pack := &Pack{}
+	if err := yaml.NewDecoder(r).Decode(pack); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for i, emoji := range pack.Emojis {
+		switch {
+		case emoji.Name == "":
+			return nil, fmt.Errorf("emoji %d has no name", i+1)
+		case emoji.Src == "":
+			return nil, fmt.Errorf("emoji %q has no src", emoji.Name)
+		}
+		for _, name := range append([]string{emoji.Name}, emoji.Aliases...) {
+			if !validName.MatchString(name) {
+				return nil, fmt.Errorf("%q isn't a valid emoji name", name)
+			}
+			if seen[name] {
+				return nil, fmt.Errorf("%q is in the pack more than once", name)
+			}
+			seen[name] = true
+		}
+	}
+	return pack, nil
+}
+
+// Encode writes the pack as YAML
+func (p *Pack) Encode(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(p); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// Save writes the pack to a file
+func (p *Pack) Save(fPath string) error {
+	buf := new(bytes.Buffer)
+	if err := p.Encode(buf); err != nil {
+		return err
+	}
+	return os.WriteFile(fPath, buf.Bytes(), 0644)
+}
+
+/*
+Fetch
+
+Reads an emoji's image from its src, returning it along with the filename
+it should be saved as, named after the emoji with an extension for the
+image's format.
+*/
+func (p *Pack) Fetch(ctx context.Context, client *http.Client, emoji Emoji) ([]byte, string, error) {
+	src, err := p.resolve(emoji.Src)
+	if err != nil {
+		return nil, "", err
+	}
+	var data []byte
+	if parsed, err := url.Parse(src); err == nil && isHTTP(parsed) {
+		data, err = get(ctx, client, src)
+		if err != nil {
+			return nil, "", err
+		}
+	} else {
+		fp, err := os.Open(src)
+		if err != nil {
+			return nil, "", err
+		}
+		defer fp.Close()
+		if data, err = io.ReadAll(io.LimitReader(fp, maxImageSize)); err != nil {
+			return nil, "", err
+		}
+	}
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, "", fmt.Errorf("%s is %s, not an image", emoji.Src, contentType)
+	}
+	return data, emoji.Name + imageExtension(contentType), nil
+}
+
+// resolve turns a src into a url or path, relative ones are taken from next to the pack
+func (p *Pack) resolve(src string) (string, error) {
+	parsed, err := url.Parse(src)
+	if err != nil {
+		return "", err
+	}
+	if isHTTP(parsed) || p.base == "" {
+		return src, nil
+	}
+	if base, err := url.Parse(p.base); err == nil && isHTTP(base) {
+		return base.ResolveReference(parsed).String(), nil
+	}
+	if filepath.IsAbs(src) {
+		return src, nil
+	}
+	return filepath.Join(filepath.Dir(p.base), filepath.FromSlash(src)), nil
+}
+
+func get(ctx context.Context, client *http.Client, location string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", location, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxImageSize))
+}
+
+func isHTTP(u *url.URL) bool {
+	return u.Scheme == "http" || u.Scheme == "https"
+}
+
+func imageExtension(contentType string) string {
+	switch contentType {
+	case "image/gif":
+		return ".gif"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/webp":
+		return ".webp"
+	}
+	return ".png"
+}
@@ -0,0 +1,108 @@
+package emojipack
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erindatkinson/emoji-archiver/internal/apitest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/neko"
+)
+
+func TestPack(t *testing.T) {
+	tests := neko.Modern(t)
+
+	tests.It("round trips through yaml", func(t *testing.T) {
+		pack := &Pack{Title: "parrots", Emojis: []Emoji{
+			{Name: "party-parrot", Src: "https://example.com/party-parrot.gif", Aliases: []string{"parrot"}},
+			{Name: "sad-parrot", Src: "images/sad-parrot.png"},
+		}}
+		buf := new(bytes.Buffer)
+		require.Nil(t, pack.Encode(buf))
+		assert.Contains(t, buf.String(), "title: parrots\nemojis:\n  - name: party-parrot\n")
+
+		decoded, err := Decode(buf)
+		require.Nil(t, err)
+		assert.Equal(t, pack, decoded)
+	})
+
+	tests.It("rejects emoji without a name or src, or named twice", func(t *testing.T) {
+		_, err := Decode(strings.NewReader("emojis:\n  - src: a.png\n"))
+		assert.ErrorContains(t, err, "no name")
+		_, err = Decode(strings.NewReader("emojis:\n  - name: a\n"))
+		assert.ErrorContains(t, err, "no src")
+		_, err = Decode(strings.NewReader("emojis:\n  - name: a\n    src: a.png\n  - name: b\n    src: b.png\n    aliases: [a]\n"))
+		assert.ErrorContains(t, err, `"a" is in the pack more than once`)
+	})
+
+	tests.It("rejects names that aren't plain emoji names", func(t *testing.T) {
+		for _, name := range []string{"../../../somewhere/x", "a/b", `a\b`, "..", ".hidden", "/etc/passwd", " leading-space"} {
+			_, err := Decode(strings.NewReader(fmt.Sprintf("emojis:\n  - name: %q\n    src: a.png\n", name)))
+			assert.ErrorContains(t, err, "isn't a valid emoji name", name)
+		}
+		_, err := Decode(strings.NewReader("emojis:\n  - name: a\n    src: a.png\n    aliases: [../b]\n"))
+		assert.ErrorContains(t, err, "isn't a valid emoji name")
+
+		pack, err := Decode(strings.NewReader("emojis:\n  - name: party_parrot-2\n    src: a.png\n    aliases: [\"+1\", it's.fine, with space]\n"))
+		require.Nil(t, err)
+		assert.Len(t, pack.Emojis, 1)
+	})
+
+	tests.It("recognizes pack files and urls", func(t *testing.T) {
+		assert.True(t, IsPack("packs/parrots.yaml"))
+		assert.True(t, IsPack("https://example.com/parrots.yml?raw=true"))
+		assert.False(t, IsPack("emojis.zip"))
+		assert.False(t, IsPack("s3://bucket/prefix"))
+	})
+
+	tests.It("fetches srcs relative to a pack file", func(t *testing.T) {
+		dir := t.TempDir()
+		require.Nil(t, os.MkdirAll(filepath.Join(dir, "images"), 0755))
+		require.Nil(t, os.WriteFile(filepath.Join(dir, "images", "blob.png"), apitest.PNG(t), 0644))
+		require.Nil(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hello"), 0644))
+		require.Nil(t, os.WriteFile(filepath.Join(dir, "pack.yaml"), []byte("title: blobs\nemojis:\n  - name: blob\n    src: images/blob.png\n  - name: notes\n    src: notes.txt\n"), 0644))
+
+		pack, err := Load(t.Context(), http.DefaultClient, filepath.Join(dir, "pack.yaml"))
+		require.Nil(t, err)
+		data, filename, err := pack.Fetch(t.Context(), http.DefaultClient, pack.Emojis[0])
+		require.Nil(t, err)
+		assert.Equal(t, "blob.png", filename)
+		assert.Equal(t, apitest.PNG(t), data)
+
+		_, _, err = pack.Fetch(t.Context(), http.DefaultClient, pack.Emojis[1])
+		assert.ErrorContains(t, err, "not an image")
+	})
+
+	tests.It("fetches srcs relative to a pack url", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/packs/blobs.yaml":
+				w.Write([]byte("title: blobs\nemojis:\n  - name: blob\n    src: img/blob.png\n  - name: gone\n    src: /missing.png\n"))
+			case "/packs/img/blob.png":
+				w.Write(apitest.PNG(t))
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		pack, err := Load(t.Context(), server.Client(), server.URL+"/packs/blobs.yaml")
+		require.Nil(t, err)
+		assert.Equal(t, "blobs", pack.Title)
+		data, _, err := pack.Fetch(t.Context(), server.Client(), pack.Emojis[0])
+		require.Nil(t, err)
+		assert.Equal(t, apitest.PNG(t), data)
+
+		_, _, err = pack.Fetch(t.Context(), server.Client(), pack.Emojis[1])
+		assert.ErrorContains(t, err, "404")
+	})
+
+	tests.Run()
+}
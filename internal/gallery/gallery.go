@@ -0,0 +1,450 @@
+/*
+Package gallery serves a browsable web gallery over an export directory.
+Every request lists the directory again, so emoji from a newer export show
+up without regenerating anything.
+*/
+package gallery
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/cache"
+	"github.com/erindatkinson/emoji-archiver/internal/images"
+	"github.com/erindatkinson/emoji-archiver/internal/templates"
+	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+)
+
+// PageSize is how many emoji the index shows at once
+const PageSize = 200
+
+// Kinds the gallery can be filtered to
+const (
+	KindAlias    = "alias"
+	KindAnimated = "animated"
+	KindStatic   = "static"
+)
+
+var Kinds = []string{KindAlias, KindAnimated, KindStatic}
+
+// Emoji is an emoji in the export directory with what the manifest and the
+// image itself say about it
+type Emoji struct {
+	Name string
+	// Path is the image relative to the export directory, an alias shows
+	// the image of the emoji it points at
+	Path     string
+	IsAlias  bool
+	AliasFor string
+	Aliases  []string
+	Synonyms []string
+	Uploader string
+	Created  time.Time
+	Format   string
+	Width    int
+	Height   int
+	Frames   int
+	Animated bool
+	Size     int64
+	SHA256   string
+	URL      string
+}
+
+// Filter narrows the gallery down, zero fields match everything
+type Filter struct {
+	Query    string
+	Uploader string
+	Kind     string
+	From     time.Time
+	// To is the start of the day after the last one asked for
+	To time.Time
+}
+
+/*
+ParseFilter
+
+Reads a filter from query parameters: q is a name substring, uploader a
+display name, from and to a 2006-01-02 date range that includes both ends,
+and kind one of alias, animated or static.
+*/
+func ParseFilter(values url.Values) (Filter, error) {
+	filter := Filter{
+		Query:    strings.TrimSpace(values.Get("q")),
+		Uploader: values.Get("uploader"),
+		Kind:     values.Get("kind"),
+	}
+	if filter.Kind != "" && !slices.Contains(Kinds, filter.Kind) {
+		return Filter{}, fmt.Errorf("unknown kind %q, use one of %s", filter.Kind, strings.Join(Kinds, ", "))
+	}
+	if from := values.Get("from"); from != "" {
+		date, err := time.Parse(time.DateOnly, from)
+		if err != nil {
+			return Filter{}, fmt.Errorf("from must be a date like 2006-01-02: %w", err)
+		}
+		filter.From = date
+	}
+	if to := values.Get("to"); to != "" {
+		date, err := time.Parse(time.DateOnly, to)
+		if err != nil {
+			return Filter{}, fmt.Errorf("to must be a date like 2006-01-02: %w", err)
+		}
+		filter.To = date.AddDate(0, 0, 1)
+	}
+	return filter, nil
+}
+
+// Match reports whether an emoji passes the filter
+func (f Filter) Match(emoji Emoji) bool {
+	if f.Query != "" && !strings.Contains(strings.ToLower(emoji.Name), strings.ToLower(f.Query)) {
+		return false
+	}
+	if f.Uploader != "" && emoji.Uploader != f.Uploader {
+		return false
+	}
+	if !f.From.IsZero() && emoji.Created.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !emoji.Created.Before(f.To) {
+		return false
+	}
+	switch f.Kind {
+	case KindAlias:
+		return emoji.IsAlias
+	case KindAnimated:
+		return !emoji.IsAlias && emoji.Animated
+	case KindStatic:
+		return !emoji.IsAlias && !emoji.Animated
+	}
+	return true
+}
+
+// FromValue is the start of the range as a date input value
+func (f Filter) FromValue() string {
+	if f.From.IsZero() {
+		return ""
+	}
+	return f.From.Format(time.DateOnly)
+}
+
+// ToValue is the end of the range as a date input value
+func (f Filter) ToValue() string {
+	if f.To.IsZero() {
+		return ""
+	}
+	return f.To.AddDate(0, 0, -1).Format(time.DateOnly)
+}
+
+// Values turns the filter back into query parameters
+func (f Filter) Values() url.Values {
+	values := url.Values{}
+	for key, value := range map[string]string{
+		"q":        f.Query,
+		"uploader": f.Uploader,
+		"kind":     f.Kind,
+		"from":     f.FromValue(),
+		"to":       f.ToValue(),
+	} {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	return values
+}
+
+// Handler serves the gallery for one export directory
+type Handler struct {
+	dir    string
+	logger *slog.Logger
+	tpl    *template.Template
+	mux    *http.ServeMux
+
+	mu sync.Mutex
+	// reports caches what's been read from each image, keyed by path and
+	// dropped when the file's size or modification time changes
+	reports map[string]imageReport
+}
+
+type imageReport struct {
+	size    int64
+	modTime time.Time
+	report  images.Report
+}
+
+type indexPage struct {
+	Title     string
+	Namespace string
+	Filter    Filter
+	Kinds     []string
+	Uploaders []string
+	Emoji     []Emoji
+	Count     int
+	Total     int
+	PrevPage  string
+	NextPage  string
+}
+
+type emojiPage struct {
+	Title     string
+	Namespace string
+	Emoji     Emoji
+}
+
+// New creates a handler for the export directory
+func New(ctx context.Context, dir string) (*Handler, error) {
+	tpl, err := template.New("gallery").Parse(templates.MustAssetString("templates/gallery.html.gotmpl"))
+	if err != nil {
+		return nil, err
+	}
+	h := &Handler{
+		dir:     dir,
+		logger:  utilities.ContextLogger(ctx),
+		tpl:     tpl,
+		mux:     http.NewServeMux(),
+		reports: make(map[string]imageReport),
+	}
+	h.mux.HandleFunc("GET /{$}", h.handleIndex)
+	h.mux.HandleFunc("GET /emoji/{name}", h.handleEmoji)
+	h.mux.HandleFunc("GET /images/{file...}", h.handleImage)
+	return h, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+/*
+List
+
+Reads the export directory and its manifest, returning every emoji sorted
+by name. Aliases in the manifest are listed when the emoji they point at has
+been downloaded.
+*/
+func (h *Handler) List() ([]Emoji, error) {
+	items, err := cache.ListDownloadedEmojis(h.dir)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := cache.LoadManifest(h.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	aliases := make(map[string][]string)
+	for _, alias := range manifest.Aliases() {
+		aliases[alias.AliasFor] = append(aliases[alias.AliasFor], alias.Name)
+	}
+
+	emoji := make([]Emoji, 0, len(items))
+	byName := make(map[string]Emoji, len(items))
+	for _, item := range items {
+		if _, ok := byName[item.Name]; ok {
+			continue
+		}
+		e, err := h.describe(item)
+		if err != nil {
+			h.logger.Warn("unable to read emoji", "name", item.Name, "error", err)
+			continue
+		}
+		if entry, ok := manifest.Get(item.Name); ok {
+			e.Uploader = entry.UserDisplayName
+			e.Synonyms = entry.Synonyms
+			e.SHA256 = entry.SHA256
+			e.URL = entry.URL
+			if entry.Created > 0 {
+				e.Created = time.Unix(entry.Created, 0).UTC()
+			}
+		}
+		e.Aliases = aliases[item.Name]
+		byName[e.Name] = e
+		emoji = append(emoji, e)
+	}
+
+	for _, alias := range manifest.Aliases() {
+		target, ok := byName[alias.AliasFor]
+		if !ok {
+			continue
+		}
+		if _, ok := byName[alias.Name]; ok {
+			continue
+		}
+		e := target
+		e.Name = alias.Name
+		e.IsAlias = true
+		e.AliasFor = target.Name
+		e.Aliases = nil
+		e.Synonyms = nil
+		e.Uploader = alias.UserDisplayName
+		e.URL = alias.URL
+		e.Created = time.Time{}
+		if alias.Created > 0 {
+			e.Created = time.Unix(alias.Created, 0).UTC()
+		}
+		emoji = append(emoji, e)
+	}
+
+	slices.SortFunc(emoji, func(a, b Emoji) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return emoji, nil
+}
+
+//========== Handlers ==========
+
+func (h *Handler) handleIndex(w http.ResponseWriter, r *http.Request) {
+	filter, err := ParseFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	all, err := h.List()
+	if err != nil {
+		h.fail(w, "unable to list emoji", err)
+		return
+	}
+
+	uploaders := make([]string, 0)
+	matched := make([]Emoji, 0, len(all))
+	for _, emoji := range all {
+		if emoji.Uploader != "" && !slices.Contains(uploaders, emoji.Uploader) {
+			uploaders = append(uploaders, emoji.Uploader)
+		}
+		if filter.Match(emoji) {
+			matched = append(matched, emoji)
+		}
+	}
+	slices.Sort(uploaders)
+
+	number, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	number = max(number, 1)
+	start := min((number-1)*PageSize, len(matched))
+	end := min(start+PageSize, len(matched))
+
+	page := indexPage{
+		Title:     path.Base(h.dir) + " emoji",
+		Namespace: path.Base(h.dir),
+		Filter:    filter,
+		Kinds:     Kinds,
+		Uploaders: uploaders,
+		Emoji:     matched[start:end],
+		Count:     len(all),
+		Total:     len(matched),
+	}
+	if number > 1 {
+		page.PrevPage = pageLink(filter, number-1)
+	}
+	if end < len(matched) {
+		page.NextPage = pageLink(filter, number+1)
+	}
+	h.render(w, "index", page)
+}
+
+func (h *Handler) handleEmoji(w http.ResponseWriter, r *http.Request) {
+	all, err := h.List()
+	if err != nil {
+		h.fail(w, "unable to list emoji", err)
+		return
+	}
+	index := slices.IndexFunc(all, func(e Emoji) bool {
+		return e.Name == r.PathValue("name")
+	})
+	if index < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	h.render(w, "emoji", emojiPage{
+		Title:     ":" + all[index].Name + ":",
+		Namespace: path.Base(h.dir),
+		Emoji:     all[index],
+	})
+}
+
+func (h *Handler) handleImage(w http.ResponseWriter, r *http.Request) {
+	file := r.PathValue("file")
+	if !fs.ValidPath(file) || hidden(file) {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFileFS(w, r, os.DirFS(h.dir), file)
+}
+
+//========== Helpers ==========
+
+// describe reads an image's format and frames, reusing the last read while the file is unchanged
+func (h *Handler) describe(item cache.EmojiItem) (Emoji, error) {
+	fPath := filepath.Join(item.Dir, item.Filename)
+	rel, err := filepath.Rel(h.dir, fPath)
+	if err != nil {
+		return Emoji{}, err
+	}
+	info, err := os.Stat(fPath)
+	if err != nil {
+		return Emoji{}, err
+	}
+
+	h.mu.Lock()
+	cached, ok := h.reports[fPath]
+	h.mu.Unlock()
+	if !ok || cached.size != info.Size() || !cached.modTime.Equal(info.ModTime()) {
+		report, err := images.ValidateFile(fPath, images.SlackLimits)
+		if err != nil {
+			return Emoji{}, err
+		}
+		cached = imageReport{size: info.Size(), modTime: info.ModTime(), report: report}
+		h.mu.Lock()
+		h.reports[fPath] = cached
+		h.mu.Unlock()
+	}
+
+	return Emoji{
+		Name:     item.Name,
+		Path:     filepath.ToSlash(rel),
+		Created:  info.ModTime().UTC(),
+		Format:   cached.report.Format,
+		Width:    cached.report.Width,
+		Height:   cached.report.Height,
+		Frames:   cached.report.Frames,
+		Animated: cached.report.Frames > 1,
+		Size:     info.Size(),
+	}, nil
+}
+
+func (h *Handler) render(w http.ResponseWriter, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tpl.ExecuteTemplate(w, name, data); err != nil {
+		h.logger.Error("unable to render page", "page", name, "error", err)
+	}
+}
+
+func (h *Handler) fail(w http.ResponseWriter, message string, err error) {
+	h.logger.Error(message, "error", err)
+	http.Error(w, message, http.StatusInternalServerError)
+}
+
+// hidden reports whether a path is inside the archive's bookkeeping rather than an emoji image
+func hidden(file string) bool {
+	for _, part := range strings.Split(file, "/") {
+		if strings.HasPrefix(part, "_") || cache.IsMetadataFile(part) {
+			return true
+		}
+	}
+	return false
+}
+
+func pageLink(filter Filter, number int) string {
+	values := filter.Values()
+	values.Set("page", strconv.Itoa(number))
+	return "/?" + values.Encode()
+}
@@ -0,0 +1,140 @@
+package gallery
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/apitest"
+	"github.com/erindatkinson/emoji-archiver/internal/cache"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/neko"
+)
+
+// helpExport writes an export with a static emoji, an animated one and an alias of the static one
+func helpExport(t *testing.T) string {
+	dir := filepath.Join(t.TempDir(), "team")
+	require.Nil(t, os.MkdirAll(dir, 0755))
+	manifest := cache.NewManifest()
+	manifest.RecordData(slack.Emoji{Name: "blob", UserDisplayName: "Alice", Created: time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC).Unix()}, "blob.png", apitest.PNG(t))
+	manifest.RecordData(slack.Emoji{Name: "party-parrot", UserDisplayName: "Bob", Created: time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC).Unix()}, "party-parrot.gif", apitest.AnimatedGIF(t))
+	manifest.RecordAlias(slack.Emoji{Name: "blobby", IsAlias: 1, AliasFor: "blob", UserDisplayName: "Bob", Created: time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC).Unix()})
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "blob.png"), apitest.PNG(t), 0644))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "party-parrot.gif"), apitest.AnimatedGIF(t), 0644))
+	require.Nil(t, manifest.Save(dir))
+	return dir
+}
+
+func helpGet(t *testing.T, h http.Handler, target string) (int, string) {
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+	body, err := io.ReadAll(recorder.Result().Body)
+	require.Nil(t, err)
+	return recorder.Code, string(body)
+}
+
+func TestGallery(t *testing.T) {
+	tests := neko.Modern(t)
+	ctx := utilities.ToContext(context.Background(), utilities.NewLogger("error"))
+
+	tests.It("lists emoji with manifest metadata and aliases", func(t *testing.T) {
+		h, err := New(ctx, helpExport(t))
+		require.Nil(t, err)
+
+		emoji, err := h.List()
+		require.Nil(t, err)
+		require.Len(t, emoji, 3)
+		assert.Equal(t, "blob", emoji[0].Name)
+		assert.Equal(t, "Alice", emoji[0].Uploader)
+		assert.Equal(t, []string{"blobby"}, emoji[0].Aliases)
+		assert.False(t, emoji[0].Animated)
+		assert.Equal(t, "blobby", emoji[1].Name)
+		assert.True(t, emoji[1].IsAlias)
+		assert.Equal(t, "blob.png", emoji[1].Path)
+		assert.Equal(t, "party-parrot", emoji[2].Name)
+		assert.True(t, emoji[2].Animated)
+		assert.Equal(t, 2, emoji[2].Frames)
+	})
+
+	tests.It("filters by name, uploader, date and kind", func(t *testing.T) {
+		h, err := New(ctx, helpExport(t))
+		require.Nil(t, err)
+		emoji, err := h.List()
+		require.Nil(t, err)
+
+		names := func(query string) []string {
+			values, err := url.ParseQuery(query)
+			require.Nil(t, err)
+			filter, err := ParseFilter(values)
+			require.Nil(t, err)
+			matched := []string{}
+			for _, e := range emoji {
+				if filter.Match(e) {
+					matched = append(matched, e.Name)
+				}
+			}
+			return matched
+		}
+
+		assert.Equal(t, []string{"blob", "blobby"}, names("q=BLOB"))
+		assert.Equal(t, []string{"blobby", "party-parrot"}, names("uploader=Bob"))
+		assert.Equal(t, []string{"blobby"}, names("from=2024-02-01&to=2024-03-04"))
+		assert.Equal(t, []string{"party-parrot"}, names("to=2024-03-05&from=2024-03-05"))
+		assert.Equal(t, []string{"blobby"}, names("kind=alias"))
+		assert.Equal(t, []string{"party-parrot"}, names("kind=animated"))
+		assert.Equal(t, []string{"blob"}, names("kind=static"))
+
+		_, err = ParseFilter(url.Values{"kind": {"sparkly"}})
+		assert.NotNil(t, err)
+		_, err = ParseFilter(url.Values{"from": {"last tuesday"}})
+		assert.NotNil(t, err)
+	})
+
+	tests.It("serves the index, detail pages and images", func(t *testing.T) {
+		h, err := New(ctx, helpExport(t))
+		require.Nil(t, err)
+
+		status, body := helpGet(t, h, "/?kind=animated")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Contains(t, body, "1 of 3 emoji")
+		assert.Contains(t, body, `data-copy=":party-parrot:"`)
+		assert.NotContains(t, body, `data-copy=":blob:"`)
+
+		status, body = helpGet(t, h, "/emoji/blobby")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Contains(t, body, `<a href="/emoji/blob">blob</a>`)
+		assert.Contains(t, body, `src="/images/blob.png"`)
+
+		status, _ = helpGet(t, h, "/emoji/missing")
+		assert.Equal(t, http.StatusNotFound, status)
+		status, body = helpGet(t, h, "/images/blob.png")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, string(apitest.PNG(t)), body)
+		status, _ = helpGet(t, h, "/images/manifest.json")
+		assert.Equal(t, http.StatusNotFound, status)
+		status, _ = helpGet(t, h, "/?kind=sparkly")
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	tests.It("picks up emoji exported after it started", func(t *testing.T) {
+		dir := helpExport(t)
+		h, err := New(ctx, dir)
+		require.Nil(t, err)
+
+		require.Nil(t, os.WriteFile(filepath.Join(dir, "late.png"), apitest.PNG(t), 0644))
+		_, body := helpGet(t, h, "/")
+		assert.Contains(t, body, "4 of 4 emoji")
+		assert.Contains(t, body, `data-copy=":late:"`)
+	})
+
+	tests.Run()
+}
@@ -0,0 +1,187 @@
+/*
+Package gitrepo drives the git command line to keep an export directory
+under version control, so each export shows up in the repository's log.
+*/
+package gitrepo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// defaultIdentity is committed as when git has no user configured
+var defaultIdentity = Author{Name: "emoji-archiver", Email: "emoji-archiver@localhost"}
+
+// Author is who a commit is attributed to
+type Author struct {
+	Name  string
+	Email string
+}
+
+// Repo is a git working tree rooted at or above Dir
+type Repo struct {
+	Dir string
+
+	committer Author
+}
+
+/*
+Open
+
+Returns the repository that dir belongs to, running git init in dir when
+it isn't inside a working tree yet. When git has no identity configured,
+commits are made as emoji-archiver.
+*/
+func Open(ctx context.Context, dir string) (*Repo, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, errors.Join(fmt.Errorf("git isn't installed"), err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	repo := &Repo{Dir: dir}
+	if _, err := repo.git(ctx, nil, "", "rev-parse", "--is-inside-work-tree"); err != nil {
+		if _, err := repo.git(ctx, nil, "", "init", "--quiet"); err != nil {
+			return nil, err
+		}
+	}
+	if email, _ := repo.git(ctx, nil, "", "config", "user.email"); email == "" {
+		repo.committer = defaultIdentity
+	}
+	return repo, nil
+}
+
+// Add stages paths, relative to Dir, or everything under Dir when none are given
+func (r *Repo) Add(ctx context.Context, paths ...string) error {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	_, err := r.git(ctx, nil, "", append([]string{"add", "--all", "--"}, paths...)...)
+	return err
+}
+
+// Staged reports whether anything is staged to be committed under paths, or under Dir when none are given
+func (r *Repo) Staged(ctx context.Context, paths ...string) (bool, error) {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	_, err := r.git(ctx, nil, "", append([]string{"diff", "--cached", "--quiet", "--"}, paths...)...)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return true, nil
+	}
+	return false, err
+}
+
+// Tracked reports whether git already tracks path, relative to Dir
+func (r *Repo) Tracked(ctx context.Context, path string) (bool, error) {
+	_, err := r.git(ctx, nil, "", "ls-files", "--error-unmatch", "--", path)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+/*
+Ignore
+
+Keeps patterns, relative to Dir, out of the repository through its
+info/exclude file, so nothing is added to the working tree for it
+*/
+func (r *Repo) Ignore(ctx context.Context, patterns ...string) error {
+	excludePath, err := r.git(ctx, nil, "", "rev-parse", "--git-path", "info/exclude")
+	if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(excludePath) {
+		excludePath = filepath.Join(r.Dir, excludePath)
+	}
+	prefix, err := r.git(ctx, nil, "", "rev-parse", "--show-prefix")
+	if err != nil {
+		return err
+	}
+
+	existing, err := os.ReadFile(excludePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	lines := strings.Split(string(existing), "\n")
+	missing := ""
+	for _, pattern := range patterns {
+		line := "/" + prefix + pattern
+		if !slices.Contains(lines, line) {
+			missing += line + "\n"
+		}
+	}
+	if missing == "" {
+		return nil
+	}
+	if len(existing) > 0 && !bytes.HasSuffix(existing, []byte("\n")) {
+		missing = "\n" + missing
+	}
+	if err := os.MkdirAll(filepath.Dir(excludePath), 0755); err != nil {
+		return err
+	}
+	fp, err := os.OpenFile(excludePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = fp.WriteString(missing)
+	return errors.Join(err, fp.Close())
+}
+
+/*
+Commit
+
+Commits paths, or everything under Dir when none are given, with the
+message. Paths have to be tracked or staged with Add first. An empty author
+leaves it to git, and a zero date means now.
+*/
+func (r *Repo) Commit(ctx context.Context, message string, author Author, date time.Time, paths ...string) error {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	env := []string{}
+	if r.committer.Email != "" {
+		env = append(env, "GIT_COMMITTER_NAME="+r.committer.Name, "GIT_COMMITTER_EMAIL="+r.committer.Email)
+		if author.Email == "" {
+			author = r.committer
+		}
+	}
+	if author.Email != "" {
+		env = append(env, "GIT_AUTHOR_NAME="+author.Name, "GIT_AUTHOR_EMAIL="+author.Email)
+	}
+	if !date.IsZero() {
+		env = append(env, "GIT_AUTHOR_DATE="+date.Format(time.RFC3339))
+	}
+	_, err := r.git(ctx, env, message, append([]string{"commit", "--quiet", "--no-verify", "--file", "-", "--"}, paths...)...)
+	return err
+}
+
+// git runs a git command in Dir with extra env vars and stdin, returning its trimmed output
+func (r *Repo) git(ctx context.Context, env []string, input string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.Dir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = strings.NewReader(input)
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	if err := cmd.Run(); err != nil {
+		output := lo.CoalesceOrEmpty(strings.TrimSpace(stderr.String()), strings.TrimSpace(stdout.String()))
+		return "", errors.Join(fmt.Errorf("git %s: %s", args[0], output), err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,126 @@
+package gitrepo
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/neko"
+)
+
+func TestRepo(t *testing.T) {
+	tests := neko.Modern(t)
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	log := func(t *testing.T, repo *Repo, format string) string {
+		out, err := repo.git(t.Context(), nil, "", "log", "--format="+format)
+		require.Nil(t, err)
+		return out
+	}
+
+	tests.It("initializes a repository and commits to it", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "team")
+		repo, err := Open(t.Context(), dir)
+		require.Nil(t, err)
+		_, err = os.Stat(filepath.Join(dir, ".git"))
+		require.Nil(t, err)
+
+		require.Nil(t, os.WriteFile(filepath.Join(dir, "blob.png"), []byte("blob"), 0644))
+		require.Nil(t, repo.Add(t.Context()))
+		staged, err := repo.Staged(t.Context())
+		require.Nil(t, err)
+		assert.True(t, staged)
+
+		created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		author := Author{Name: "Erin", Email: "U123@team.slack.com"}
+		require.Nil(t, repo.Commit(t.Context(), "Add :blob:\n\nuploaded by Erin", author, created))
+
+		assert.Equal(t, fmt.Sprintf("Erin <U123@team.slack.com> %d Add :blob:", created.Unix()), log(t, repo, "%an <%ae> %at %s"))
+		staged, err = repo.Staged(t.Context())
+		require.Nil(t, err)
+		assert.False(t, staged)
+	})
+
+	tests.It("only commits the paths it's given", func(t *testing.T) {
+		dir := t.TempDir()
+		repo, err := Open(t.Context(), dir)
+		require.Nil(t, err)
+
+		require.Nil(t, os.WriteFile(filepath.Join(dir, "blob.png"), []byte("blob"), 0644))
+		require.Nil(t, os.WriteFile(filepath.Join(dir, "manifest.json"), []byte("{}"), 0644))
+		require.Nil(t, repo.Add(t.Context()))
+		require.Nil(t, repo.Commit(t.Context(), "Add :blob:", Author{}, time.Time{}, "blob.png"))
+
+		staged, err := repo.Staged(t.Context(), "manifest.json")
+		require.Nil(t, err)
+		assert.True(t, staged)
+		require.Nil(t, repo.Commit(t.Context(), "Update manifest", Author{}, time.Time{}))
+		assert.Equal(t, "Update manifest\nAdd :blob:", log(t, repo, "%s"))
+	})
+
+	tests.It("knows which paths are tracked", func(t *testing.T) {
+		dir := t.TempDir()
+		repo, err := Open(t.Context(), dir)
+		require.Nil(t, err)
+
+		require.Nil(t, os.WriteFile(filepath.Join(dir, "blob.png"), []byte("blob"), 0644))
+		require.Nil(t, os.WriteFile(filepath.Join(dir, "parrot.gif"), []byte("parrot"), 0644))
+		require.Nil(t, repo.Add(t.Context(), "blob.png"))
+		require.Nil(t, repo.Commit(t.Context(), "Add :blob:", Author{}, time.Time{}, "blob.png"))
+		require.Nil(t, os.Remove(filepath.Join(dir, "blob.png")))
+
+		tracked, err := repo.Tracked(t.Context(), "blob.png")
+		require.Nil(t, err)
+		assert.True(t, tracked)
+		tracked, err = repo.Tracked(t.Context(), "parrot.gif")
+		require.Nil(t, err)
+		assert.False(t, tracked)
+		tracked, err = repo.Tracked(t.Context(), "parrot.png")
+		require.Nil(t, err)
+		assert.False(t, tracked)
+	})
+
+	tests.It("reuses the repository a directory is already in", func(t *testing.T) {
+		root := t.TempDir()
+		_, err := Open(t.Context(), root)
+		require.Nil(t, err)
+
+		dir := filepath.Join(root, "team")
+		_, err = Open(t.Context(), dir)
+		require.Nil(t, err)
+		_, err = os.Stat(filepath.Join(dir, ".git"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	tests.It("ignores patterns without touching the working tree", func(t *testing.T) {
+		root := t.TempDir()
+		_, err := Open(t.Context(), root)
+		require.Nil(t, err)
+		dir := filepath.Join(root, "team")
+		repo, err := Open(t.Context(), dir)
+		require.Nil(t, err)
+
+		require.Nil(t, repo.Ignore(t.Context(), "_history"))
+		require.Nil(t, repo.Ignore(t.Context(), "_history"))
+		exclude, err := os.ReadFile(filepath.Join(root, ".git", "info", "exclude"))
+		require.Nil(t, err)
+		assert.Equal(t, 1, strings.Count(string(exclude), "/team/_history\n"))
+
+		require.Nil(t, os.MkdirAll(filepath.Join(dir, "_history"), 0755))
+		require.Nil(t, os.WriteFile(filepath.Join(dir, "_history", "snapshot.json"), []byte("{}"), 0644))
+		require.Nil(t, repo.Add(t.Context()))
+		staged, err := repo.Staged(t.Context())
+		require.Nil(t, err)
+		assert.False(t, staged)
+	})
+
+	tests.Run()
+}
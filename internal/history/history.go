@@ -0,0 +1,268 @@
+/*
+Package history keeps dated snapshots of a workspace's emoji set next to a
+content addressed store of their images, so any past state can be listed,
+compared and restored from even after the export directory moves on.
+*/
+package history
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// Dir is the history area inside an export directory
+	Dir = "_history"
+
+	// Kinds of change between snapshots
+	ChangeAdded      = "added"
+	ChangeRemoved    = "removed"
+	ChangeImage      = "changed image"
+	ChangeRetargeted = "alias retargeted"
+
+	idLayout = "20060102T150405Z"
+)
+
+var validHash = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Open returns the history store for an export directory, nothing is written until a snapshot is saved
+func Open(exportDir string) *Store {
+	return &Store{dir: filepath.Join(exportDir, Dir)}
+}
+
+// NewSnapshot starts an empty snapshot taken at the given time
+func NewSnapshot(takenAt time.Time) *Snapshot {
+	return &Snapshot{
+		ID:      takenAt.UTC().Format(idLayout),
+		TakenAt: takenAt.Unix(),
+		Emoji:   make(map[string]Entry),
+	}
+}
+
+/*
+PutBlob
+
+Adds an image to the blob store under its hash. Images already stored are
+left alone, new ones are hashed to make sure they are what the caller says
+they are, then hard linked from fPath when the filesystem allows and
+copied otherwise.
+*/
+func (s *Store) PutBlob(fPath, sum, ext string) error {
+	blob, err := s.BlobPath(sum, ext)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(blob); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+		return err
+	}
+
+	hash, err := hashFile(fPath)
+	if err != nil {
+		return err
+	}
+	if hash != sum {
+		return fmt.Errorf("%s has sha256 %s, not %s", fPath, hash, sum)
+	}
+	if err := os.Link(fPath, blob); err == nil {
+		return nil
+	}
+	return copyFile(fPath, blob, sum)
+}
+
+// BlobPath is where the image with the given hash is stored
+func (s *Store) BlobPath(sum, ext string) (string, error) {
+	if !validHash.MatchString(sum) {
+		return "", fmt.Errorf("%q isn't a sha256 hash", sum)
+	}
+	return filepath.Join(s.dir, "blobs", sum[:2], sum+ext), nil
+}
+
+/*
+Save
+
+Writes a snapshot into the store. Snapshot ids only go down to the second,
+so when another snapshot already has the id, as when two exports finish
+in the same second, a suffix is added to the id rather than one snapshot
+replacing the other.
+*/
+func (s *Store) Save(snapshot *Snapshot) error {
+	dir := filepath.Join(s.dir, "snapshots")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	base := snapshot.ID
+	for n := 2; ; n++ {
+		err := write(dir, snapshot)
+		if !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+		snapshot.ID = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// List returns the ids of every snapshot in the store, oldest first
+func (s *Store) List() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, "snapshots"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if id, ok := strings.CutSuffix(entry.Name(), ".json"); ok && !strings.HasPrefix(id, ".") {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// Load reads a snapshot by id, "latest" loads the newest one
+func (s *Store) Load(id string) (*Snapshot, error) {
+	if id == "latest" {
+		ids, err := s.List()
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			return nil, errors.New("no snapshots have been taken")
+		}
+		id = ids[len(ids)-1]
+	}
+
+	fp, err := os.Open(filepath.Join(s.dir, "snapshots", id+".json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no snapshot %q", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	snapshot := &Snapshot{}
+	if err := json.NewDecoder(fp).Decode(snapshot); err != nil {
+		return nil, errors.Join(fmt.Errorf("unable to parse snapshot %s", id), err)
+	}
+	return snapshot, nil
+}
+
+/*
+Diff
+
+Lists what changed between two snapshots sorted by name: emoji that were
+added or removed, images that were replaced, and aliases that point at a
+different emoji. A nil before is treated as empty.
+*/
+func Diff(before, after *Snapshot) []Change {
+	if before == nil {
+		before = &Snapshot{}
+	}
+	changes := make([]Change, 0)
+	for name, next := range after.Emoji {
+		previous, ok := before.Emoji[name]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Name: name, Kind: ChangeAdded, After: next})
+		case previous.AliasFor != next.AliasFor:
+			changes = append(changes, Change{Name: name, Kind: ChangeRetargeted, Before: previous, After: next})
+		case previous.SHA256 != next.SHA256:
+			changes = append(changes, Change{Name: name, Kind: ChangeImage, Before: previous, After: next})
+		}
+	}
+	for name, previous := range before.Emoji {
+		if _, ok := after.Emoji[name]; !ok {
+			changes = append(changes, Change{Name: name, Kind: ChangeRemoved, Before: previous})
+		}
+	}
+	slices.SortFunc(changes, func(a, b Change) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return changes
+}
+
+// copyFile copies source to destination through a temp file, checking the bytes copied have the expected hash
+func copyFile(source, destination, sum string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp(filepath.Dir(destination), ".blob.tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, hash), in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if copied := hex.EncodeToString(hash.Sum(nil)); copied != sum {
+		return fmt.Errorf("%s changed while it was copied, it has sha256 %s, not %s", source, copied, sum)
+	}
+	return os.Rename(out.Name(), destination)
+}
+
+// write saves a snapshot under its id, failing with fs.ErrExist rather than replacing another
+func write(dir string, snapshot *Snapshot) error {
+	fp, err := os.CreateTemp(dir, ".snapshot.tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(fp.Name())
+
+	encoder := json.NewEncoder(fp)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(snapshot); err != nil {
+		fp.Close()
+		return errors.Join(fmt.Errorf("unable to write snapshot"), err)
+	}
+	if err := fp.Close(); err != nil {
+		return err
+	}
+
+	// linking fails if the id is taken, where renaming would replace it
+	target := filepath.Join(dir, snapshot.ID+".json")
+	if err := os.Link(fp.Name(), target); err == nil || errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	if _, err := os.Stat(target); err == nil {
+		return fs.ErrExist
+	}
+	return os.Rename(fp.Name(), target)
+}
+
+func hashFile(fPath string) (string, error) {
+	fp, err := os.Open(fPath)
+	if err != nil {
+		return "", err
+	}
+	defer fp.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, fp); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
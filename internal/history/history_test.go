@@ -0,0 +1,141 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/neko"
+)
+
+func TestStore(t *testing.T) {
+	tests := neko.Modern(t)
+
+	tests.It("keeps identical images once", func(t *testing.T) {
+		dir := t.TempDir()
+		store := Open(dir)
+		// sha256("blob")
+		sum := "fa2c8cc4f28176bbeed4b736df569a34c79cd3723e9ec42f9674b4d46ac6b8b8"
+		for _, name := range []string{"blob.png", "blob-copy.png"} {
+			fPath := filepath.Join(dir, name)
+			require.Nil(t, os.WriteFile(fPath, []byte("blob"), 0644))
+			require.Nil(t, store.PutBlob(fPath, sum, ".png"))
+		}
+
+		blobs, err := os.ReadDir(filepath.Join(dir, Dir, "blobs", "fa"))
+		require.Nil(t, err)
+		require.Len(t, blobs, 1)
+		blob, err := store.BlobPath(sum, ".png")
+		require.Nil(t, err)
+		data, err := os.ReadFile(blob)
+		require.Nil(t, err)
+		assert.Equal(t, "blob", string(data))
+	})
+
+	tests.It("refuses images that don't match their hash", func(t *testing.T) {
+		dir := t.TempDir()
+		store := Open(dir)
+		fPath := filepath.Join(dir, "blob.png")
+		require.Nil(t, os.WriteFile(fPath, []byte("blub"), 0644))
+
+		sum := "fa2c8cc4f28176bbeed4b736df569a34c79cd3723e9ec42f9674b4d46ac6b8b8"
+		assert.NotNil(t, store.PutBlob(fPath, sum, ".png"))
+		blob, err := store.BlobPath(sum, ".png")
+		require.Nil(t, err)
+		_, err = os.Stat(blob)
+		assert.True(t, os.IsNotExist(err))
+		assert.NotNil(t, store.PutBlob(fPath, "", ".png"))
+		_, err = store.BlobPath("a", ".png")
+		assert.NotNil(t, err)
+	})
+
+	tests.It("lists and loads snapshots oldest first", func(t *testing.T) {
+		store := Open(t.TempDir())
+		first := NewSnapshot(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+		first.Emoji["blob"] = Entry{Name: "blob", SHA256: "aaaa"}
+		second := NewSnapshot(time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC))
+		require.Nil(t, store.Save(second))
+		require.Nil(t, store.Save(first))
+
+		ids, err := store.List()
+		require.Nil(t, err)
+		assert.Equal(t, []string{"20250101T000000Z", "20250201T000000Z"}, ids)
+
+		loaded, err := store.Load(ids[0])
+		require.Nil(t, err)
+		assert.Equal(t, "aaaa", loaded.Emoji["blob"].SHA256)
+
+		latest, err := store.Load("latest")
+		require.Nil(t, err)
+		assert.Equal(t, second.ID, latest.ID)
+
+		_, err = store.Load("19990101T000000Z")
+		assert.NotNil(t, err)
+	})
+
+	tests.It("keeps both snapshots taken in the same second", func(t *testing.T) {
+		store := Open(t.TempDir())
+		takenAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		first := NewSnapshot(takenAt)
+		first.Emoji["blob"] = Entry{Name: "blob", SHA256: "aaaa"}
+		second := NewSnapshot(takenAt.Add(500 * time.Millisecond))
+		second.Emoji["blob"] = Entry{Name: "blob", SHA256: "bbbb"}
+		require.Nil(t, store.Save(first))
+		require.Nil(t, store.Save(second))
+
+		ids, err := store.List()
+		require.Nil(t, err)
+		assert.Equal(t, []string{"20250101T000000Z", "20250101T000000Z-2"}, ids)
+		assert.Equal(t, ids[1], second.ID)
+
+		loaded, err := store.Load(ids[0])
+		require.Nil(t, err)
+		assert.Equal(t, "aaaa", loaded.Emoji["blob"].SHA256)
+		latest, err := store.Load("latest")
+		require.Nil(t, err)
+		assert.Equal(t, "bbbb", latest.Emoji["blob"].SHA256)
+		assert.Equal(t, second.ID, latest.ID)
+	})
+
+	tests.Run()
+}
+
+func TestDiff(t *testing.T) {
+	tests := neko.Modern(t)
+
+	tests.It("finds added, removed, changed and retargeted emoji", func(t *testing.T) {
+		before := NewSnapshot(time.Unix(100, 0))
+		before.Emoji = map[string]Entry{
+			"blob":       {Name: "blob", SHA256: "aaaa"},
+			"parrot":     {Name: "parrot", SHA256: "bbbb"},
+			"gone":       {Name: "gone", SHA256: "cccc"},
+			"blob-alias": {Name: "blob-alias", AliasFor: "blob"},
+		}
+		after := NewSnapshot(time.Unix(200, 0))
+		after.Emoji = map[string]Entry{
+			"blob":       {Name: "blob", SHA256: "aaaa"},
+			"parrot":     {Name: "parrot", SHA256: "dddd"},
+			"new":        {Name: "new", SHA256: "eeee"},
+			"blob-alias": {Name: "blob-alias", AliasFor: "parrot"},
+		}
+
+		changes := Diff(before, after)
+		kinds := map[string]string{}
+		for _, change := range changes {
+			kinds[change.Name] = change.Kind
+		}
+		assert.Equal(t, map[string]string{
+			"blob-alias": ChangeRetargeted,
+			"gone":       ChangeRemoved,
+			"new":        ChangeAdded,
+			"parrot":     ChangeImage,
+		}, kinds)
+		assert.Equal(t, "blob-alias", changes[0].Name)
+		assert.Len(t, Diff(nil, after), 4)
+	})
+
+	tests.Run()
+}
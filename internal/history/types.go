@@ -0,0 +1,32 @@
+package history
+
+// Entry is one emoji as it was when a snapshot was taken
+type Entry struct {
+	Name            string `json:"name"`
+	SHA256          string `json:"sha256,omitempty"`
+	Ext             string `json:"ext,omitempty"`
+	AliasFor        string `json:"alias_for,omitempty"`
+	Created         int64  `json:"created"`
+	UserID          string `json:"user_id"`
+	UserDisplayName string `json:"user_display_name"`
+}
+
+// Snapshot is the full emoji set of a workspace at a point in time
+type Snapshot struct {
+	ID      string           `json:"id"`
+	TakenAt int64            `json:"taken_at"`
+	Emoji   map[string]Entry `json:"emoji"`
+}
+
+// Change is a difference for one emoji between two snapshots
+type Change struct {
+	Name   string
+	Kind   string
+	Before Entry
+	After  Entry
+}
+
+// Store keeps snapshots and the images they refer to inside an export directory
+type Store struct {
+	dir string
+}
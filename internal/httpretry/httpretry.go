@@ -0,0 +1,178 @@
+/*
+Package httpretry sends requests to chat platform APIs, retrying the ones
+that were throttled or failed along the way. Each platform's client only
+says how its 429s ask to wait and how its error responses become errors.
+*/
+package httpretry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// Defaults for a Retrier
+const (
+	DefaultRetries     = 5
+	DefaultBackoffBase = time.Second
+	DefaultBackoffMax  = time.Minute
+)
+
+// ErrRateLimited is returned once a request is still throttled after every retry
+var ErrRateLimited = errors.New("rate limited")
+
+/*
+Retrier
+
+How a platform's requests are retried. RetryAfter reads how long a 429
+asks to wait, returning 0 when it doesn't say so the backoff is used.
+Error turns any other error status into the platform's error, when it's
+nil the response is handed back for the caller to check. Clients that
+rate limit themselves set Wait, which runs before every attempt, and
+Throttled, which hears about every 429.
+
+A network error or 5xx may come after the server has already acted on
+the request, so they're only retried for requests Idempotent allows,
+GETs and the other idempotent methods by default. A 429 is always
+retried since the server turned the request away.
+*/
+type Retrier struct {
+	// Platform names the server in log messages
+	Platform    string
+	Retries     int
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+
+	RetryAfter func(resp *http.Response) time.Duration
+	Error      func(path string, resp *http.Response) error
+	Wait       func(ctx context.Context) error
+	Throttled  func(delay time.Duration)
+	Idempotent func(req *http.Request) bool
+}
+
+// New creates a Retrier for platform with the default retries and backoff
+func New(platform string) Retrier {
+	return Retrier{
+		Platform:    platform,
+		Retries:     DefaultRetries,
+		BackoffBase: DefaultBackoffBase,
+		BackoffMax:  DefaultBackoffMax,
+	}
+}
+
+/*
+Do
+
+Sends the request built by build, retrying 429s, 5xx responses and
+network errors with exponential backoff and jitter. The request is
+rebuilt for each attempt since bodies can only be read once.
+*/
+func (r *Retrier) Do(ctx context.Context, client *http.Client, logger *slog.Logger, path string, build func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if r.Wait != nil {
+			if err := r.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		req, err := build()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+		var delay time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if attempt >= r.Retries || !r.idempotent(req) {
+				return nil, err
+			}
+			delay = r.Backoff(attempt)
+			logger.Debug("request failed, retrying", "path", path, "error", err, "attempt", attempt+1, "delay", delay)
+		case resp.StatusCode == http.StatusTooManyRequests:
+			delay = r.Backoff(attempt)
+			if r.RetryAfter != nil {
+				// If working behind a proxy, 429 may be returned with
+				// stripped headers, the backoff is used instead.
+				if retryAfter := r.RetryAfter(resp); retryAfter > 0 {
+					delay = retryAfter + Jitter(time.Second)
+				}
+			}
+			resp.Body.Close()
+			if r.Throttled != nil {
+				r.Throttled(delay)
+			}
+			logger.Debug("throttled by "+r.Platform, "path", path, "attempt", attempt+1, "retry_after", delay)
+			if attempt >= r.Retries {
+				return nil, errors.Join(fmt.Errorf("%s still rate limited after %d attempts", path, attempt+1), ErrRateLimited)
+			}
+		case resp.StatusCode >= http.StatusInternalServerError && resp.StatusCode != http.StatusNotImplemented:
+			resp.Body.Close()
+			if !r.idempotent(req) {
+				return nil, fmt.Errorf("%s failed with status %d", path, resp.StatusCode)
+			}
+			if attempt >= r.Retries {
+				return nil, fmt.Errorf("%s failed with status %d after %d attempts", path, resp.StatusCode, attempt+1)
+			}
+			delay = r.Backoff(attempt)
+			logger.Debug("server error, retrying", "path", path, "code", resp.StatusCode, "attempt", attempt+1, "delay", delay)
+		case resp.StatusCode >= http.StatusBadRequest && r.Error != nil:
+			defer resp.Body.Close()
+			return nil, r.Error(path, resp)
+		default:
+			return resp, nil
+		}
+
+		if err := Sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *Retrier) idempotent(req *http.Request) bool {
+	if r.Idempotent != nil {
+		return r.Idempotent(req)
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// Backoff doubles the delay for each attempt up to the max, with jitter so
+// concurrent workers don't all retry at the same moment
+func (r *Retrier) Backoff(attempt int) time.Duration {
+	delay := r.BackoffBase << attempt
+	if delay <= 0 || delay > r.BackoffMax {
+		delay = r.BackoffMax
+	}
+	return delay/2 + Jitter(delay/2)
+}
+
+// Jitter picks a random duration up to d
+func Jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d)
+}
+
+// Sleep waits for d, returning early if the context is cancelled
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,68 @@
+package httpretry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/neko"
+)
+
+func TestRetrier(t *testing.T) {
+	tests := neko.Modern(t)
+	logger := utilities.NewLogger("error")
+
+	// helpServer fails the first request with status and counts every request it gets
+	helpServer := func(t *testing.T, status int) (*httptest.Server, *atomic.Int32) {
+		requests := new(atomic.Int32)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if requests.Add(1) == 1 {
+				w.WriteHeader(status)
+				return
+			}
+			w.Write([]byte("ok"))
+		}))
+		t.Cleanup(server.Close)
+		return server, requests
+	}
+
+	send := func(ctx context.Context, server *httptest.Server, method string) error {
+		retry := New("test")
+		retry.BackoffBase = time.Millisecond
+		retry.BackoffMax = 10 * time.Millisecond
+		resp, err := retry.Do(ctx, server.Client(), logger, "/emoji", func() (*http.Request, error) {
+			return http.NewRequestWithContext(ctx, method, server.URL+"/emoji", strings.NewReader("body"))
+		})
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	tests.It("retries server errors for reads", func(t *testing.T) {
+		server, requests := helpServer(t, http.StatusBadGateway)
+		require.Nil(t, send(t.Context(), server, http.MethodGet))
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	tests.It("doesn't retry server errors for writes", func(t *testing.T) {
+		server, requests := helpServer(t, http.StatusBadGateway)
+		assert.ErrorContains(t, send(t.Context(), server, http.MethodPost), "failed with status 502")
+		assert.Equal(t, int32(1), requests.Load())
+	})
+
+	tests.It("retries throttled writes", func(t *testing.T) {
+		server, requests := helpServer(t, http.StatusTooManyRequests)
+		require.Nil(t, send(t.Context(), server, http.MethodPost))
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	tests.Run()
+}
@@ -0,0 +1,208 @@
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/image/draw"
+)
+
+// minDimension is the point at which shrinking an image further to hit the
+// size limit is given up on
+const minDimension = 16
+
+var jpegQualities = []int{90, 80, 70, 60, 50, 40}
+
+/*
+FixFile
+
+Downsizes and recompresses the image described by the report into
+stagingDir so it fits within limits, returning the path of the fixed copy
+*/
+func FixFile(report Report, stagingDir string, limits Limits) (string, error) {
+	data, err := os.ReadFile(report.Path)
+	if err != nil {
+		return "", err
+	}
+
+	fixed, err := Fix(data, limits)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(stagingDir, 0755); err != nil {
+		return "", err
+	}
+	fPath := filepath.Join(stagingDir, filepath.Base(report.Path))
+	if err := os.WriteFile(fPath, fixed, 0644); err != nil {
+		return "", err
+	}
+	return fPath, nil
+}
+
+/*
+Fix
+
+Scales an image down to fit within the limits' largest dimension, then
+keeps shrinking and recompressing it until it's under the size limit.
+Animated GIFs keep all of their frames and timings, and the original
+format is kept.
+*/
+func Fix(data []byte, limits Limits) ([]byte, error) {
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "gif":
+		anim, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return shrink(anim.Config.Width, anim.Config.Height, limits, func(width, height int) ([]byte, error) {
+			return encodeGIF(anim, width, height)
+		})
+	case "png", "jpeg":
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		bounds := img.Bounds()
+		return shrink(bounds.Dx(), bounds.Dy(), limits, func(width, height int) ([]byte, error) {
+			scaled := scale(img, width, height)
+			if format == "png" {
+				return encodePNG(scaled)
+			}
+			return encodeJPEG(scaled, limits.MaxFileSize)
+		})
+	default:
+		return nil, fmt.Errorf("unsupported format %s", format)
+	}
+}
+
+// shrink calls encode with ever smaller dimensions until the result fits
+func shrink(width, height int, limits Limits, encode func(width, height int) ([]byte, error)) ([]byte, error) {
+	if limits.MaxDimension > 0 {
+		width, height = fit(width, height, limits.MaxDimension)
+	}
+	for {
+		out, err := encode(width, height)
+		if err != nil {
+			return nil, err
+		}
+		if limits.MaxFileSize <= 0 || int64(len(out)) <= limits.MaxFileSize {
+			return out, nil
+		}
+
+		next := max(width, height) * 4 / 5
+		if next < minDimension {
+			return nil, fmt.Errorf("unable to get under %d bytes, smallest attempt was %d bytes", limits.MaxFileSize, len(out))
+		}
+		width, height = fit(width, height, next)
+	}
+}
+
+// fit scales width and height down to fit in a limit x limit box keeping the aspect ratio
+func fit(width, height, limit int) (int, int) {
+	if width <= limit && height <= limit {
+		return width, height
+	}
+	if width >= height {
+		return limit, max(1, height*limit/width)
+	}
+	return max(1, width*limit/height), limit
+}
+
+func scale(img image.Image, width, height int) image.Image {
+	if img.Bounds().Dx() == width && img.Bounds().Dy() == height {
+		return img
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Over, nil)
+	return dst
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeJPEG steps the quality down before the caller resorts to shrinking further
+func encodeJPEG(img image.Image, maxSize int64) ([]byte, error) {
+	var out []byte
+	for _, quality := range jpegQualities {
+		buf := new(bytes.Buffer)
+		if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, err
+		}
+		out = buf.Bytes()
+		if maxSize <= 0 || int64(len(out)) <= maxSize {
+			break
+		}
+	}
+	return out, nil
+}
+
+/*
+encodeGIF
+
+Replays the animation onto a full size canvas so frames that only cover
+part of the image and their disposal methods are respected, then scales
+each composed frame with the palette it was drawn with
+*/
+func encodeGIF(anim *gif.GIF, width, height int) ([]byte, error) {
+	out := &gif.GIF{
+		LoopCount: anim.LoopCount,
+		Config: image.Config{
+			ColorModel: anim.Config.ColorModel,
+			Width:      width,
+			Height:     height,
+		},
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, anim.Config.Width, anim.Config.Height))
+	for i, frame := range anim.Image {
+		var previous *image.RGBA
+		disposal := byte(0)
+		if i < len(anim.Disposal) {
+			disposal = anim.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(canvas.Bounds())
+			draw.Draw(previous, previous.Bounds(), canvas, image.Point{}, draw.Src)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		paletted := image.NewPaletted(image.Rect(0, 0, width, height), frame.Palette)
+		draw.Draw(paletted, paletted.Bounds(), scale(canvas, width, height), image.Point{}, draw.Src)
+		out.Image = append(out.Image, paletted)
+		out.Delay = append(out.Delay, anim.Delay[i])
+		// every frame is a whole picture now, so clear it before drawing the next
+		out.Disposal = append(out.Disposal, gif.DisposalBackground)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := gif.EncodeAll(buf, out); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
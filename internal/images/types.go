@@ -0,0 +1,20 @@
+package images
+
+// Violation is a single way an image breaks Slack's emoji requirements
+type Violation struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// Report is the result of validating one emoji image
+type Report struct {
+	Name       string      `json:"name"`
+	Path       string      `json:"path"`
+	Format     string      `json:"format,omitempty"`
+	Width      int         `json:"width,omitempty"`
+	Height     int         `json:"height,omitempty"`
+	Frames     int         `json:"frames,omitempty"`
+	Size       int64       `json:"size"`
+	Violations []Violation `json:"violations,omitempty"`
+	FixedPath  string      `json:"fixed_path,omitempty"`
+}
@@ -0,0 +1,154 @@
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// MaxDimension is the largest width or height Slack accepts for an emoji
+	MaxDimension = 128
+	// MaxFileSize is the largest image in bytes Slack accepts for an emoji
+	MaxFileSize = 64 * 1024
+)
+
+// Limits are the largest emoji image a platform accepts, a zero means there's no limit
+type Limits struct {
+	MaxDimension int
+	MaxFileSize  int64
+}
+
+// SlackLimits are Slack's emoji limits
+var SlackLimits = Limits{MaxDimension: MaxDimension, MaxFileSize: MaxFileSize}
+
+const (
+	ViolationDecode     = "decode"
+	ViolationFormat     = "format"
+	ViolationDimensions = "dimensions"
+	ViolationSize       = "size"
+)
+
+var supportedFormats = []string{"png", "gif", "jpeg", "webp"}
+
+// ValidateFile reads an image from disk and validates it
+func ValidateFile(fPath string, limits Limits) (Report, error) {
+	data, err := os.ReadFile(fPath)
+	if err != nil {
+		return Report{}, err
+	}
+	name := strings.Split(filepath.Base(fPath), ".")[0]
+	report := Validate(name, data, limits)
+	report.Path = fPath
+	return report, nil
+}
+
+/*
+Validate
+
+Checks an image against a platform's emoji limits and returns a report
+listing every violation rather than stopping at the first one
+*/
+func Validate(name string, data []byte, limits Limits) Report {
+	report := Report{
+		Name: name,
+		Size: int64(len(data)),
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		report.Violations = append(report.Violations, Violation{
+			Kind:    ViolationFormat,
+			Message: fmt.Sprintf("not a supported image (%s): %s", strings.Join(supportedFormats, ", "), err),
+		})
+		return report
+	}
+	report.Format = format
+	report.Width = config.Width
+	report.Height = config.Height
+
+	frames, err := countFrames(format, data)
+	if err != nil {
+		report.Violations = append(report.Violations, Violation{
+			Kind:    ViolationDecode,
+			Message: fmt.Sprintf("unable to decode %s: %s", format, err),
+		})
+		return report
+	}
+	report.Frames = frames
+
+	if limits.MaxDimension > 0 && (config.Width > limits.MaxDimension || config.Height > limits.MaxDimension) {
+		report.Violations = append(report.Violations, Violation{
+			Kind:    ViolationDimensions,
+			Message: fmt.Sprintf("%dx%d is larger than %dx%d", config.Width, config.Height, limits.MaxDimension, limits.MaxDimension),
+		})
+	}
+
+	if limits.MaxFileSize > 0 && report.Size > limits.MaxFileSize {
+		report.Violations = append(report.Violations, Violation{
+			Kind:    ViolationSize,
+			Message: fmt.Sprintf("%d bytes is larger than %d bytes", report.Size, limits.MaxFileSize),
+		})
+	}
+
+	return report
+}
+
+// Verify returns an error if data isn't a complete, decodable emoji image
+func Verify(data []byte) error {
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if _, err := countFrames(format, data); err != nil {
+		return fmt.Errorf("unable to decode %s: %w", format, err)
+	}
+	return nil
+}
+
+// VerifyFile is Verify for an image on disk
+func VerifyFile(fPath string) error {
+	data, err := os.ReadFile(fPath)
+	if err != nil {
+		return err
+	}
+	return Verify(data)
+}
+
+// Valid reports whether the image had no violations
+func (r Report) Valid() bool {
+	return len(r.Violations) == 0
+}
+
+// Fixable reports whether every violation can be solved by resizing and recompressing
+func (r Report) Fixable() bool {
+	for _, violation := range r.Violations {
+		if violation.Kind != ViolationDimensions && violation.Kind != ViolationSize {
+			return false
+		}
+	}
+	return true
+}
+
+func countFrames(format string, data []byte) (int, error) {
+	if format == "gif" {
+		anim, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return 0, err
+		}
+		return len(anim.Image), nil
+	}
+
+	if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
@@ -0,0 +1,111 @@
+package images
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/neko"
+)
+
+func helpNoisyPNG(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	rng := rand.New(rand.NewSource(1))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255})
+		}
+	}
+	buf := new(bytes.Buffer)
+	require.Nil(t, png.Encode(buf, img))
+	return buf.Bytes()
+}
+
+func helpAnimatedGIF(t *testing.T, width, height, frames int) []byte {
+	anim := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		frame := image.NewPaletted(image.Rect(0, 0, width, height), palette.Plan9)
+		for x := 0; x < width; x++ {
+			for y := 0; y < height; y++ {
+				frame.SetColorIndex(x, y, uint8((x+y+i*16)%256))
+			}
+		}
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 10)
+	}
+	buf := new(bytes.Buffer)
+	require.Nil(t, gif.EncodeAll(buf, anim))
+	return buf.Bytes()
+}
+
+func TestValidate(t *testing.T) {
+	tests := neko.Modern(t)
+
+	tests.It("accepts images within the limits", func(t *testing.T) {
+		report := Validate("small", helpNoisyPNG(t, 32, 32), SlackLimits)
+		assert.True(t, report.Valid())
+		assert.Equal(t, "png", report.Format)
+		assert.Equal(t, 1, report.Frames)
+	})
+
+	tests.It("reports every violation", func(t *testing.T) {
+		report := Validate("big", helpNoisyPNG(t, 256, 256), SlackLimits)
+		require.Len(t, report.Violations, 2)
+		assert.Equal(t, ViolationDimensions, report.Violations[0].Kind)
+		assert.Equal(t, ViolationSize, report.Violations[1].Kind)
+		assert.True(t, report.Fixable())
+	})
+
+	tests.It("holds images to the limits it's given", func(t *testing.T) {
+		data := helpNoisyPNG(t, 256, 256)
+		report := Validate("big", data, Limits{MaxDimension: 1024, MaxFileSize: int64(len(data))})
+		assert.True(t, report.Valid(), report.Violations)
+		report = Validate("big", data, Limits{})
+		assert.True(t, report.Valid(), report.Violations)
+		report = Validate("big", data, Limits{MaxFileSize: int64(len(data)) - 1})
+		require.Len(t, report.Violations, 1)
+		assert.Equal(t, ViolationSize, report.Violations[0].Kind)
+	})
+
+	tests.It("rejects unknown formats", func(t *testing.T) {
+		report := Validate("text", []byte("not an image"), SlackLimits)
+		require.Len(t, report.Violations, 1)
+		assert.Equal(t, ViolationFormat, report.Violations[0].Kind)
+		assert.False(t, report.Fixable())
+	})
+
+	tests.Run()
+}
+
+func TestFix(t *testing.T) {
+	tests := neko.Modern(t)
+
+	tests.It("shrinks pngs under the limits", func(t *testing.T) {
+		fixed, err := Fix(helpNoisyPNG(t, 400, 200), SlackLimits)
+		require.Nil(t, err)
+
+		report := Validate("fixed", fixed, SlackLimits)
+		assert.True(t, report.Valid(), report.Violations)
+		assert.Equal(t, "png", report.Format)
+		assert.Equal(t, 2*report.Height, report.Width)
+	})
+
+	tests.It("keeps every frame of an animated gif", func(t *testing.T) {
+		fixed, err := Fix(helpAnimatedGIF(t, 256, 256, 5), SlackLimits)
+		require.Nil(t, err)
+
+		report := Validate("fixed", fixed, SlackLimits)
+		assert.True(t, report.Valid(), report.Violations)
+		assert.Equal(t, "gif", report.Format)
+		assert.Equal(t, 5, report.Frames)
+	})
+
+	tests.Run()
+}
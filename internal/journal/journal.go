@@ -0,0 +1,110 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	OutcomeSucceeded = "succeeded"
+	// OutcomeFailed is for failures that will happen again, like a bad image or a taken name
+	OutcomeFailed = "failed"
+	// OutcomeRetryable is for failures that might not, like rate limits, server and network errors
+	OutcomeRetryable = "retryable"
+)
+
+/*
+Open
+
+Loads the journal at fPath, creating it if needed. Each attempt is
+appended as a line of json, the latest line for a name wins, so a run
+that's killed part way only loses the attempt that was in flight.
+*/
+func Open(fPath string) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(fPath), 0755); err != nil {
+		return nil, err
+	}
+
+	fp, err := os.OpenFile(fPath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	journal := &Journal{
+		fp:      fp,
+		entries: make(map[string]Entry),
+	}
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		entry := Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// a partial last line means we died mid write, the attempt
+			// will just be made again
+			continue
+		}
+		journal.entries[entry.Name] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		fp.Close()
+		return nil, errors.Join(fmt.Errorf("unable to read journal"), err)
+	}
+	if err := terminateLastLine(fp); err != nil {
+		fp.Close()
+		return nil, err
+	}
+
+	return journal, nil
+}
+
+// Lookup returns the latest attempt for the named emoji
+func (j *Journal) Lookup(name string) (Entry, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entry, ok := j.entries[name]
+	return entry, ok
+}
+
+// Record appends an attempt to the journal and syncs it to disk
+func (j *Journal) Record(entry Entry) error {
+	if entry.AttemptedAt == 0 {
+		entry.AttemptedAt = time.Now().Unix()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.fp.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	j.entries[entry.Name] = entry
+	return j.fp.Sync()
+}
+
+func (j *Journal) Close() error {
+	return j.fp.Close()
+}
+
+// terminateLastLine makes sure new entries don't get glued onto a partial line
+func terminateLastLine(fp *os.File) error {
+	info, err := fp.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+
+	last := make([]byte, 1)
+	if _, err := fp.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] != '\n' {
+		_, err = fp.Write([]byte{'\n'})
+	}
+	return err
+}
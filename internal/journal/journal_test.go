@@ -0,0 +1,62 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/neko"
+)
+
+func TestJournal(t *testing.T) {
+	tests := neko.Modern(t)
+
+	tests.It("keeps the latest attempt across reopens", func(t *testing.T) {
+		fPath := filepath.Join(t.TempDir(), ".journal", "my-team.jsonl")
+		journal, err := Open(fPath)
+		require.Nil(t, err)
+
+		require.Nil(t, journal.Record(Entry{Name: "blob", SHA256: "abc", Outcome: OutcomeFailed, Error: "too_large"}))
+		require.Nil(t, journal.Record(Entry{Name: "blob", SHA256: "abc", Outcome: OutcomeSucceeded}))
+		require.Nil(t, journal.Record(Entry{Name: "parrot", SHA256: "def", Outcome: OutcomeFailed}))
+		require.Nil(t, journal.Close())
+
+		reopened, err := Open(fPath)
+		require.Nil(t, err)
+		defer reopened.Close()
+
+		entry, ok := reopened.Lookup("blob")
+		require.True(t, ok)
+		assert.Equal(t, OutcomeSucceeded, entry.Outcome)
+		assert.NotZero(t, entry.AttemptedAt)
+
+		entry, ok = reopened.Lookup("parrot")
+		require.True(t, ok)
+		assert.Equal(t, OutcomeFailed, entry.Outcome)
+
+		_, ok = reopened.Lookup("missing")
+		assert.False(t, ok)
+	})
+
+	tests.It("ignores a partially written last line", func(t *testing.T) {
+		fPath := filepath.Join(t.TempDir(), "my-team.jsonl")
+		require.Nil(t, os.WriteFile(fPath, []byte("{\"name\":\"blob\",\"outcome\":\"succeeded\"}\n{\"name\":\"par"), 0644))
+
+		journal, err := Open(fPath)
+		require.Nil(t, err)
+		_, ok := journal.Lookup("blob")
+		assert.True(t, ok)
+		require.Nil(t, journal.Record(Entry{Name: "parrot", Outcome: OutcomeSucceeded}))
+		require.Nil(t, journal.Close())
+
+		reopened, err := Open(fPath)
+		require.Nil(t, err)
+		defer reopened.Close()
+		_, ok = reopened.Lookup("parrot")
+		assert.True(t, ok)
+	})
+
+	tests.Run()
+}
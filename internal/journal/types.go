@@ -0,0 +1,23 @@
+package journal
+
+import (
+	"os"
+	"sync"
+)
+
+// Entry is one recorded import attempt
+type Entry struct {
+	Name        string `json:"name"`
+	File        string `json:"file,omitempty"`
+	SHA256      string `json:"sha256,omitempty"`
+	Outcome     string `json:"outcome"`
+	Error       string `json:"error,omitempty"`
+	AttemptedAt int64  `json:"attempted_at"`
+}
+
+// Journal is the append-only record of import attempts for one subdomain
+type Journal struct {
+	mu      sync.Mutex
+	fp      *os.File
+	entries map[string]Entry
+}
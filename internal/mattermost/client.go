@@ -0,0 +1,228 @@
+/*
+Package mattermost talks to a Mattermost server's custom emoji REST API
+(v4) with a personal access token, so emoji can be exported from and
+imported into Mattermost like a Slack team.
+*/
+package mattermost
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/erindatkinson/emoji-archiver/internal/httpretry"
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+	"github.com/samber/lo"
+)
+
+const (
+	apiPath      = "/api/v4"
+	listPageSize = 200
+)
+
+var _ platform.Backend = (*Client)(nil)
+
+type Client struct {
+	platform.HTTP
+	Token string
+	// UserID is the token's user, emoji are created as them
+	UserID string
+}
+
+/*
+NewClient
+
+Creates a client for the Mattermost server at serverURL, checking the
+token by looking up the user it belongs to
+*/
+func NewClient(ctx context.Context, serverURL, token string, opts ...platform.Option) (*Client, error) {
+	if serverURL == "" {
+		return nil, errors.New("a mattermost server url is required")
+	}
+	if token == "" {
+		return nil, errors.Join(errors.New("a mattermost access token is required"), slack.ErrAuth)
+	}
+	client := &Client{Token: token}
+	client.HTTP = platform.HTTP{
+		URL:        strings.TrimSuffix(serverURL, "/"),
+		APIPath:    apiPath,
+		HTTPClient: http.DefaultClient,
+		Logger:     utilities.ContextLogger(ctx),
+		Retry:      newRetrier(),
+		Authorize:  client.authorize,
+		Upload:     client.UploadEmoji,
+	}
+	for _, opt := range opts {
+		opt(&client.HTTP)
+	}
+
+	me := user{}
+	if err := client.GetJSON(ctx, "/users/me", &me); err != nil {
+		return nil, err
+	}
+	client.UserID = me.ID
+	return client, nil
+}
+
+/*
+ListEmoji
+
+Pages through the server's custom emoji and looks up who created them.
+Mattermost has no aliases, so none are ever returned.
+*/
+func (c *Client) ListEmoji(ctx context.Context) ([]slack.Emoji, error) {
+	listed := make([]emoji, 0)
+	for page := 0; ; page++ {
+		c.Logger.Debug("Downloading list", "page", page)
+		batch := []emoji{}
+		query := url.Values{"page": {strconv.Itoa(page)}, "per_page": {strconv.Itoa(listPageSize)}, "sort": {"name"}}
+		if err := c.GetJSON(ctx, "/emoji?"+query.Encode(), &batch); err != nil {
+			return []slack.Emoji{}, err
+		}
+		listed = append(listed, batch...)
+		if len(batch) < listPageSize {
+			break
+		}
+	}
+
+	usernames, err := c.usernames(ctx, lo.Uniq(lo.Map(listed, func(e emoji, index int) string {
+		return e.CreatorID
+	})))
+	if err != nil {
+		return []slack.Emoji{}, err
+	}
+	return lo.Map(listed, func(e emoji, index int) slack.Emoji {
+		return slack.Emoji{
+			Name:            e.Name,
+			Created:         e.CreateAt / 1000,
+			URL:             c.Endpoint("/emoji/" + url.PathEscape(e.ID) + "/image"),
+			UserID:          e.CreatorID,
+			UserDisplayName: usernames[e.CreatorID],
+		}
+	}), nil
+}
+
+// UploadEmoji creates a new emoji from an image held in memory
+func (c *Client) UploadEmoji(ctx context.Context, name, filename string, image []byte) error {
+	c.Logger.Debug("importing emoji", "name", name)
+	resp, err := c.Do(ctx, "/emoji", func() (*http.Request, error) {
+		return c.buildUploadRequest(ctx, name, filename, image)
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+/*
+AddAlias
+
+Mattermost has no aliases, so the alias is created as a copy of the
+target's image under the new name
+*/
+func (c *Client) AddAlias(ctx context.Context, name, target string) error {
+	c.Logger.Debug("adding alias as a copy", "name", name, "alias_for", target)
+	existing := emoji{}
+	if err := c.GetJSON(ctx, "/emoji/name/"+url.PathEscape(target), &existing); err != nil {
+		return errors.Join(fmt.Errorf("unable to find %s to copy for alias %s", target, name), err)
+	}
+	data, filename, err := c.Download(ctx, slack.Emoji{
+		Name: target,
+		URL:  c.Endpoint("/emoji/" + url.PathEscape(existing.ID) + "/image"),
+	})
+	if err != nil {
+		return err
+	}
+	return c.UploadEmoji(ctx, name, filename, data)
+}
+
+//========== Private Methods ==========
+
+// usernames looks up the usernames for a set of user ids
+func (c *Client) usernames(ctx context.Context, ids []string) (map[string]string, error) {
+	ids = lo.Compact(ids)
+	names := make(map[string]string, len(ids))
+	if len(ids) == 0 {
+		return names, nil
+	}
+	body, err := json.Marshal(ids)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(ctx, "/users/ids", func() (*http.Request, error) {
+		req, err := c.NewRequest(ctx, http.MethodPost, "/users/ids", bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return req, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	users := []user{}
+	if err := json.NewDecoder(resp.Body).Decode(&users); err != nil {
+		return nil, errors.Join(fmt.Errorf("unable to parse users"), err)
+	}
+	for _, u := range users {
+		names[u.ID] = u.Username
+	}
+	return names, nil
+}
+
+func (c *Client) buildUploadRequest(ctx context.Context, name, filename string, image []byte) (*http.Request, error) {
+	body := new(bytes.Buffer)
+	wrapper := multipart.NewWriter(body)
+	metadata, err := json.Marshal(emoji{Name: name, CreatorID: c.UserID})
+	if err != nil {
+		return nil, err
+	}
+	if err := wrapper.WriteField("emoji", string(metadata)); err != nil {
+		return nil, err
+	}
+	part, err := wrapper.CreateFormFile("image", filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(image); err != nil {
+		return nil, err
+	}
+	if err := wrapper.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := c.NewRequest(ctx, http.MethodPost, "/emoji", body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", wrapper.FormDataContentType())
+	return req, nil
+}
+
+// authorize sends the access token as a bearer token
+func (c *Client) authorize(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+}
+
+// newRetrier retries requests the way mattermost asks
+func newRetrier() httpretry.Retrier {
+	retry := httpretry.New("mattermost")
+	retry.RetryAfter = retryAfter
+	retry.Error = decodeError
+	// looking up users is a POST but only reads
+	retry.Idempotent = func(req *http.Request) bool {
+		return req.Method == http.MethodGet || strings.HasSuffix(req.URL.Path, apiPath+"/users/ids")
+	}
+	return retry
+}
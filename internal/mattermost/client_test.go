@@ -0,0 +1,160 @@
+package mattermost_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/erindatkinson/emoji-archiver/internal/apitest"
+	"github.com/erindatkinson/emoji-archiver/internal/mattermost"
+	"github.com/erindatkinson/emoji-archiver/internal/mattermost/mattermosttest"
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/neko"
+)
+
+func helpNewClient(t *testing.T, opts ...platform.Option) (*mattermost.Client, *mattermosttest.Server) {
+	server := mattermosttest.NewServer()
+	return apitest.NewClient(t, server, opts...), server
+}
+
+func TestClient(t *testing.T) {
+	tests := neko.Modern(t)
+
+	tests.It("looks up the token's user", func(t *testing.T) {
+		client, _ := helpNewClient(t)
+		assert.Equal(t, mattermosttest.UserID, client.UserID)
+	})
+
+	tests.It("rejects a bad token", func(t *testing.T) {
+		server := mattermosttest.NewServer()
+		defer server.Close()
+		ctx := utilities.ToContext(t.Context(), utilities.NewLogger("error"))
+		_, err := mattermost.NewClient(ctx, server.URL, "nope", server.ClientOptions()...)
+		assert.ErrorIs(t, err, slack.ErrAuth)
+	})
+
+	tests.It("lists emoji across pages with their creators", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.AddUser("erinuserid", "erin")
+		for i := 0; i < 205; i++ {
+			server.AddEmoji(slack.Emoji{Name: fmt.Sprintf("emoji-%03d", i), UserID: "erinuserid", Created: 1700000000}, apitest.PNG(t))
+		}
+
+		emoji, err := client.ListEmoji(t.Context())
+		require.Nil(t, err)
+		require.Len(t, emoji, 205)
+		assert.Equal(t, 2, server.Requests(mattermosttest.MethodListEmoji))
+		assert.Equal(t, "emoji-000", emoji[0].Name)
+		assert.Equal(t, int64(1700000000), emoji[0].Created)
+		assert.Equal(t, "erin", emoji[0].UserDisplayName)
+		assert.Equal(t, int64(0), emoji[0].IsAlias)
+	})
+
+	tests.It("exports images with an extension for their format", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.AddEmoji(slack.Emoji{Name: "still"}, apitest.PNG(t))
+		server.AddEmoji(slack.Emoji{Name: "moving"}, apitest.GIF(t))
+		// a 1x1 lossless webp
+		server.AddEmoji(slack.Emoji{Name: "tiny"}, []byte("RIFF\x1a\x00\x00\x00WEBPVP8L\x0d\x00\x00\x00\x2f\x00\x00\x00\x10\x07\x10\x11\x11\x88\x88\xfe\x07\x00"))
+
+		emoji, err := client.ListEmoji(t.Context())
+		require.Nil(t, err)
+		dir := t.TempDir()
+		for _, e := range emoji {
+			_, err := client.ExportEmoji(t.Context(), e, dir)
+			require.Nil(t, err)
+		}
+		entries, err := os.ReadDir(dir)
+		require.Nil(t, err)
+		names := []string{}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		assert.Equal(t, []string{"moving.gif", "still.png", "tiny.webp"}, names)
+	})
+
+	tests.It("uploads emoji as the token's user", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		fPath := filepath.Join(t.TempDir(), "blob.png")
+		require.Nil(t, os.WriteFile(fPath, apitest.PNG(t), 0644))
+
+		require.Nil(t, client.ImportEmoji(t.Context(), "blob", fPath))
+		emoji := server.Emoji()
+		require.Len(t, emoji, 1)
+		assert.Equal(t, "blob", emoji[0].Name)
+		assert.Equal(t, mattermosttest.Username, emoji[0].UserDisplayName)
+	})
+
+	tests.It("copies the target's image for an alias", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.AddEmoji(slack.Emoji{Name: "blob"}, apitest.PNG(t))
+
+		require.Nil(t, client.AddAlias(t.Context(), "blob-too", "blob"))
+		data, ok := server.Image("blob-too")
+		require.True(t, ok)
+		assert.Equal(t, apitest.PNG(t), data)
+
+		assert.NotNil(t, client.AddAlias(t.Context(), "missing-too", "missing"))
+	})
+
+	tests.It("maps app errors onto the slack error kinds", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.AddEmoji(slack.Emoji{Name: "blob"}, apitest.PNG(t))
+
+		err := client.UploadEmoji(t.Context(), "blob", "blob.png", apitest.PNG(t))
+		assert.ErrorIs(t, err, slack.ErrNameTaken)
+		err = client.UploadEmoji(t.Context(), "not a name", "blob.png", apitest.PNG(t))
+		assert.ErrorIs(t, err, slack.ErrInvalidName)
+		err = client.UploadEmoji(t.Context(), "huge", "huge.png", make([]byte, mattermosttest.MaxImageSize+1))
+		assert.ErrorIs(t, err, slack.ErrTooLarge)
+	})
+
+	tests.It("retries when throttled or the server fails", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.AddEmoji(slack.Emoji{Name: "blob"}, apitest.PNG(t))
+		server.InjectFault(mattermosttest.MethodListEmoji, mattermosttest.Fault{Status: http.StatusTooManyRequests})
+		server.InjectFault(mattermosttest.MethodListEmoji, mattermosttest.Fault{Status: http.StatusBadGateway})
+
+		emoji, err := client.ListEmoji(t.Context())
+		require.Nil(t, err)
+		assert.Len(t, emoji, 1)
+		assert.Equal(t, 3, server.Requests(mattermosttest.MethodListEmoji))
+	})
+
+	tests.It("gives up once retries run out", func(t *testing.T) {
+		client, server := helpNewClient(t, platform.WithRetries(1))
+		server.AddEmoji(slack.Emoji{Name: "blob"}, apitest.PNG(t))
+		for range 2 {
+			server.InjectFault(mattermosttest.MethodImage, mattermosttest.Fault{Status: http.StatusTooManyRequests})
+		}
+
+		emoji, err := client.ListEmoji(t.Context())
+		require.Nil(t, err)
+		_, _, err = client.DownloadEmoji(t.Context(), emoji[0])
+		assert.ErrorIs(t, err, slack.ErrRateLimited)
+	})
+
+	tests.It("downloads into memory", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.AddEmoji(slack.Emoji{Name: "blob"}, apitest.PNG(t))
+
+		emoji, err := client.ListEmoji(t.Context())
+		require.Nil(t, err)
+		body, filename, err := client.DownloadEmoji(t.Context(), emoji[0])
+		require.Nil(t, err)
+		defer body.Close()
+		data, err := io.ReadAll(body)
+		require.Nil(t, err)
+		assert.Equal(t, "blob.png", filename)
+		assert.Equal(t, apitest.PNG(t), data)
+	})
+
+	tests.Run()
+}
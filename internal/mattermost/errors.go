@@ -0,0 +1,38 @@
+package mattermost
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+)
+
+// errorIDs maps the ids of Mattermost's app errors onto the slack error kinds
+var errorIDs = map[string]error{
+	"api.emoji.create.duplicate.app_error": slack.ErrNameTaken,
+	"api.emoji.create.too_large.app_error": slack.ErrTooLarge,
+	"api.emoji.create.parse.app_error":     slack.ErrBadImage,
+	"api.emoji.upload.image.app_error":     slack.ErrBadImage,
+	"api.emoji.disabled.app_error":         slack.ErrAuth,
+	"model.emoji.name.app_error":           slack.ErrInvalidName,
+}
+
+// retryAfter reads how long a 429 asks to wait from Retry-After or X-Ratelimit-Reset
+func retryAfter(resp *http.Response) time.Duration {
+	for _, header := range []string{"Retry-After", "X-Ratelimit-Reset"} {
+		if seconds, err := strconv.Atoi(resp.Header.Get(header)); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return 0
+}
+
+// decodeError reads the appError mattermost sends with an error status
+func decodeError(path string, resp *http.Response) error {
+	body := appError{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	return platform.NewAPIError(resp, path, body.ID, body.Message, errorIDs[body.ID])
+}
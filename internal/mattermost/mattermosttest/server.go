@@ -0,0 +1,358 @@
+/*
+Package mattermosttest runs an in memory stand-in for the custom emoji
+parts of Mattermost's v4 api, so the mattermost client and commands can be
+tested without a server.
+*/
+package mattermosttest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/apitest"
+	"github.com/erindatkinson/emoji-archiver/internal/mattermost"
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+)
+
+const (
+	// Token is the personal access token the server accepts
+	Token = "mattermosttest-token"
+	// UserID is the token's user
+	UserID = "mattermosttestuserid00000000"
+	// Username is the token's user's name
+	Username = "mattermosttest"
+)
+
+// Endpoints that faults can be injected into
+const (
+	MethodMe        = "users/me"
+	MethodUsers     = "users/ids"
+	MethodListEmoji = "emoji"
+	MethodGetByName = "emoji/name"
+	MethodAddEmoji  = "emoji/create"
+	MethodImage     = "emoji/image"
+)
+
+// MaxImageSize is the largest image the server accepts, mattermost's default
+const MaxImageSize = 512 * 1024
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9\-+_]{1,64}$`)
+
+// Fault replaces the next response from an endpoint with an error status
+// and, if ID is set, an app error body
+type Fault struct {
+	Status     int
+	RetryAfter int
+	ID         string
+}
+
+type storedEmoji struct {
+	ID        string
+	Name      string
+	CreatorID string
+	CreateAt  int64
+	Image     []byte
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	emoji []storedEmoji
+	users map[string]string
+	apitest.Faults[Fault]
+	nextID int
+}
+
+// NewServer starts a server, close it when done
+func NewServer() *Server {
+	s := &Server{
+		Faults: apitest.Faults[Fault]{Write: writeFault},
+		users:  map[string]string{UserID: Username},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v4/users/me", s.handleMe)
+	mux.HandleFunc("POST /api/v4/users/ids", s.handleUsers)
+	mux.HandleFunc("GET /api/v4/emoji", s.handleListEmoji)
+	mux.HandleFunc("POST /api/v4/emoji", s.handleAddEmoji)
+	// emoji/name/{name} and emoji/{id}/image overlap as patterns, so they share a route
+	mux.HandleFunc("GET /api/v4/emoji/{first}/{second}", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.PathValue("first") == "name":
+			s.handleGetByName(w, r, r.PathValue("second"))
+		case r.PathValue("second") == "image":
+			s.handleImage(w, r, r.PathValue("first"))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// ClientOptions points a mattermost client at the server
+func (s *Server) ClientOptions() []platform.Option {
+	return []platform.Option{
+		platform.WithHTTPClient(s.Client()),
+	}
+}
+
+// Connect creates a mattermost client with the server's token
+func (s *Server) Connect(ctx context.Context, opts ...platform.Option) (*mattermost.Client, error) {
+	return mattermost.NewClient(ctx, s.URL, Token, append(s.ClientOptions(), opts...)...)
+}
+
+// AddUser seeds the server with a user who can be an emoji's creator
+func (s *Server) AddUser(id, username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[id] = username
+}
+
+// AddEmoji seeds the server with an emoji, created by the token's user if emoji.UserID is empty
+func (s *Server) AddEmoji(emoji slack.Emoji, image []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addEmoji(emoji.Name, emoji.UserID, emoji.Created, image)
+}
+
+// Emoji returns the server's emoji as the client lists them
+func (s *Server) Emoji() []slack.Emoji {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	emoji := make([]slack.Emoji, 0, len(s.emoji))
+	for _, stored := range s.emoji {
+		emoji = append(emoji, slack.Emoji{
+			Name:            stored.Name,
+			Created:         stored.CreateAt / 1000,
+			UserID:          stored.CreatorID,
+			UserDisplayName: s.users[stored.CreatorID],
+		})
+	}
+	return emoji
+}
+
+// Image returns the image stored for an emoji
+func (s *Server) Image(name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index := s.find(name); index >= 0 {
+		return s.emoji[index].Image, true
+	}
+	return nil, false
+}
+
+//========== Handlers ==========
+
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodMe) || !s.authorized(w, r) {
+		return
+	}
+	apitest.WriteJSON(w, http.StatusOK, map[string]string{"id": UserID, "username": Username})
+}
+
+func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodUsers) || !s.authorized(w, r) {
+		return
+	}
+	ids := []string{}
+	if err := json.NewDecoder(r.Body).Decode(&ids); err != nil {
+		writeError(w, http.StatusBadRequest, "api.context.invalid_body_param.app_error")
+		return
+	}
+
+	s.mu.Lock()
+	users := make([]map[string]string, 0, len(ids))
+	for _, id := range ids {
+		if username, ok := s.users[id]; ok {
+			users = append(users, map[string]string{"id": id, "username": username})
+		}
+	}
+	s.mu.Unlock()
+	apitest.WriteJSON(w, http.StatusOK, users)
+}
+
+func (s *Server) handleListEmoji(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodListEmoji) || !s.authorized(w, r) {
+		return
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage <= 0 || perPage > 200 {
+		perPage = 60
+	}
+
+	s.mu.Lock()
+	sorted := slices.Clone(s.emoji)
+	s.mu.Unlock()
+	if r.URL.Query().Get("sort") == "name" {
+		slices.SortFunc(sorted, func(a, b storedEmoji) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+	}
+	start := min(max(page, 0)*perPage, len(sorted))
+	end := min(start+perPage, len(sorted))
+	apitest.WriteJSON(w, http.StatusOK, toResponses(sorted[start:end]))
+}
+
+func (s *Server) handleGetByName(w http.ResponseWriter, r *http.Request, name string) {
+	if s.Faulted(w, MethodGetByName) || !s.authorized(w, r) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := s.find(name)
+	if index < 0 {
+		writeError(w, http.StatusNotFound, "store.sql_emoji.get_by_name.app_error")
+		return
+	}
+	apitest.WriteJSON(w, http.StatusOK, toResponses(s.emoji[index : index+1])[0])
+}
+
+func (s *Server) handleAddEmoji(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodAddEmoji) || !s.authorized(w, r) {
+		return
+	}
+	if err := r.ParseMultipartForm(MaxImageSize * 2); err != nil {
+		writeError(w, http.StatusBadRequest, "api.emoji.create.parse.app_error")
+		return
+	}
+	metadata := map[string]string{}
+	if err := json.Unmarshal([]byte(r.FormValue("emoji")), &metadata); err != nil {
+		writeError(w, http.StatusBadRequest, "api.emoji.create.parse.app_error")
+		return
+	}
+	if metadata["creator_id"] != UserID {
+		writeError(w, http.StatusForbidden, "api.emoji.create.other_user.app_error")
+		return
+	}
+	name := metadata["name"]
+	if !validName.MatchString(name) {
+		writeError(w, http.StatusBadRequest, "model.emoji.name.app_error")
+		return
+	}
+	fp, _, err := r.FormFile("image")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "api.emoji.create.parse.app_error")
+		return
+	}
+	defer fp.Close()
+	data, err := io.ReadAll(fp)
+	if err != nil || len(data) == 0 {
+		writeError(w, http.StatusBadRequest, "api.emoji.upload.image.app_error")
+		return
+	}
+	if len(data) > MaxImageSize {
+		writeError(w, http.StatusBadRequest, "api.emoji.create.too_large.app_error")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.find(name) >= 0 {
+		writeError(w, http.StatusBadRequest, "api.emoji.create.duplicate.app_error")
+		return
+	}
+	created := s.addEmoji(name, UserID, 0, data)
+	apitest.WriteJSON(w, http.StatusCreated, toResponses([]storedEmoji{created})[0])
+}
+
+func (s *Server) handleImage(w http.ResponseWriter, r *http.Request, id string) {
+	if s.Faulted(w, MethodImage) || !s.authorized(w, r) {
+		return
+	}
+	s.mu.Lock()
+	index := slices.IndexFunc(s.emoji, func(e storedEmoji) bool {
+		return e.ID == id
+	})
+	var data []byte
+	if index >= 0 {
+		data = s.emoji[index].Image
+	}
+	s.mu.Unlock()
+	if index < 0 {
+		writeError(w, http.StatusNotFound, "store.sql_emoji.get.app_error")
+		return
+	}
+	apitest.WriteImage(w, data)
+}
+
+//========== Helpers ==========
+
+// writeFault sends a fault the way mattermost reports errors
+func writeFault(w http.ResponseWriter, fault Fault) {
+	if fault.RetryAfter > 0 {
+		w.Header().Set("X-Ratelimit-Reset", strconv.Itoa(fault.RetryAfter))
+	}
+	writeError(w, fault.Status, fault.ID)
+}
+
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get("Authorization") != "Bearer "+Token {
+		writeError(w, http.StatusUnauthorized, "api.context.session_expired.app_error")
+		return false
+	}
+	return true
+}
+
+// addEmoji expects the lock to be held
+func (s *Server) addEmoji(name, creatorID string, created int64, image []byte) storedEmoji {
+	s.nextID++
+	if created == 0 {
+		created = time.Now().Unix()
+	}
+	stored := storedEmoji{
+		ID:        fmt.Sprintf("emoji%021d", s.nextID),
+		Name:      name,
+		CreatorID: creatorID,
+		CreateAt:  created * 1000,
+		Image:     image,
+	}
+	if stored.CreatorID == "" {
+		stored.CreatorID = UserID
+	}
+	s.emoji = append(s.emoji, stored)
+	return stored
+}
+
+// find expects the lock to be held
+func (s *Server) find(name string) int {
+	return slices.IndexFunc(s.emoji, func(e storedEmoji) bool {
+		return e.Name == name
+	})
+}
+
+func toResponses(emoji []storedEmoji) []map[string]any {
+	responses := make([]map[string]any, 0, len(emoji))
+	for _, e := range emoji {
+		responses = append(responses, map[string]any{
+			"id":         e.ID,
+			"creator_id": e.CreatorID,
+			"name":       e.Name,
+			"create_at":  e.CreateAt,
+			"update_at":  e.CreateAt,
+			"delete_at":  0,
+		})
+	}
+	return responses
+}
+
+func writeError(w http.ResponseWriter, status int, id string) {
+	if id == "" {
+		w.WriteHeader(status)
+		return
+	}
+	apitest.WriteJSON(w, status, map[string]any{"id": id, "message": id, "status_code": status})
+}
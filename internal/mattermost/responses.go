@@ -0,0 +1,23 @@
+package mattermost
+
+// emoji is a custom emoji as the v4 api describes it, times are in milliseconds
+type emoji struct {
+	ID        string `json:"id,omitempty"`
+	CreatorID string `json:"creator_id"`
+	Name      string `json:"name"`
+	CreateAt  int64  `json:"create_at,omitempty"`
+	UpdateAt  int64  `json:"update_at,omitempty"`
+	DeleteAt  int64  `json:"delete_at,omitempty"`
+}
+
+type user struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+// appError is the body mattermost sends with an error status
+type appError struct {
+	ID         string `json:"id"`
+	Message    string `json:"message"`
+	StatusCode int    `json:"status_code"`
+}
@@ -0,0 +1,202 @@
+package platform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/httpretry"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+)
+
+/*
+HTTP
+
+What every backend talking to a REST api shares: where the api is, the
+http client and how requests to it are retried. Backends embed it and fill
+in how requests are authorized and how emoji are uploaded, and get
+exporting, downloading and importing emoji from it.
+*/
+type HTTP struct {
+	// URL is the server the api is on
+	URL string
+	// APIPath is where on the server the api is
+	APIPath    string
+	UserAgent  string
+	HTTPClient *http.Client
+	Logger     *slog.Logger
+	Retry      httpretry.Retrier
+	// Authorize adds the platform's credentials to a request
+	Authorize func(req *http.Request)
+	// Upload creates an emoji from an image, it's what ImportEmoji calls
+	Upload func(ctx context.Context, name, filename string, image []byte) error
+}
+
+// Option customizes a backend's client
+type Option func(*HTTP)
+
+// WithURL points the client at another server
+func WithURL(serverURL string) Option {
+	return func(h *HTTP) {
+		h.URL = strings.TrimSuffix(serverURL, "/")
+	}
+}
+
+// WithHTTPClient sets the http client used for every request
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(h *HTTP) {
+		h.HTTPClient = httpClient
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request
+func WithUserAgent(userAgent string) Option {
+	return func(h *HTTP) {
+		h.UserAgent = userAgent
+	}
+}
+
+// WithRetries sets how many times throttled or failed requests are retried
+func WithRetries(retries int) Option {
+	return func(h *HTTP) {
+		h.Retry.Retries = retries
+	}
+}
+
+// WithBackoff sets the first and longest delay between retries
+func WithBackoff(base, maximum time.Duration) Option {
+	return func(h *HTTP) {
+		h.Retry.BackoffBase = base
+		h.Retry.BackoffMax = maximum
+	}
+}
+
+// ExportEmoji downloads an emoji's image into dir and returns the filename it was saved as
+func (h *HTTP) ExportEmoji(ctx context.Context, emoji slack.Emoji, dir string) (string, error) {
+	data, name, err := h.Download(ctx, emoji)
+	if err != nil {
+		return "", err
+	}
+	return name, SaveImage(emoji.Name, data, dir, name)
+}
+
+// DownloadEmoji opens an emoji's image for reading along with the filename it should be saved as
+func (h *HTTP) DownloadEmoji(ctx context.Context, emoji slack.Emoji) (io.ReadCloser, string, error) {
+	data, name, err := h.Download(ctx, emoji)
+	if err != nil {
+		return nil, "", err
+	}
+	return io.NopCloser(bytes.NewReader(data)), name, nil
+}
+
+func (h *HTTP) ImportEmoji(ctx context.Context, name, fPath string) error {
+	data, err := os.ReadFile(fPath)
+	if err != nil {
+		return err
+	}
+	return h.Upload(ctx, name, filepath.Base(fPath), data)
+}
+
+/*
+Download
+
+Reads an emoji's image, naming the file after the emoji with the
+extension of its url, or one for its format when the url has none. The
+platform's credentials are only sent when the image is on its server.
+*/
+func (h *HTTP) Download(ctx context.Context, emoji slack.Emoji) ([]byte, string, error) {
+	resp, err := h.Do(ctx, "image", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, emoji.URL, nil)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(emoji.URL, h.URL+"/") {
+			h.Authorize(req)
+		}
+		if h.UserAgent != "" {
+			req.Header.Set("User-Agent", h.UserAgent)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.ContentLength >= 0 && resp.ContentLength != int64(len(data)) {
+		return nil, "", fmt.Errorf("expected %d bytes, got %d", resp.ContentLength, len(data))
+	}
+	ext := imageExtension(data)
+	if uri, err := url.Parse(emoji.URL); err == nil && path.Ext(uri.Path) != "" {
+		ext = path.Ext(uri.Path)
+	}
+	return data, emoji.Name + ext, nil
+}
+
+// GetJSON decodes the response to a GET of an api path into into
+func (h *HTTP) GetJSON(ctx context.Context, apiPath string, into any) error {
+	resp, err := h.Do(ctx, apiPath, func() (*http.Request, error) {
+		return h.NewRequest(ctx, http.MethodGet, apiPath, nil)
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(into); err != nil {
+		return errors.Join(fmt.Errorf("unable to parse response to %s", apiPath), err)
+	}
+	return nil
+}
+
+// NewRequest creates an authorized request to an api path
+func (h *HTTP) NewRequest(ctx context.Context, method, apiPath string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, h.Endpoint(apiPath), body)
+	if err != nil {
+		return nil, err
+	}
+	h.Authorize(req)
+	if h.UserAgent != "" {
+		req.Header.Set("User-Agent", h.UserAgent)
+	}
+	return req, nil
+}
+
+// Endpoint is the full url of an api path
+func (h *HTTP) Endpoint(apiPath string) string {
+	return h.URL + h.APIPath + apiPath
+}
+
+// Do sends the request built by build, retrying it when throttled or when it fails along the way
+func (h *HTTP) Do(ctx context.Context, path string, build func() (*http.Request, error)) (*http.Response, error) {
+	return h.Retry.Do(ctx, h.HTTPClient, h.Logger, path, build)
+}
+
+//========== Private Methods ==========
+
+// imageExtension picks a file extension from an image's content
+func imageExtension(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/gif":
+		return ".gif"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/webp":
+		return ".webp"
+	}
+	return ".png"
+}
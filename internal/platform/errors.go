@@ -0,0 +1,67 @@
+package platform
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+)
+
+// statusKinds covers failures a backend doesn't recognise the code or message of
+var statusKinds = map[int]error{
+	http.StatusUnauthorized:          slack.ErrAuth,
+	http.StatusForbidden:             slack.ErrAuth,
+	http.StatusRequestEntityTooLarge: slack.ErrTooLarge,
+	http.StatusTooManyRequests:       slack.ErrRateLimited,
+}
+
+// APIError is returned when a platform's api responds with an error status
+type APIError struct {
+	Method string
+	Path   string
+	Status int
+	// Code is the platform's own name or number for the error
+	Code    string
+	Message string
+	kind    error
+}
+
+/*
+NewAPIError
+
+Creates the error for a failed response to an api path. kind is the
+slack error kind the backend recognised from the code or message, when
+it's nil the kind comes from the status instead.
+*/
+func NewAPIError(resp *http.Response, path, code, message string, kind error) *APIError {
+	if kind == nil {
+		kind = statusKinds[resp.StatusCode]
+	}
+	return &APIError{
+		Method:  resp.Request.Method,
+		Path:    path,
+		Status:  resp.StatusCode,
+		Code:    code,
+		Message: strings.TrimSpace(message),
+		kind:    kind,
+	}
+}
+
+func (e *APIError) Error() string {
+	detail := fmt.Sprintf("%d", e.Status)
+	if e.Code != "" {
+		detail += " " + e.Code
+	}
+	if e.Message != "" {
+		detail += ": " + e.Message
+	}
+	if e.kind != nil {
+		return fmt.Sprintf("%s %s failed: %s (%s)", e.Method, e.Path, e.kind, detail)
+	}
+	return fmt.Sprintf("%s %s failed: %s", e.Method, e.Path, detail)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.kind
+}
@@ -0,0 +1,110 @@
+/*
+Package platform describes what the archiver needs from a chat platform's
+custom emoji, so commands can export from and import into platforms other
+than Slack. Emoji are described with slack.Emoji on every platform since
+that's what manifests, snapshots and tombstones are made of.
+*/
+package platform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/erindatkinson/emoji-archiver/internal/images"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+)
+
+// Supported platforms
+const (
+	Slack      = "slack"
+	Mattermost = "mattermost"
+	Discord    = "discord"
+	RocketChat = "rocketchat"
+	Zulip      = "zulip"
+)
+
+// Names lists every supported platform
+var Names = []string{Slack, Mattermost, Discord, RocketChat, Zulip}
+
+/*
+Backend
+
+The emoji operations commands run against a platform. Failures are
+reported with the slack error kinds (slack.ErrAuth, slack.ErrNameTaken and
+so on) whatever the platform, so commands handle them the same way.
+*/
+type Backend interface {
+	// ListEmoji returns every custom emoji, aliases included
+	ListEmoji(ctx context.Context) ([]slack.Emoji, error)
+	// ExportEmoji downloads an emoji's image into dir, returning the filename it was saved as
+	ExportEmoji(ctx context.Context, emoji slack.Emoji, dir string) (string, error)
+	// DownloadEmoji opens an emoji's image along with the filename it should be saved as
+	DownloadEmoji(ctx context.Context, emoji slack.Emoji) (io.ReadCloser, string, error)
+	// ImportEmoji creates an emoji from an image file
+	ImportEmoji(ctx context.Context, name, fPath string) error
+	// UploadEmoji creates an emoji from an image held in memory
+	UploadEmoji(ctx context.Context, name, filename string, image []byte) error
+	// AddAlias makes name another name for the existing emoji target
+	AddAlias(ctx context.Context, name, target string) error
+}
+
+var _ Backend = (*slack.Client)(nil)
+
+/*
+ImageLimits
+
+Returns the largest emoji image a platform accepts. Mattermost shrinks
+emoji itself but turns away originals over 1028 pixels, Rocket.Chat and
+Zulip only limit the file size, and Discord's limits are checked while
+planning which emoji get its slots.
+*/
+func ImageLimits(name string) images.Limits {
+	switch name {
+	case Mattermost:
+		return images.Limits{MaxDimension: 1028, MaxFileSize: 512 * 1024}
+	case Discord:
+		return images.Limits{MaxFileSize: 256 * 1024}
+	case RocketChat:
+		return images.Limits{MaxFileSize: 1024 * 1024}
+	case Zulip:
+		return images.Limits{MaxFileSize: 5 * 1024 * 1024}
+	}
+	return images.SlackLimits
+}
+
+// Validate checks name is a supported platform
+func Validate(name string) error {
+	if !slices.Contains(Names, name) {
+		return fmt.Errorf("unknown platform %q, expected one of %v", name, Names)
+	}
+	return nil
+}
+
+/*
+SaveImage
+
+Checks a downloaded image for an emoji and writes it into dir as
+filename. It's written through a temp file so a failed download never
+leaves a broken image behind.
+*/
+func SaveImage(emoji string, data []byte, dir, filename string) error {
+	if err := images.Verify(data); err != nil {
+		return errors.Join(fmt.Errorf("download of %s failed verification", emoji), err)
+	}
+
+	fp, err := os.CreateTemp(dir, "."+filename+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(fp.Name())
+	_, err = fp.Write(data)
+	if err := errors.Join(err, fp.Close()); err != nil {
+		return err
+	}
+	return os.Rename(fp.Name(), filepath.Join(dir, filename))
+}
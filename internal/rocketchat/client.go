@@ -0,0 +1,241 @@
+/*
+Package rocketchat talks to a Rocket.Chat server's custom emoji REST API
+with a personal access token, so emoji can be exported from and imported
+into Rocket.Chat like a Slack team.
+*/
+package rocketchat
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/erindatkinson/emoji-archiver/internal/httpretry"
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+)
+
+const (
+	apiPath      = "/api/v1"
+	listPageSize = 100
+)
+
+var _ platform.Backend = (*Client)(nil)
+
+type Client struct {
+	platform.HTTP
+	UserID string
+	Token  string
+	// Username is the token's user
+	Username string
+
+	// aliasMu serializes AddAlias, which has to read an emoji's aliases
+	// before writing them back, and guards the listing they're read from
+	aliasMu sync.Mutex
+	listed  []emoji
+}
+
+/*
+NewClient
+
+Creates a client for the Rocket.Chat server at serverURL with a personal
+access token and the id of the user it belongs to, checking them by
+looking the user up
+*/
+func NewClient(ctx context.Context, serverURL, userID, token string, opts ...platform.Option) (*Client, error) {
+	if serverURL == "" {
+		return nil, errors.New("a rocket.chat server url is required")
+	}
+	if userID == "" || token == "" {
+		return nil, errors.Join(errors.New("a rocket.chat user id and access token are required"), slack.ErrAuth)
+	}
+	client := &Client{UserID: userID, Token: token}
+	client.HTTP = platform.HTTP{
+		URL:        strings.TrimSuffix(serverURL, "/"),
+		APIPath:    apiPath,
+		HTTPClient: http.DefaultClient,
+		Logger:     utilities.ContextLogger(ctx),
+		Retry:      newRetrier(),
+		Authorize:  client.authorize,
+		Upload:     client.UploadEmoji,
+	}
+	for _, opt := range opts {
+		opt(&client.HTTP)
+	}
+
+	me := user{}
+	if err := client.GetJSON(ctx, "/me", &me); err != nil {
+		return nil, err
+	}
+	client.Username = me.Username
+	return client, nil
+}
+
+/*
+ListEmoji
+
+Pages through the server's custom emoji. Each of an emoji's aliases is
+returned as an alias for it. Rocket.Chat doesn't record who uploaded an
+emoji or when, so Created is when it was last changed.
+*/
+func (c *Client) ListEmoji(ctx context.Context) ([]slack.Emoji, error) {
+	listed, err := c.list(ctx)
+	if err != nil {
+		return []slack.Emoji{}, err
+	}
+	result := make([]slack.Emoji, 0, len(listed))
+	for _, e := range listed {
+		converted := slack.Emoji{
+			Name:    e.Name,
+			Created: e.UpdatedAt.Unix(),
+			URL:     c.imageURL(e),
+		}
+		result = append(result, converted)
+		for _, alias := range e.Aliases {
+			result = append(result, slack.Emoji{
+				Name:     alias,
+				Created:  converted.Created,
+				IsAlias:  1,
+				AliasFor: e.Name,
+				URL:      converted.URL,
+			})
+		}
+	}
+	return result, nil
+}
+
+// UploadEmoji creates a new emoji from an image held in memory
+func (c *Client) UploadEmoji(ctx context.Context, name, filename string, image []byte) error {
+	c.Logger.Debug("importing emoji", "name", name)
+	return c.postForm(ctx, "/emoji-custom.create", map[string]string{"name": name, "aliases": ""}, filename, image)
+}
+
+/*
+AddAlias
+
+Adds name to the aliases of the existing emoji target. Rocket.Chat
+replaces an emoji's aliases wholesale, so calls are serialized and work
+from one listing kept up to date as aliases are added, rather than
+listing every emoji again for each alias.
+*/
+func (c *Client) AddAlias(ctx context.Context, name, target string) error {
+	c.Logger.Debug("adding alias", "name", name, "alias_for", target)
+	c.aliasMu.Lock()
+	defer c.aliasMu.Unlock()
+
+	find := func() int {
+		return slices.IndexFunc(c.listed, func(e emoji) bool {
+			return e.Name == target
+		})
+	}
+	index := find()
+	if index < 0 {
+		// the target may have been uploaded since the last listing
+		listed, err := c.list(ctx)
+		if err != nil {
+			return err
+		}
+		c.listed = listed
+		index = find()
+	}
+	if index < 0 {
+		return fmt.Errorf("unable to find %s to add alias %s to", target, name)
+	}
+	existing := &c.listed[index]
+	aliases := append(slices.Clone(existing.Aliases), name)
+	fields := map[string]string{
+		"_id":     existing.ID,
+		"name":    existing.Name,
+		"aliases": strings.Join(aliases, ","),
+	}
+	if err := c.postForm(ctx, "/emoji-custom.update", fields, "", nil); err != nil {
+		return err
+	}
+	existing.Aliases = aliases
+	return nil
+}
+
+//========== Private Methods ==========
+
+// list pages through emoji-custom.all
+func (c *Client) list(ctx context.Context) ([]emoji, error) {
+	listed := make([]emoji, 0)
+	for offset := 0; ; {
+		c.Logger.Debug("Downloading list", "offset", offset)
+		page := emojiPage{}
+		query := url.Values{"offset": {strconv.Itoa(offset)}, "count": {strconv.Itoa(listPageSize)}}
+		if err := c.GetJSON(ctx, "/emoji-custom.all?"+query.Encode(), &page); err != nil {
+			return nil, err
+		}
+		listed = append(listed, page.Emojis...)
+		offset += len(page.Emojis)
+		if len(page.Emojis) == 0 || offset >= page.Total {
+			return listed, nil
+		}
+	}
+}
+
+// imageURL is where the server hosts an emoji's image, named after it rather than its id
+func (c *Client) imageURL(e emoji) string {
+	return c.URL + "/emoji-custom/" + url.PathEscape(e.Name+"."+e.Extension)
+}
+
+// postForm sends a multipart form, with the image as the emoji field if there is one
+func (c *Client) postForm(ctx context.Context, path string, fields map[string]string, filename string, image []byte) error {
+	resp, err := c.Do(ctx, path, func() (*http.Request, error) {
+		body := new(bytes.Buffer)
+		wrapper := multipart.NewWriter(body)
+		for key, value := range fields {
+			if err := wrapper.WriteField(key, value); err != nil {
+				return nil, err
+			}
+		}
+		if image != nil {
+			part, err := wrapper.CreateFormFile("emoji", filename)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := part.Write(image); err != nil {
+				return nil, err
+			}
+		}
+		if err := wrapper.Close(); err != nil {
+			return nil, err
+		}
+
+		req, err := c.NewRequest(ctx, http.MethodPost, path, body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", wrapper.FormDataContentType())
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// authorize sends the access token and the id of the user it belongs to
+func (c *Client) authorize(req *http.Request) {
+	req.Header.Set("X-User-Id", c.UserID)
+	req.Header.Set("X-Auth-Token", c.Token)
+}
+
+// newRetrier retries requests the way rocket.chat asks
+func newRetrier() httpretry.Retrier {
+	retry := httpretry.New("rocket.chat")
+	retry.RetryAfter = retryAfter
+	retry.Error = decodeError
+	return retry
+}
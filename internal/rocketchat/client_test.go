@@ -0,0 +1,157 @@
+package rocketchat_test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/apitest"
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
+	"github.com/erindatkinson/emoji-archiver/internal/rocketchat"
+	"github.com/erindatkinson/emoji-archiver/internal/rocketchat/rocketchattest"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/neko"
+)
+
+func helpNewClient(t *testing.T, opts ...platform.Option) (*rocketchat.Client, *rocketchattest.Server) {
+	server := rocketchattest.NewServer()
+	return apitest.NewClient(t, server, opts...), server
+}
+
+func TestClient(t *testing.T) {
+	tests := neko.Modern(t)
+
+	tests.It("looks up the token's user", func(t *testing.T) {
+		client, _ := helpNewClient(t)
+		assert.Equal(t, rocketchattest.Username, client.Username)
+	})
+
+	tests.It("rejects a bad token", func(t *testing.T) {
+		server := rocketchattest.NewServer()
+		defer server.Close()
+		ctx := utilities.ToContext(t.Context(), utilities.NewLogger("error"))
+		_, err := rocketchat.NewClient(ctx, server.URL, rocketchattest.UserID, "nope", server.ClientOptions()...)
+		assert.ErrorIs(t, err, slack.ErrAuth)
+	})
+
+	tests.It("lists emoji across pages with their aliases", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		for i := 0; i < 105; i++ {
+			server.AddEmoji(fmt.Sprintf("emoji-%03d", i), nil, apitest.PNG(t))
+		}
+		server.AddEmoji("parrot", []string{"party-parrot"}, apitest.PNG(t))
+
+		emoji, err := client.ListEmoji(t.Context())
+		require.Nil(t, err)
+		require.Len(t, emoji, 107)
+		assert.Equal(t, 2, server.Requests(rocketchattest.MethodListEmoji))
+		assert.Equal(t, "emoji-000", emoji[0].Name)
+		assert.InDelta(t, time.Now().Unix(), emoji[0].Created, 5)
+		assert.Equal(t, slack.Emoji{Name: "party-parrot", Created: emoji[105].Created, IsAlias: 1, AliasFor: "parrot", URL: emoji[105].URL}, emoji[106])
+	})
+
+	tests.It("exports images with the extension they were uploaded with", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.AddEmoji("still", nil, apitest.PNG(t))
+		server.AddEmoji("moving", nil, apitest.GIF(t))
+
+		emoji, err := client.ListEmoji(t.Context())
+		require.Nil(t, err)
+		dir := t.TempDir()
+		for _, e := range emoji {
+			_, err := client.ExportEmoji(t.Context(), e, dir)
+			require.Nil(t, err)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "moving.gif"))
+		require.Nil(t, err)
+		assert.Equal(t, apitest.GIF(t), data)
+		assert.FileExists(t, filepath.Join(dir, "still.png"))
+	})
+
+	tests.It("uploads emoji and adds aliases to them", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		fPath := filepath.Join(t.TempDir(), "blob.png")
+		require.Nil(t, os.WriteFile(fPath, apitest.PNG(t), 0644))
+
+		require.Nil(t, client.ImportEmoji(t.Context(), "blob", fPath))
+		require.Nil(t, client.AddAlias(t.Context(), "blob-too", "blob"))
+		emoji := server.Emoji()
+		require.Len(t, emoji, 1)
+		assert.Equal(t, "blob", emoji[0].Name)
+		assert.Equal(t, []string{"blob-too"}, emoji[0].Aliases)
+		assert.Equal(t, apitest.PNG(t), emoji[0].Image)
+
+		assert.NotNil(t, client.AddAlias(t.Context(), "missing-too", "missing"))
+	})
+
+	tests.It("keeps every alias added concurrently", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.AddEmoji("blob", []string{"blob-alias"}, apitest.PNG(t))
+		server.AddEmoji("parrot", nil, apitest.PNG(t))
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 20)
+		for i := range 20 {
+			target := []string{"blob", "parrot"}[i%2]
+			wg.Go(func() {
+				errs <- client.AddAlias(t.Context(), fmt.Sprintf("%s-%d", target, i), target)
+			})
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.Nil(t, err)
+		}
+
+		emoji := server.Emoji()
+		require.Len(t, emoji, 2)
+		assert.Len(t, emoji[0].Aliases, 11)
+		assert.Contains(t, emoji[0].Aliases, "blob-alias")
+		assert.Len(t, emoji[1].Aliases, 10)
+		assert.Equal(t, 1, server.Requests(rocketchattest.MethodListEmoji))
+	})
+
+	tests.It("maps error types onto the slack error kinds", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.AddEmoji("blob", []string{"blob-alias"}, apitest.PNG(t))
+
+		err := client.UploadEmoji(t.Context(), "blob-alias", "blob.png", apitest.PNG(t))
+		assert.ErrorIs(t, err, slack.ErrNameTaken)
+		err = client.UploadEmoji(t.Context(), "not a name", "blob.png", apitest.PNG(t))
+		assert.ErrorIs(t, err, slack.ErrInvalidName)
+		err = client.UploadEmoji(t.Context(), "huge", "huge.png", append(apitest.PNG(t), make([]byte, rocketchattest.MaxImageSize)...))
+		assert.ErrorIs(t, err, slack.ErrTooLarge)
+		err = client.UploadEmoji(t.Context(), "text", "text.png", []byte("not an image"))
+		assert.ErrorIs(t, err, slack.ErrBadImage)
+	})
+
+	tests.It("waits for the rate limit to reset", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.InjectFault(rocketchattest.MethodListEmoji, rocketchattest.Fault{Status: http.StatusTooManyRequests, ErrorType: "error-too-many-requests", Reset: 200 * time.Millisecond})
+		server.InjectFault(rocketchattest.MethodListEmoji, rocketchattest.Fault{Status: http.StatusBadGateway})
+
+		started := time.Now()
+		_, err := client.ListEmoji(t.Context())
+		require.Nil(t, err)
+		assert.GreaterOrEqual(t, time.Since(started), 150*time.Millisecond)
+		assert.Equal(t, 3, server.Requests(rocketchattest.MethodListEmoji))
+	})
+
+	tests.It("gives up once retries run out", func(t *testing.T) {
+		client, server := helpNewClient(t, platform.WithRetries(1))
+		for range 2 {
+			server.InjectFault(rocketchattest.MethodAddEmoji, rocketchattest.Fault{Status: http.StatusTooManyRequests})
+		}
+		err := client.UploadEmoji(t.Context(), "blob", "blob.png", apitest.PNG(t))
+		assert.ErrorIs(t, err, slack.ErrRateLimited)
+	})
+
+	tests.Run()
+}
@@ -0,0 +1,38 @@
+package rocketchat
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+)
+
+// errorTypes maps Rocket.Chat's error types onto the slack error kinds
+var errorTypes = map[string]error{
+	"Custom_Emoji_Error_Name_Or_Alias_Already_In_Use": slack.ErrNameTaken,
+	"error-input-is-not-a-valid-field":                slack.ErrInvalidName,
+	"error-file-too-large":                            slack.ErrTooLarge,
+	"error-invalid-file-type":                         slack.ErrBadImage,
+	"error-not-allowed":                               slack.ErrAuth,
+	"error-action-not-allowed":                        slack.ErrAuth,
+	"error-too-many-requests":                         slack.ErrRateLimited,
+}
+
+// retryAfter reads how long a 429 asks to wait from X-RateLimit-Reset, which is in unix milliseconds
+func retryAfter(resp *http.Response) time.Duration {
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return max(0, time.Until(time.UnixMilli(reset)))
+}
+
+// decodeError reads the apiError rocket.chat sends with an error status
+func decodeError(path string, resp *http.Response) error {
+	body := apiError{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	return platform.NewAPIError(resp, path, body.ErrorType, body.Error, errorTypes[body.ErrorType])
+}
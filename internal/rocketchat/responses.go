@@ -0,0 +1,32 @@
+package rocketchat
+
+import "time"
+
+// emoji is a custom emoji as the rest api describes it, aliases share the emoji's image
+type emoji struct {
+	ID        string    `json:"_id"`
+	Name      string    `json:"name"`
+	Aliases   []string  `json:"aliases"`
+	Extension string    `json:"extension"`
+	UpdatedAt time.Time `json:"_updatedAt"`
+}
+
+// emojiPage is one page of emoji-custom.all
+type emojiPage struct {
+	Emojis []emoji `json:"emojis"`
+	Count  int     `json:"count"`
+	Offset int     `json:"offset"`
+	Total  int     `json:"total"`
+}
+
+type user struct {
+	ID       string `json:"_id"`
+	Username string `json:"username"`
+}
+
+// apiError is the body rocket.chat sends with an error status
+type apiError struct {
+	Success   bool   `json:"success"`
+	Error     string `json:"error"`
+	ErrorType string `json:"errorType"`
+}
@@ -0,0 +1,340 @@
+/*
+Package rocketchattest runs an in memory stand-in for the custom emoji
+parts of Rocket.Chat's rest api, so the rocketchat client and commands can
+be tested without a server.
+*/
+package rocketchattest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/apitest"
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
+	"github.com/erindatkinson/emoji-archiver/internal/rocketchat"
+)
+
+const (
+	// UserID and Token are the credentials the server accepts
+	UserID = "rocketchattestuserid"
+	Token  = "rocketchattest-token"
+	// Username is the token's user's name
+	Username = "rocketchattest"
+)
+
+// Endpoints that faults can be injected into
+const (
+	MethodMe          = "me"
+	MethodListEmoji   = "emoji-custom.all"
+	MethodAddEmoji    = "emoji-custom.create"
+	MethodUpdateEmoji = "emoji-custom.update"
+	MethodImage       = "image"
+)
+
+// MaxImageSize is the largest image the server accepts
+const MaxImageSize = 1024 * 1024
+
+var validName = regexp.MustCompile(`^[0-9a-zA-Z\-_+;.]+$`)
+
+// Fault replaces the next response from an endpoint with an error status
+// and, if ErrorType is set, an error body. Reset sets X-RateLimit-Reset
+// that far in the future.
+type Fault struct {
+	Status    int
+	ErrorType string
+	Reset     time.Duration
+}
+
+// Emoji is an emoji stored on the server
+type Emoji struct {
+	ID        string
+	Name      string
+	Aliases   []string
+	Extension string
+	UpdatedAt time.Time
+	Image     []byte
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	emoji []Emoji
+	apitest.Faults[Fault]
+	nextID int
+}
+
+// NewServer starts a server, close it when done
+func NewServer() *Server {
+	s := &Server{Faults: apitest.Faults[Fault]{Write: writeFault}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/me", s.handleMe)
+	mux.HandleFunc("GET /api/v1/emoji-custom.all", s.handleListEmoji)
+	mux.HandleFunc("POST /api/v1/emoji-custom.create", s.handleAddEmoji)
+	mux.HandleFunc("POST /api/v1/emoji-custom.update", s.handleUpdateEmoji)
+	mux.HandleFunc("GET /emoji-custom/{file}", s.handleImage)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// ClientOptions points a rocketchat client at the server
+func (s *Server) ClientOptions() []platform.Option {
+	return []platform.Option{
+		platform.WithHTTPClient(s.Client()),
+	}
+}
+
+// Connect creates a rocket.chat client with the server's user and token
+func (s *Server) Connect(ctx context.Context, opts ...platform.Option) (*rocketchat.Client, error) {
+	return rocketchat.NewClient(ctx, s.URL, UserID, Token, append(s.ClientOptions(), opts...)...)
+}
+
+// AddEmoji seeds the server with an emoji
+func (s *Server) AddEmoji(name string, aliases []string, image []byte) Emoji {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addEmoji(name, aliases, image)
+}
+
+// Emoji returns the server's emoji
+func (s *Server) Emoji() []Emoji {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.emoji)
+}
+
+//========== Handlers ==========
+
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodMe) || !s.authorized(w, r) {
+		return
+	}
+	apitest.WriteJSON(w, http.StatusOK, map[string]any{"_id": UserID, "username": Username, "success": true})
+}
+
+func (s *Server) handleListEmoji(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodListEmoji) || !s.authorized(w, r) {
+		return
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+	if count <= 0 || count > 100 {
+		count = 50
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	start := min(max(offset, 0), len(s.emoji))
+	end := min(start+count, len(s.emoji))
+	page := make([]map[string]any, 0, end-start)
+	for _, e := range s.emoji[start:end] {
+		page = append(page, map[string]any{
+			"_id":        e.ID,
+			"name":       e.Name,
+			"aliases":    e.Aliases,
+			"extension":  e.Extension,
+			"_updatedAt": e.UpdatedAt.Format(time.RFC3339Nano),
+		})
+	}
+	apitest.WriteJSON(w, http.StatusOK, map[string]any{
+		"emojis":  page,
+		"count":   len(page),
+		"offset":  start,
+		"total":   len(s.emoji),
+		"success": true,
+	})
+}
+
+func (s *Server) handleAddEmoji(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodAddEmoji) || !s.authorized(w, r) {
+		return
+	}
+	name, aliases, image, ok := s.readForm(w, r)
+	if !ok {
+		return
+	}
+	if image == nil {
+		writeError(w, http.StatusBadRequest, "error-invalid-file-type", "The emoji file is required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, taken := range append([]string{name}, aliases...) {
+		if s.find(taken) >= 0 {
+			writeError(w, http.StatusBadRequest, "Custom_Emoji_Error_Name_Or_Alias_Already_In_Use", "The custom emoji or one of its aliases is already in use")
+			return
+		}
+	}
+	s.addEmoji(name, aliases, image)
+	apitest.WriteJSON(w, http.StatusOK, map[string]any{"success": true})
+}
+
+func (s *Server) handleUpdateEmoji(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodUpdateEmoji) || !s.authorized(w, r) {
+		return
+	}
+	name, aliases, image, ok := s.readForm(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := slices.IndexFunc(s.emoji, func(e Emoji) bool {
+		return e.ID == r.FormValue("_id")
+	})
+	if index < 0 {
+		writeError(w, http.StatusBadRequest, "Custom_Emoji_Error_Invalid_Emoji", "The emoji to update does not exist")
+		return
+	}
+	for _, taken := range append([]string{name}, aliases...) {
+		if found := s.find(taken); found >= 0 && found != index {
+			writeError(w, http.StatusBadRequest, "Custom_Emoji_Error_Name_Or_Alias_Already_In_Use", "The custom emoji or one of its aliases is already in use")
+			return
+		}
+	}
+	s.emoji[index].Name = name
+	s.emoji[index].Aliases = aliases
+	s.emoji[index].UpdatedAt = time.Now().UTC()
+	if image != nil {
+		s.emoji[index].Image = image
+		s.emoji[index].Extension = extension(image)
+	}
+	apitest.WriteJSON(w, http.StatusOK, map[string]any{"success": true})
+}
+
+func (s *Server) handleImage(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodImage) {
+		return
+	}
+	file := r.PathValue("file")
+	s.mu.Lock()
+	index := slices.IndexFunc(s.emoji, func(e Emoji) bool {
+		return e.Name+"."+e.Extension == file
+	})
+	var data []byte
+	if index >= 0 {
+		data = s.emoji[index].Image
+	}
+	s.mu.Unlock()
+	if index < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	apitest.WriteImage(w, data)
+}
+
+//========== Helpers ==========
+
+// writeFault sends a fault the way rocket.chat reports errors
+func writeFault(w http.ResponseWriter, fault Fault) {
+	if fault.Reset > 0 {
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(fault.Reset).UnixMilli(), 10))
+	}
+	writeError(w, fault.Status, fault.ErrorType, http.StatusText(fault.Status))
+}
+
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get("X-User-Id") != UserID || r.Header.Get("X-Auth-Token") != Token {
+		writeError(w, http.StatusUnauthorized, "", "You must be logged in to do this.")
+		return false
+	}
+	return true
+}
+
+// readForm reads and checks the fields shared by create and update, image is nil when no file was sent
+func (s *Server) readForm(w http.ResponseWriter, r *http.Request) (string, []string, []byte, bool) {
+	if err := r.ParseMultipartForm(MaxImageSize * 2); err != nil {
+		writeError(w, http.StatusBadRequest, "error-invalid-params", err.Error())
+		return "", nil, nil, false
+	}
+	name := r.FormValue("name")
+	aliases := []string{}
+	for _, alias := range strings.Split(r.FormValue("aliases"), ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			aliases = append(aliases, alias)
+		}
+	}
+	for _, check := range append([]string{name}, aliases...) {
+		if !validName.MatchString(check) {
+			writeError(w, http.StatusBadRequest, "error-input-is-not-a-valid-field", fmt.Sprintf("%s is not a valid name", check))
+			return "", nil, nil, false
+		}
+	}
+
+	fp, _, err := r.FormFile("emoji")
+	if err == http.ErrMissingFile {
+		return name, aliases, nil, true
+	} else if err != nil {
+		writeError(w, http.StatusBadRequest, "error-invalid-params", err.Error())
+		return "", nil, nil, false
+	}
+	defer fp.Close()
+	image, err := io.ReadAll(fp)
+	switch {
+	case err != nil:
+		writeError(w, http.StatusBadRequest, "error-invalid-params", err.Error())
+		return "", nil, nil, false
+	case len(image) > MaxImageSize:
+		writeError(w, http.StatusBadRequest, "error-file-too-large", "File is too large")
+		return "", nil, nil, false
+	case !strings.HasPrefix(http.DetectContentType(image), "image/"):
+		writeError(w, http.StatusBadRequest, "error-invalid-file-type", "File type is not accepted")
+		return "", nil, nil, false
+	}
+	return name, aliases, image, true
+}
+
+// addEmoji expects the lock to be held
+func (s *Server) addEmoji(name string, aliases []string, image []byte) Emoji {
+	s.nextID++
+	stored := Emoji{
+		ID:        fmt.Sprintf("emoji%012d", s.nextID),
+		Name:      name,
+		Aliases:   aliases,
+		Extension: extension(image),
+		UpdatedAt: time.Now().UTC(),
+		Image:     image,
+	}
+	if stored.Aliases == nil {
+		stored.Aliases = []string{}
+	}
+	s.emoji = append(s.emoji, stored)
+	return stored
+}
+
+// find returns the emoji using name as its name or an alias, it expects the lock to be held
+func (s *Server) find(name string) int {
+	return slices.IndexFunc(s.emoji, func(e Emoji) bool {
+		return e.Name == name || slices.Contains(e.Aliases, name)
+	})
+}
+
+func extension(image []byte) string {
+	switch http.DetectContentType(image) {
+	case "image/gif":
+		return "gif"
+	case "image/jpeg":
+		return "jpg"
+	}
+	return "png"
+}
+
+func writeError(w http.ResponseWriter, status int, errorType, message string) {
+	body := map[string]any{"success": false, "error": message}
+	if errorType != "" {
+		body["errorType"] = errorType
+	}
+	apitest.WriteJSON(w, status, body)
+}
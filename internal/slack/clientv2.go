@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"mime/multipart"
 	"net/http"
 	"net/url"
@@ -15,58 +16,73 @@ import (
 	"path/filepath"
 	"regexp"
 	"strconv"
-	"time"
+	"sync"
 
-	"github.com/browserutils/kooky"
-	_ "github.com/browserutils/kooky/browser/all"
+	"github.com/erindatkinson/emoji-archiver/internal/httpretry"
+	"github.com/erindatkinson/emoji-archiver/internal/images"
 	"github.com/erindatkinson/emoji-archiver/internal/utilities"
 )
 
-const (
-	postMessageAPIEndpoint     = "https://slack.com/api/chat.postMessage"
-	listEmojiAPITemplateString = "https://%s.slack.com/api/emoji.adminList"
-	addEmojiAPITemplateString  = "https://%s.slack.com/api/emoji.add"
-)
-
 type Client struct {
-	XOXD      string
-	XOXC      string
-	Subdomain string
-	Logger    *slog.Logger
+	XOXD         string
+	XOXC         string
+	Subdomain    string
+	APIURL       string
+	WorkspaceURL string
+	UserAgent    string
+	HTTPClient   *http.Client
+	Logger       *slog.Logger
+
+	limits     map[string]int
+	limitersMu sync.Mutex
+	limiters   map[string]*limiter
+	retry      httpretry.Retrier
 }
 
-func NewSlackClient(ctx context.Context, browser, profile, subdomain string) (*Client, error) {
+func NewSlackClient(ctx context.Context, subdomain string, provider CredentialProvider, opts ...ClientOption) (*Client, error) {
 	client := &Client{
-		Subdomain: subdomain,
-		Logger:    utilities.ContextLogger(ctx),
+		Subdomain:    subdomain,
+		APIURL:       defaultAPIURL,
+		WorkspaceURL: defaultWorkspaceURL,
+		HTTPClient:   http.DefaultClient,
+		Logger:       utilities.ContextLogger(ctx),
+		limits:       maps.Clone(methodLimits),
+		limiters:     make(map[string]*limiter),
+		retry:        newRetrier(),
+	}
+	for _, opt := range opts {
+		opt(client)
 	}
-	if err := client.setXOXDFromCookie(ctx, browser, profile); err != nil {
+	creds, err := provider.Credentials(ctx, subdomain)
+	if err != nil {
 		return nil, err
 	}
+	client.XOXD = creds.XOXD
+	client.XOXC = creds.XOXC
 
-	if err := client.setXOXCToken(); err != nil {
-		return nil, err
+	if client.XOXC == "" {
+		if err := client.setXOXCToken(ctx); err != nil {
+			return nil, err
+		}
 	}
 
 	return client, nil
 }
 
-func (c *Client) RefreshToken() error {
-	return c.setXOXCToken()
+func (c *Client) RefreshToken(ctx context.Context) error {
+	return c.setXOXCToken(ctx)
 }
 
 // PostMessage posts a message to the channel specified
-func (c *Client) PostMessage(channel, message string, threadTs *string) (map[string]any, error) {
-	req, err := c.buildMessageRequest(channel, message, threadTs)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := http.DefaultClient.Do(req)
+func (c *Client) PostMessage(ctx context.Context, channel, message string, threadTs *string) (map[string]any, error) {
+	resp, err := c.do(ctx, "chat.postMessage", func() (*http.Request, error) {
+		return c.buildMessageRequest(ctx, channel, message, threadTs)
+	})
 	if err != nil {
 		return nil, errors.Join(fmt.Errorf("unable to make request"), err)
 
 	}
+	defer resp.Body.Close()
 
 	data := make(map[string]any)
 	err = json.NewDecoder(resp.Body).Decode(&data)
@@ -74,30 +90,29 @@ func (c *Client) PostMessage(channel, message string, threadTs *string) (map[str
 		return nil, errors.Join(fmt.Errorf("unable to parse response"), err)
 	}
 
-	resp.Body.Close()
 	return data, nil
 }
 
-func (c *Client) ListEmoji() ([]Emoji, error) {
+func (c *Client) ListEmoji(ctx context.Context) ([]Emoji, error) {
 	emojis := make([]Emoji, 0)
 
 	page := 1
 	for {
 		c.Logger.Debug("Downloading list", "page", page)
-		req, err := c.buildListRequest(page)
-		resp, err := http.DefaultClient.Do(req)
+		resp, err := c.do(ctx, "emoji.adminList", func() (*http.Request, error) {
+			return c.buildListRequest(ctx, page)
+		})
 		if err != nil {
 			return []Emoji{}, err
 		}
 
 		data := EmojiList{}
 		err = json.NewDecoder(resp.Body).Decode(&data)
+		resp.Body.Close()
 		if err != nil {
 			return []Emoji{}, err
 		}
 
-		resp.Body.Close()
-
 		if data.Ok {
 			emojis = append(emojis, data.Emoji...)
 			if data.Paging.Page+1 > data.Paging.Pages {
@@ -106,107 +121,157 @@ func (c *Client) ListEmoji() ([]Emoji, error) {
 				page++
 			}
 		} else {
-			return []Emoji{}, fmt.Errorf("response ok: false: %s", resp.Header["X-Slack-Failure"])
+			return []Emoji{}, newAPIError("emoji.adminList", data.Error)
 		}
 	}
 }
 
-func (c *Client) ExportEmoji(emoji Emoji, dir string) error {
-	name, err := parseFile(emoji.URL)
+/*
+ExportEmoji
+
+Downloads an emoji's image into dir and returns the filename it was saved
+as. The image is written to a temp file, checked against the response's
+Content-Length and decoded before being renamed into place, so a failed or
+cancelled download never leaves a truncated image behind.
+*/
+func (c *Client) ExportEmoji(ctx context.Context, emoji Emoji, dir string) (string, error) {
+	resp, name, err := c.download(ctx, emoji)
 	if err != nil {
-		return err
+		return "", err
 	}
+	defer resp.Body.Close()
 
-	fp, err := os.Create(filepath.Join(dir, name))
+	fp, err := os.CreateTemp(dir, "."+name+".tmp-*")
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer fp.Close()
+	defer os.Remove(fp.Name())
 
-	resp, err := http.Get(emoji.URL)
+	written, err := io.Copy(fp, resp.Body)
+	err = errors.Join(err, fp.Close())
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("bad request (%d)", resp.StatusCode)
+	if err := verifyDownload(resp.ContentLength, written, fp.Name()); err != nil {
+		return "", errors.Join(fmt.Errorf("download of %s failed verification", emoji.Name), err)
 	}
 
-	fp.ReadFrom(resp.Body)
-	return nil
+	return name, os.Rename(fp.Name(), filepath.Join(dir, name))
 }
 
-func (c *Client) ImportEmoji(name, fPath string) error {
-	c.Logger.Debug("importing emoji", "name", name)
-	req, err := c.buildImportRequest(name, fPath)
+// DownloadEmoji opens an emoji's image for reading along with the filename it should be saved as
+func (c *Client) DownloadEmoji(ctx context.Context, emoji Emoji) (io.ReadCloser, string, error) {
+	resp, name, err := c.download(ctx, emoji)
+	if err != nil {
+		return nil, "", err
+	}
+	return resp.Body, name, nil
+}
+
+func (c *Client) ImportEmoji(ctx context.Context, name, fPath string) error {
+	data, err := os.ReadFile(fPath)
 	if err != nil {
 		return err
 	}
+	return c.UploadEmoji(ctx, name, filepath.Base(fPath), data)
+}
 
-	for attempts := 0; attempts < 3; attempts++ {
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			return err
-		}
-		if resp.StatusCode == http.StatusTooManyRequests {
-			retry := resp.Header.Get("Retry-After")
-			seconds, err := strconv.Atoi(retry)
-			if err != nil {
-				// If working behind a proxy, 429 may be returned with
-				// stripped headers, fallback to 30s if no retry-after
-				seconds = 30
-			}
-			time.Sleep(time.Duration(seconds) * time.Second)
-			continue
-		}
+// UploadEmoji creates a new emoji from an image held in memory
+func (c *Client) UploadEmoji(ctx context.Context, name, filename string, image []byte) error {
+	c.Logger.Debug("importing emoji", "name", name)
+	return c.addEmoji(ctx, name, func() (*http.Request, error) {
+		return c.buildImportRequest(ctx, name, filename, image)
+	})
+}
 
-		defer resp.Body.Close()
+// AddAlias creates name as an alias of the existing emoji target
+func (c *Client) AddAlias(ctx context.Context, name, target string) error {
+	c.Logger.Debug("adding alias", "name", name, "alias_for", target)
+	return c.addEmoji(ctx, name, func() (*http.Request, error) {
+		return c.buildAliasRequest(ctx, name, target)
+	})
+}
 
-		var data map[string]any
-		err = json.NewDecoder(resp.Body).Decode(&data)
-		if err != nil {
-			return err
-		}
-		c.Logger.Debug("response", "code", resp.StatusCode, "data", data, "name", name, "path", fPath)
-		return nil
+//========== Private Methods ==========
+
+// addEmoji sends an emoji.add request and turns an ok: false reply into an APIError
+func (c *Client) addEmoji(ctx context.Context, name string, build func() (*http.Request, error)) error {
+	resp, err := c.do(ctx, "emoji.add", build)
+	if err != nil {
+		return err
 	}
+	defer resp.Body.Close()
 
-	return fmt.Errorf("attempted 3 times and failed")
+	data := apiResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&data)
+	if err != nil {
+		return errors.Join(fmt.Errorf("unable to parse response (%d)", resp.StatusCode), err)
+	}
+	c.Logger.Debug("response", "code", resp.StatusCode, "data", data, "name", name)
+	if !data.Ok {
+		return newAPIError("emoji.add", data.Error)
+	}
+	return nil
 }
 
-//========== Private Methods ==========
+// download fetches an emoji's image, returning the response and the filename to save it as
+func (c *Client) download(ctx context.Context, emoji Emoji) (*http.Response, string, error) {
+	name, err := parseFile(emoji.URL)
+	if err != nil {
+		return nil, "", err
+	}
 
-// GetXOXDFromCookie gets the long running token from your cookie store
-func (c *Client) setXOXDFromCookie(ctx context.Context, browser, profile string) error {
-	c.Logger.Info("getting cookies from browser", "browser", browser, "profile", profile)
-	stores := kooky.FindAllCookieStores(ctx)
-	site, _ := url.Parse(fmt.Sprintf("https://%s.slack.com", c.Subdomain))
-	for _, store := range stores {
-		if store.Browser() == browser && store.Profile() == profile {
-			for _, cookie := range store.Cookies(site) {
-				if cookie.Name == "d" {
-					c.XOXD = cookie.Value
-					return nil
-				}
-			}
+	resp, err := c.do(ctx, "image", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, emoji.URL, nil)
+		if err != nil {
+			return nil, err
 		}
+		if c.UserAgent != "" {
+			req.Header.Set("User-Agent", c.UserAgent)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return nil, "", fmt.Errorf("bad request (%d)", resp.StatusCode)
 	}
 
-	return fmt.Errorf("no cookie found in cookie stores for subdomain")
+	return resp, name, nil
+}
+
+// verifyDownload checks a downloaded file is as long as the server said it
+// would be and is an image we can decode
+func verifyDownload(contentLength, written int64, fPath string) error {
+	if contentLength >= 0 && contentLength != written {
+		return fmt.Errorf("expected %d bytes, got %d", contentLength, written)
+	}
+	return images.VerifyFile(fPath)
 }
 
 // GetXOXCToken requests a new xoxc token from slack given your xoxd token
-func (c *Client) setXOXCToken() error {
+func (c *Client) setXOXCToken(ctx context.Context) error {
 	c.Logger.Debug("getting xoxc token")
-	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("https://%s.slack.com", c.Subdomain), nil)
-	if err != nil {
-		return errors.Join(fmt.Errorf("error building request"), err)
-	}
+	resp, err := c.do(ctx, "bootstrap", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.workspaceURL(), nil)
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("error building request"), err)
+		}
 
-	req.Header.Set("Cookie", fmt.Sprintf("d=%s", c.XOXD))
-	resp, err := http.DefaultClient.Do(req)
+		req.Header.Set("Cookie", fmt.Sprintf("d=%s", c.XOXD))
+		if c.UserAgent != "" {
+			req.Header.Set("User-Agent", c.UserAgent)
+		}
+		return req, nil
+	})
 	if err != nil {
-		return errors.Join(fmt.Errorf("unable to complete request - status_code: %d", resp.StatusCode), err)
+		return errors.Join(fmt.Errorf("unable to complete request"), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to complete request - status_code: %d", resp.StatusCode)
 	}
 
 	data, err := io.ReadAll(resp.Body)
@@ -219,7 +284,7 @@ func (c *Client) setXOXCToken() error {
 	return nil
 }
 
-func (c *Client) buildListRequest(page int) (*http.Request, error) {
+func (c *Client) buildListRequest(ctx context.Context, page int) (*http.Request, error) {
 	params := url.Values{}
 	params.Set("query", "")
 	params.Set("page", strconv.Itoa(page))
@@ -227,9 +292,10 @@ func (c *Client) buildListRequest(page int) (*http.Request, error) {
 	params.Set("token", c.XOXC)
 	payload := bytes.NewBufferString(params.Encode())
 
-	req, err := http.NewRequest(
+	req, err := http.NewRequestWithContext(
+		ctx,
 		http.MethodPost,
-		fmt.Sprintf(listEmojiAPITemplateString, c.Subdomain),
+		c.workspaceEndpoint("emoji.adminList"),
 		payload)
 	if err != nil {
 		return nil, err
@@ -238,7 +304,7 @@ func (c *Client) buildListRequest(page int) (*http.Request, error) {
 	return req, nil
 }
 
-func (c *Client) buildImportRequest(name, fPath string) (*http.Request, error) {
+func (c *Client) buildImportRequest(ctx context.Context, name, filename string, image []byte) (*http.Request, error) {
 
 	buf := new(bytes.Buffer)
 	writer := multipart.NewWriter(buf)
@@ -246,17 +312,35 @@ func (c *Client) buildImportRequest(name, fPath string) (*http.Request, error) {
 	addField(writer, "mode", "data")
 	addField(writer, "name", name)
 	addField(writer, "token", c.XOXC)
-	imgWriter, _ := writer.CreateFormFile("image", filepath.Base(fPath))
-	fp, err := os.Open(fPath)
+	imgWriter, err := writer.CreateFormFile("image", filename)
+	if err != nil {
+		return nil, err
+	}
+	imgWriter.Write(image)
+	writer.Close()
+	contentType := writer.FormDataContentType()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.workspaceEndpoint("emoji.add"), buf)
 	if err != nil {
 		return nil, err
 	}
-	defer fp.Close()
-	io.Copy(imgWriter, fp)
+	c.setHeaders(req)
+	req.Header.Set("Content-Type", contentType)
+	return req, nil
+}
+
+func (c *Client) buildAliasRequest(ctx context.Context, name, target string) (*http.Request, error) {
+	buf := new(bytes.Buffer)
+	writer := multipart.NewWriter(buf)
+
+	addField(writer, "mode", "alias")
+	addField(writer, "name", name)
+	addField(writer, "alias_for", target)
+	addField(writer, "token", c.XOXC)
 	writer.Close()
 	contentType := writer.FormDataContentType()
 
-	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(addEmojiAPITemplateString, c.Subdomain), buf)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.workspaceEndpoint("emoji.add"), buf)
 	if err != nil {
 		return nil, err
 	}
@@ -275,7 +359,7 @@ func addField(wrapper *multipart.Writer, name, data string) error {
 }
 
 // buildRequest creates the http post request object for writing a message to a slack channel
-func (c *Client) buildMessageRequest(channel, message string, threadTs *string) (*http.Request, error) {
+func (c *Client) buildMessageRequest(ctx context.Context, channel, message string, threadTs *string) (*http.Request, error) {
 	params := url.Values{}
 	params.Set("token", c.XOXC)
 	params.Set("channel", channel)
@@ -286,8 +370,8 @@ func (c *Client) buildMessageRequest(channel, message string, threadTs *string)
 	params.Set("markdown_text", message+"\n(This was sent via API)")
 	payload := bytes.NewBufferString(params.Encode())
 
-	req, err := http.NewRequest(
-		http.MethodPost, postMessageAPIEndpoint, payload)
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, c.apiEndpoint("chat.postMessage"), payload)
 	if err != nil {
 		return nil, errors.Join(fmt.Errorf("unable to build request"), err)
 	}
@@ -299,4 +383,7 @@ func (c *Client) setHeaders(req *http.Request) {
 	req.Header.Set("Accept-Encoding", "identity")
 	req.Header.Set("Cookie", fmt.Sprintf("d=%s", c.XOXD))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
 }
@@ -0,0 +1,223 @@
+package slack_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/apitest"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+	"github.com/erindatkinson/emoji-archiver/internal/slack/slacktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/neko"
+)
+
+func helpNewClient(t *testing.T, opts ...slack.ClientOption) (*slack.Client, *slacktest.Server) {
+	server := slacktest.NewServer()
+	t.Cleanup(server.Close)
+
+	ctx := apitest.Context()
+	// keep retries quick and don't hold tests to slack's real rate limits
+	defaults := []slack.ClientOption{
+		slack.WithBackoff(apitest.BackoffBase, apitest.BackoffMax),
+		slack.WithRateLimit(slacktest.MethodListEmoji, 0),
+		slack.WithRateLimit(slacktest.MethodAddEmoji, 0),
+		slack.WithRateLimit(slacktest.MethodPostMessage, 0),
+	}
+	opts = append(append(server.ClientOptions(), defaults...), opts...)
+	client, err := slack.NewSlackClient(ctx, "my-team", server.Credentials(), opts...)
+	require.Nil(t, err)
+	return client, server
+}
+
+func TestClient(t *testing.T) {
+	tests := neko.Modern(t)
+
+	tests.It("bootstraps an xoxc token", func(t *testing.T) {
+		client, _ := helpNewClient(t)
+		assert.Equal(t, slacktest.XOXC, client.XOXC)
+	})
+
+	tests.It("lists emoji across pages", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.PageSize = 2
+		for i := 0; i < 5; i++ {
+			server.AddEmoji(slack.Emoji{Name: fmt.Sprintf("emoji-%d", i)}, []byte("image"))
+		}
+
+		emojis, err := client.ListEmoji(t.Context())
+		require.Nil(t, err)
+		assert.Len(t, emojis, 5)
+		assert.Equal(t, 3, server.Requests(slacktest.MethodListEmoji))
+	})
+
+	tests.It("exports emoji images", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		want := apitest.PNG(t)
+		emoji := server.AddEmoji(slack.Emoji{Name: "blob"}, want)
+
+		dir := t.TempDir()
+		filename, err := client.ExportEmoji(t.Context(), emoji, dir)
+		require.Nil(t, err)
+		assert.Equal(t, "blob.png", filename)
+
+		data, err := os.ReadFile(filepath.Join(dir, filename))
+		require.Nil(t, err)
+		assert.Equal(t, want, data)
+	})
+
+	tests.It("leaves nothing behind when a download isn't an image", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		emoji := server.AddEmoji(slack.Emoji{Name: "blob"}, apitest.PNG(t)[:20])
+
+		dir := t.TempDir()
+		_, err := client.ExportEmoji(t.Context(), emoji, dir)
+		assert.NotNil(t, err)
+
+		entries, err := os.ReadDir(dir)
+		require.Nil(t, err)
+		assert.Empty(t, entries)
+	})
+
+	tests.It("uploads emoji and aliases", func(t *testing.T) {
+		client, server := helpNewClient(t)
+
+		require.Nil(t, client.UploadEmoji(t.Context(), "blob", "blob.gif", []byte("blob image")))
+		require.Nil(t, client.AddAlias(t.Context(), "blob-alias", "blob"))
+
+		emojis := server.Emoji()
+		require.Len(t, emojis, 2)
+		assert.Equal(t, int64(1), emojis[1].IsAlias)
+		assert.Equal(t, "blob", emojis[1].AliasFor)
+		data, ok := server.Image("blob")
+		require.True(t, ok)
+		assert.Equal(t, []byte("blob image"), data)
+	})
+
+	tests.It("returns typed errors", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.AddEmoji(slack.Emoji{Name: "blob"}, []byte("blob image"))
+
+		err := client.UploadEmoji(t.Context(), "blob", "blob.png", []byte("again"))
+		assert.ErrorIs(t, err, slack.ErrNameTaken)
+
+		err = client.UploadEmoji(t.Context(), "Not Valid", "blob.png", []byte("again"))
+		assert.ErrorIs(t, err, slack.ErrInvalidName)
+
+		server.InjectFault(slacktest.MethodListEmoji, slacktest.Fault{Error: "invalid_auth"})
+		_, err = client.ListEmoji(t.Context())
+		assert.ErrorIs(t, err, slack.ErrAuth)
+	})
+
+	tests.It("retries rate limited uploads", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.InjectFault(slacktest.MethodAddEmoji, slacktest.Fault{Status: http.StatusTooManyRequests, RetryAfter: 1})
+
+		require.Nil(t, client.UploadEmoji(t.Context(), "blob", "blob.png", []byte("blob image")))
+		assert.Equal(t, 2, server.Requests(slacktest.MethodAddEmoji))
+		assert.Len(t, server.Emoji(), 1)
+	})
+
+	tests.It("holds back other callers while throttled", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.InjectFault(slacktest.MethodAddEmoji, slacktest.Fault{Status: http.StatusTooManyRequests, RetryAfter: 1})
+
+		throttled := make(chan error)
+		go func() {
+			throttled <- client.UploadEmoji(t.Context(), "blob", "blob.png", []byte("blob image"))
+		}()
+		require.Eventually(t, func() bool {
+			return server.Requests(slacktest.MethodAddEmoji) == 1
+		}, time.Second, time.Millisecond)
+		// give the throttled upload a moment to read the 429
+		time.Sleep(100 * time.Millisecond)
+
+		started := time.Now()
+		require.Nil(t, client.UploadEmoji(t.Context(), "other", "other.png", []byte("other image")))
+		assert.GreaterOrEqual(t, time.Since(started), 800*time.Millisecond)
+		require.Nil(t, <-throttled)
+		assert.Len(t, server.Emoji(), 2)
+	})
+
+	tests.It("retries server errors when listing", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.AddEmoji(slack.Emoji{Name: "blob"}, []byte("image"))
+		server.InjectFault(slacktest.MethodListEmoji, slacktest.Fault{Status: http.StatusBadGateway})
+		server.InjectFault(slacktest.MethodListEmoji, slacktest.Fault{Status: http.StatusServiceUnavailable})
+
+		emoji, err := client.ListEmoji(t.Context())
+		require.Nil(t, err)
+		assert.Len(t, emoji, 1)
+		assert.Equal(t, 3, server.Requests(slacktest.MethodListEmoji))
+	})
+
+	tests.It("doesn't retry uploads after a server error", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.InjectFault(slacktest.MethodAddEmoji, slacktest.Fault{Status: http.StatusBadGateway})
+
+		err := client.UploadEmoji(t.Context(), "blob", "blob.png", []byte("blob image"))
+		assert.ErrorContains(t, err, "failed with status 502")
+		assert.Equal(t, 1, server.Requests(slacktest.MethodAddEmoji))
+	})
+
+	tests.It("gives up once retries run out", func(t *testing.T) {
+		client, server := helpNewClient(t, slack.WithRetries(1))
+		server.InjectFault(slacktest.MethodAddEmoji, slacktest.Fault{Status: http.StatusTooManyRequests})
+		server.InjectFault(slacktest.MethodAddEmoji, slacktest.Fault{Status: http.StatusTooManyRequests})
+
+		err := client.UploadEmoji(t.Context(), "blob", "blob.png", []byte("blob image"))
+		assert.ErrorIs(t, err, slack.ErrRateLimited)
+		assert.Equal(t, 2, server.Requests(slacktest.MethodAddEmoji))
+		assert.Empty(t, server.Emoji())
+	})
+
+	tests.It("retries image downloads", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		want := apitest.PNG(t)
+		emoji := server.AddEmoji(slack.Emoji{Name: "blob"}, want)
+		server.InjectFault(slacktest.MethodImage, slacktest.Fault{Status: http.StatusInternalServerError})
+
+		body, _, err := client.DownloadEmoji(t.Context(), emoji)
+		require.Nil(t, err)
+		defer body.Close()
+		got, err := io.ReadAll(body)
+		require.Nil(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	tests.It("stops waiting on a retry when cancelled", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.InjectFault(slacktest.MethodAddEmoji, slacktest.Fault{Status: http.StatusTooManyRequests, RetryAfter: 60})
+
+		ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+		defer cancel()
+		started := time.Now()
+		err := client.UploadEmoji(ctx, "blob", "blob.png", []byte("blob image"))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(started), 5*time.Second)
+	})
+
+	tests.It("posts threaded messages", func(t *testing.T) {
+		client, server := helpNewClient(t)
+
+		resp, err := client.PostMessage(t.Context(), "C123", "header", nil)
+		require.Nil(t, err)
+		thread := resp["ts"].(string)
+		_, err = client.PostMessage(t.Context(), "C123", "reply", &thread)
+		require.Nil(t, err)
+
+		messages := server.Messages()
+		require.Len(t, messages, 2)
+		assert.Empty(t, messages[0].ThreadTs)
+		assert.Equal(t, thread, messages[1].ThreadTs)
+		assert.Contains(t, messages[1].Text, "reply")
+	})
+
+	tests.Run()
+}
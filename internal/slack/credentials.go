@@ -0,0 +1,120 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/browserutils/kooky"
+	_ "github.com/browserutils/kooky/browser/all"
+	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+	"github.com/zalando/go-keyring"
+)
+
+// KeyringService is the service name credentials are stored under in the OS keyring
+const KeyringService = "emoji-archiver"
+
+// Credentials are the tokens used to talk to a workspace. If XOXC is empty
+// it's requested from slack using XOXD when the client is created.
+type Credentials struct {
+	XOXD string `json:"xoxd"`
+	XOXC string `json:"xoxc,omitempty"`
+}
+
+// CredentialProvider looks up the credentials for a slack subdomain
+type CredentialProvider interface {
+	Credentials(ctx context.Context, subdomain string) (Credentials, error)
+}
+
+// BrowserCredentials reads the d cookie out of a browser profile's cookie store
+type BrowserCredentials struct {
+	Browser string
+	Profile string
+}
+
+// StaticCredentials are tokens given directly, from env vars or flags
+type StaticCredentials Credentials
+
+/*
+FileCredentials
+
+Reads tokens from a json file keyed by subdomain, e.g.
+
+	{"my-team": {"xoxd": "xoxd-...", "xoxc": "xoxc-..."}}
+*/
+type FileCredentials struct {
+	Path string
+}
+
+// KeyringCredentials reads tokens saved in the OS keyring with SaveToKeyring
+type KeyringCredentials struct{}
+
+func (b BrowserCredentials) Credentials(ctx context.Context, subdomain string) (Credentials, error) {
+	if b.Browser == "" || b.Profile == "" {
+		return Credentials{}, fmt.Errorf("a browser and profile are required to read cookies")
+	}
+
+	utilities.ContextLogger(ctx).Info("getting cookies from browser", "browser", b.Browser, "profile", b.Profile)
+	stores := kooky.FindAllCookieStores(ctx)
+	site, _ := url.Parse(fmt.Sprintf("https://%s.slack.com", subdomain))
+	for _, store := range stores {
+		if store.Browser() == b.Browser && store.Profile() == b.Profile {
+			for _, cookie := range store.Cookies(site) {
+				if cookie.Name == "d" {
+					return Credentials{XOXD: cookie.Value}, nil
+				}
+			}
+		}
+	}
+
+	return Credentials{}, fmt.Errorf("no cookie found in cookie stores for subdomain")
+}
+
+func (s StaticCredentials) Credentials(ctx context.Context, subdomain string) (Credentials, error) {
+	if s.XOXD == "" {
+		return Credentials{}, fmt.Errorf("no xoxd token given")
+	}
+	return Credentials(s), nil
+}
+
+func (f FileCredentials) Credentials(ctx context.Context, subdomain string) (Credentials, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	teams := make(map[string]Credentials)
+	if err := json.Unmarshal(data, &teams); err != nil {
+		return Credentials{}, errors.Join(fmt.Errorf("unable to parse token file"), err)
+	}
+	creds, ok := teams[subdomain]
+	if !ok || creds.XOXD == "" {
+		return Credentials{}, fmt.Errorf("no tokens for %s in %s", subdomain, f.Path)
+	}
+	return creds, nil
+}
+
+func (k KeyringCredentials) Credentials(ctx context.Context, subdomain string) (Credentials, error) {
+	secret, err := keyring.Get(KeyringService, subdomain)
+	if err != nil {
+		return Credentials{}, errors.Join(fmt.Errorf("unable to read %s from keyring", subdomain), err)
+	}
+
+	creds := Credentials{}
+	if err := json.Unmarshal([]byte(secret), &creds); err != nil {
+		return Credentials{}, errors.Join(fmt.Errorf("unable to parse keyring entry"), err)
+	}
+	return creds, nil
+}
+
+// SaveToKeyring stores credentials for a subdomain in the OS keyring for KeyringCredentials to find
+func SaveToKeyring(subdomain string, creds Credentials) error {
+	data, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	return keyring.Set(KeyringService, subdomain, string(data))
+}
@@ -0,0 +1,48 @@
+package slack
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/neko"
+)
+
+func TestFileCredentials(t *testing.T) {
+	tests := neko.Modern(t)
+
+	tests.It("reads the tokens for the subdomain", func(t *testing.T) {
+		fPath := filepath.Join(t.TempDir(), "tokens.json")
+		require.Nil(t, os.WriteFile(fPath, []byte(`{
+			"my-team": {"xoxd": "xoxd-mine", "xoxc": "xoxc-mine"},
+			"other-team": {"xoxd": "xoxd-other"}
+		}`), 0600))
+
+		creds, err := FileCredentials{Path: fPath}.Credentials(context.Background(), "other-team")
+		require.Nil(t, err)
+		assert.Equal(t, Credentials{XOXD: "xoxd-other"}, creds)
+
+		_, err = FileCredentials{Path: fPath}.Credentials(context.Background(), "missing-team")
+		assert.NotNil(t, err)
+	})
+
+	tests.Run()
+}
+
+func TestStaticCredentials(t *testing.T) {
+	tests := neko.Modern(t)
+
+	tests.It("requires an xoxd token", func(t *testing.T) {
+		_, err := StaticCredentials{XOXC: "xoxc-only"}.Credentials(context.Background(), "my-team")
+		assert.NotNil(t, err)
+
+		creds, err := StaticCredentials{XOXD: "xoxd-mine"}.Credentials(context.Background(), "my-team")
+		require.Nil(t, err)
+		assert.Equal(t, "xoxd-mine", creds.XOXD)
+	})
+
+	tests.Run()
+}
@@ -0,0 +1,63 @@
+package slack
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/erindatkinson/emoji-archiver/internal/httpretry"
+)
+
+// Kinds of Slack API failure, match them with errors.Is
+var (
+	ErrNameTaken   = errors.New("emoji name is already taken")
+	ErrTooLarge    = errors.New("image is too large")
+	ErrInvalidName = errors.New("emoji name is invalid")
+	ErrBadImage    = errors.New("image could not be processed")
+	ErrAuth        = errors.New("not authorized")
+	ErrRateLimited = httpretry.ErrRateLimited
+)
+
+// errorCodes maps the error strings Slack returns with ok: false onto error kinds
+var errorCodes = map[string]error{
+	"error_name_taken":            ErrNameTaken,
+	"error_name_taken_i18n":       ErrNameTaken,
+	"too_large":                   ErrTooLarge,
+	"resized_but_still_too_large": ErrTooLarge,
+	"invalid_name":                ErrInvalidName,
+	"error_bad_name_i18n":         ErrInvalidName,
+	"error_missing_name":          ErrInvalidName,
+	"no_image_uploaded":           ErrBadImage,
+	"error_bad_format":            ErrBadImage,
+	"error_bad_upload":            ErrBadImage,
+	"not_authed":                  ErrAuth,
+	"invalid_auth":                ErrAuth,
+	"token_revoked":               ErrAuth,
+	"token_expired":               ErrAuth,
+	"ratelimited":                 ErrRateLimited,
+}
+
+// APIError is returned when Slack responds with ok: false
+type APIError struct {
+	Method string
+	Code   string
+	kind   error
+}
+
+func newAPIError(method, code string) *APIError {
+	return &APIError{
+		Method: method,
+		Code:   code,
+		kind:   errorCodes[code],
+	}
+}
+
+func (e *APIError) Error() string {
+	if e.kind != nil {
+		return fmt.Sprintf("%s failed: %s (%s)", e.Method, e.kind, e.Code)
+	}
+	return fmt.Sprintf("%s failed: %s", e.Method, e.Code)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.kind
+}
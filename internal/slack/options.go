@@ -0,0 +1,64 @@
+package slack
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultAPIURL       = "https://slack.com/api"
+	defaultWorkspaceURL = "https://%s.slack.com"
+)
+
+// ClientOption customizes a Client created by NewSlackClient
+type ClientOption func(*Client)
+
+// WithAPIURL sets the base url for workspace independent methods like chat.postMessage
+func WithAPIURL(apiURL string) ClientOption {
+	return func(c *Client) {
+		c.APIURL = strings.TrimSuffix(apiURL, "/")
+	}
+}
+
+/*
+WithWorkspaceURL
+
+Sets the url of the workspace used for the emoji admin methods and token
+bootstrap, a %s in the url is replaced with the subdomain, e.g.
+https://%s.enterprise.slack.com for Enterprise Grid
+*/
+func WithWorkspaceURL(workspaceURL string) ClientOption {
+	return func(c *Client) {
+		c.WorkspaceURL = strings.TrimSuffix(workspaceURL, "/")
+	}
+}
+
+// WithHTTPClient sets the http client used for every request, for timeouts, proxies or custom transports
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		c.HTTPClient = httpClient
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request
+func WithUserAgent(userAgent string) ClientOption {
+	return func(c *Client) {
+		c.UserAgent = userAgent
+	}
+}
+
+func (c *Client) apiEndpoint(method string) string {
+	return c.APIURL + "/" + method
+}
+
+func (c *Client) workspaceURL() string {
+	if strings.Contains(c.WorkspaceURL, "%s") {
+		return fmt.Sprintf(c.WorkspaceURL, c.Subdomain)
+	}
+	return c.WorkspaceURL
+}
+
+func (c *Client) workspaceEndpoint(method string) string {
+	return c.workspaceURL() + "/api/" + method
+}
@@ -0,0 +1,151 @@
+package slack
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/httpretry"
+	"golang.org/x/time/rate"
+)
+
+// Requests per minute for Slack's web api rate limit tiers
+const (
+	Tier1 = 1
+	Tier2 = 20
+	Tier3 = 50
+	Tier4 = 100
+)
+
+/*
+methodLimits is requests per minute for each method we call. emoji.add
+isn't documented so it gets tier 3 which slack tolerates in practice,
+chat.postMessage is limited to about one a second per channel, and
+unlisted methods (token bootstrap, image downloads) aren't limited.
+*/
+var methodLimits = map[string]int{
+	"emoji.adminList":  Tier2,
+	"emoji.add":        Tier3,
+	"chat.postMessage": 60,
+}
+
+// writeMethods change something in slack, so a network error or 5xx could
+// mean they went through and they aren't retried
+var writeMethods = map[string]bool{
+	"emoji.add":        true,
+	"chat.postMessage": true,
+}
+
+// WithRateLimit overrides the requests per minute allowed for a method
+func WithRateLimit(method string, perMinute int) ClientOption {
+	return func(c *Client) {
+		c.limits[method] = perMinute
+	}
+}
+
+// WithRetries sets how many times a request is retried after a 429, 5xx or network error
+func WithRetries(retries int) ClientOption {
+	return func(c *Client) {
+		c.retry.Retries = retries
+	}
+}
+
+// WithBackoff sets the starting and maximum delay for exponential backoff between retries
+func WithBackoff(base, maximum time.Duration) ClientOption {
+	return func(c *Client) {
+		c.retry.BackoffBase = base
+		c.retry.BackoffMax = maximum
+	}
+}
+
+// limiter spaces out requests for a method and holds every caller back
+// while slack has told us to back off
+type limiter struct {
+	rate *rate.Limiter
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+func newLimiter(perMinute int) *limiter {
+	if perMinute <= 0 {
+		return &limiter{rate: rate.NewLimiter(rate.Inf, 0)}
+	}
+	return &limiter{
+		rate: rate.NewLimiter(rate.Every(time.Minute/time.Duration(perMinute)), max(1, perMinute/10)),
+	}
+}
+
+func (l *limiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	pause := time.Until(l.pausedUntil)
+	l.mu.Unlock()
+	if pause > 0 {
+		if err := httpretry.Sleep(ctx, pause); err != nil {
+			return err
+		}
+	}
+	return l.rate.Wait(ctx)
+}
+
+func (l *limiter) pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+func (c *Client) limiterFor(method string) *limiter {
+	c.limitersMu.Lock()
+	defer c.limitersMu.Unlock()
+	if l, ok := c.limiters[method]; ok {
+		return l
+	}
+	l := newLimiter(c.limits[method])
+	c.limiters[method] = l
+	return l
+}
+
+// newRetrier retries requests the way slack asks, api errors come back with a 200 so responses are left for the caller
+func newRetrier() httpretry.Retrier {
+	retry := httpretry.New("slack")
+	retry.RetryAfter = func(resp *http.Response) time.Duration {
+		seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+		if err != nil {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	return retry
+}
+
+/*
+do
+
+Sends the request built by build once the method's rate limiter allows,
+retrying it when throttled or, unless it's a write, when it fails along
+the way. A 429's Retry-After pauses every request to the method, not
+just this one.
+*/
+func (c *Client) do(ctx context.Context, method string, build func() (*http.Request, error)) (*http.Response, error) {
+	l := c.limiterFor(method)
+	retry := c.retry
+	retry.Wait = func(ctx context.Context) error {
+		started := time.Now()
+		if err := l.wait(ctx); err != nil {
+			return err
+		}
+		if waited := time.Since(started); waited > time.Second {
+			c.Logger.Debug("waited on rate limiter", "method", method, "waited", waited)
+		}
+		return nil
+	}
+	retry.Throttled = l.pause
+	retry.Idempotent = func(*http.Request) bool {
+		return !writeMethods[method]
+	}
+	return retry.Do(ctx, c.HTTPClient, c.Logger, method, build)
+}
@@ -24,8 +24,14 @@ type Emoji struct {
 
 type EmojiList struct {
 	Ok                    bool       `json:"ok"`
+	Error                 string     `json:"error"`
 	Emoji                 []Emoji    `json:"emoji"`
 	DisabledEmoji         []Emoji    `json:"disabled_emoji"`
 	CustomEmojiTotalCount int64      `json:"custom_emoji_total_count"`
 	Paging                Pagination `json:"paging"`
 }
+
+type apiResponse struct {
+	Ok    bool   `json:"ok"`
+	Error string `json:"error"`
+}
@@ -0,0 +1,375 @@
+/*
+Package slacktest runs an in memory stand-in for the parts of Slack the
+archiver talks to, so clients and commands can be tested end to end.
+*/
+package slacktest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/apitest"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+)
+
+const (
+	// XOXD is the cookie the server accepts
+	XOXD = "xoxd-slacktest"
+	// XOXC is the token handed out by the bootstrap page
+	XOXC = "xoxc-slacktest-0000"
+	// TeamID is used in emoji image urls
+	TeamID = "T0SLACKTEST"
+)
+
+// Method names that faults can be injected into
+const (
+	MethodBootstrap   = "bootstrap"
+	MethodListEmoji   = "emoji.adminList"
+	MethodAddEmoji    = "emoji.add"
+	MethodPostMessage = "chat.postMessage"
+	MethodImage       = "image"
+)
+
+var validName = regexp.MustCompile(`^[a-z0-9_'+-]+$`)
+
+// Fault replaces the next response from a method. With a Status the
+// request fails at the http level, with an Error slack replies ok: false.
+type Fault struct {
+	Status     int
+	RetryAfter int
+	Error      string
+}
+
+// Message is a message posted with chat.postMessage
+type Message struct {
+	Channel  string
+	Text     string
+	ThreadTs string
+	Ts       string
+}
+
+type Server struct {
+	*httptest.Server
+
+	// PageSize caps emoji.adminList pages regardless of the count asked for
+	PageSize int
+
+	mu       sync.Mutex
+	emoji    []slack.Emoji
+	images   map[string][]byte
+	messages []Message
+	apitest.Faults[Fault]
+}
+
+// NewServer starts a server, close it when done
+func NewServer() *Server {
+	s := &Server{
+		Faults:   apitest.Faults[Fault]{Write: writeFault},
+		PageSize: 100,
+		images:   make(map[string][]byte),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /{$}", s.handleBootstrap)
+	mux.HandleFunc("POST /api/emoji.adminList", s.handleListEmoji)
+	mux.HandleFunc("POST /api/emoji.add", s.handleAddEmoji)
+	mux.HandleFunc("POST /api/chat.postMessage", s.handlePostMessage)
+	mux.HandleFunc("GET /{team}/{name}/{file}", s.handleImage)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// ClientOptions points a slack client at the server
+func (s *Server) ClientOptions() []slack.ClientOption {
+	return []slack.ClientOption{
+		slack.WithAPIURL(s.URL + "/api"),
+		slack.WithWorkspaceURL(s.URL),
+		slack.WithHTTPClient(s.Client()),
+	}
+}
+
+// Credentials are accepted by the server, the xoxc token is left for the client to bootstrap
+func (s *Server) Credentials() slack.CredentialProvider {
+	return slack.StaticCredentials{XOXD: XOXD}
+}
+
+// AddEmoji seeds the workspace with an emoji, the url is filled in to point at the server
+func (s *Server) AddEmoji(emoji slack.Emoji, image []byte) slack.Emoji {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addEmoji(emoji, image, ".png")
+}
+
+// AddAlias seeds the workspace with an alias of an existing emoji
+func (s *Server) AddAlias(name, target string) slack.Emoji {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	alias, _ := s.addAlias(name, target)
+	return alias
+}
+
+// RemoveEmoji deletes an emoji from the workspace
+func (s *Server) RemoveEmoji(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.emoji = slices.DeleteFunc(s.emoji, func(e slack.Emoji) bool {
+		return e.Name == name
+	})
+}
+
+// Emoji returns the current emoji in the workspace
+func (s *Server) Emoji() []slack.Emoji {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.emoji)
+}
+
+// Image returns the image stored for an emoji
+func (s *Server) Image(name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, emoji := range s.emoji {
+		if emoji.Name == name {
+			data, ok := s.images[imagePath(emoji.URL)]
+			return data, ok
+		}
+	}
+	return nil, false
+}
+
+// Messages returns everything posted with chat.postMessage
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.messages)
+}
+
+//========== Handlers ==========
+
+func (s *Server) handleBootstrap(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodBootstrap) {
+		return
+	}
+	cookie, err := r.Cookie("d")
+	if err != nil || cookie.Value != XOXD {
+		w.Write([]byte("<html>please sign in</html>"))
+		return
+	}
+	fmt.Fprintf(w, `<html><script>var boot_data = {"api_token":"%s"};</script></html>`, XOXC)
+}
+
+func (s *Server) handleListEmoji(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodListEmoji) || !s.authorized(w, r, r.PostFormValue("token")) {
+		return
+	}
+
+	page, _ := strconv.Atoi(r.PostFormValue("page"))
+	count, _ := strconv.Atoi(r.PostFormValue("count"))
+	page = max(page, 1)
+	if count <= 0 || count > s.PageSize {
+		count = s.PageSize
+	}
+
+	s.mu.Lock()
+	total := len(s.emoji)
+	start := min((page-1)*count, total)
+	end := min(start+count, total)
+	emoji := slices.Clone(s.emoji[start:end])
+	s.mu.Unlock()
+
+	writeJSON(w, slack.EmojiList{
+		Ok:                    true,
+		Emoji:                 emoji,
+		CustomEmojiTotalCount: int64(total),
+		Paging: slack.Pagination{
+			Count: int64(count),
+			Page:  int64(page),
+			Pages: int64(max(1, (total+count-1)/count)),
+			Total: int64(total),
+		},
+	})
+}
+
+func (s *Server) handleAddEmoji(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodAddEmoji) {
+		return
+	}
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		writeError(w, "invalid_form_data")
+		return
+	}
+	if !s.authorized(w, r, r.FormValue("token")) {
+		return
+	}
+
+	name := r.FormValue("name")
+	if name == "" {
+		writeError(w, "error_missing_name")
+		return
+	}
+	if !validName.MatchString(name) {
+		writeError(w, "error_bad_name_i18n")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.find(name) >= 0 {
+		writeError(w, "error_name_taken")
+		return
+	}
+
+	switch r.FormValue("mode") {
+	case "alias":
+		if _, ok := s.addAlias(name, r.FormValue("alias_for")); !ok {
+			writeError(w, "error_invalid_alias")
+			return
+		}
+	case "data":
+		fp, header, err := r.FormFile("image")
+		if err != nil {
+			writeError(w, "no_image_uploaded")
+			return
+		}
+		defer fp.Close()
+		data, err := io.ReadAll(fp)
+		if err != nil {
+			writeError(w, "error_bad_upload")
+			return
+		}
+		if len(data) > 128*1024 {
+			writeError(w, "too_large")
+			return
+		}
+		s.addEmoji(slack.Emoji{Name: name, UserID: "U0SLACKTEST", UserDisplayName: "slacktest"}, data, path.Ext(header.Filename))
+	default:
+		writeError(w, "invalid_mode")
+		return
+	}
+
+	writeJSON(w, map[string]any{"ok": true})
+}
+
+func (s *Server) handlePostMessage(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodPostMessage) || !s.authorized(w, r, r.PostFormValue("token")) {
+		return
+	}
+	channel := r.PostFormValue("channel")
+	if channel == "" {
+		writeError(w, "channel_not_found")
+		return
+	}
+
+	s.mu.Lock()
+	message := Message{
+		Channel:  channel,
+		Text:     r.PostFormValue("markdown_text"),
+		ThreadTs: r.PostFormValue("thread_ts"),
+		Ts:       fmt.Sprintf("%d.%06d", time.Now().Unix(), len(s.messages)),
+	}
+	s.messages = append(s.messages, message)
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]any{"ok": true, "channel": channel, "ts": message.Ts})
+}
+
+func (s *Server) handleImage(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodImage) {
+		return
+	}
+	s.mu.Lock()
+	data, ok := s.images[r.URL.Path]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	apitest.WriteImage(w, data)
+}
+
+//========== Helpers ==========
+
+// writeFault sends a fault the way slack reports errors
+func writeFault(w http.ResponseWriter, fault Fault) {
+	if fault.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(fault.RetryAfter))
+	}
+	if fault.Status != 0 {
+		w.WriteHeader(fault.Status)
+		return
+	}
+	writeError(w, fault.Error)
+}
+
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request, token string) bool {
+	cookie, err := r.Cookie("d")
+	if err != nil || cookie.Value != XOXD || token != XOXC {
+		writeError(w, "invalid_auth")
+		return false
+	}
+	return true
+}
+
+// addEmoji expects the lock to be held
+func (s *Server) addEmoji(emoji slack.Emoji, image []byte, ext string) slack.Emoji {
+	sum := sha256.Sum256(image)
+	if emoji.Created == 0 {
+		emoji.Created = time.Now().Unix()
+	}
+	emoji.TeamId = TeamID
+	emoji.URL = fmt.Sprintf("%s/%s/%s/%s%s", s.URL, TeamID, emoji.Name, hex.EncodeToString(sum[:8]), ext)
+	s.images[imagePath(emoji.URL)] = image
+	s.emoji = append(s.emoji, emoji)
+	return emoji
+}
+
+// addAlias expects the lock to be held
+func (s *Server) addAlias(name, target string) (slack.Emoji, bool) {
+	index := s.find(target)
+	if index < 0 {
+		return slack.Emoji{}, false
+	}
+	alias := slack.Emoji{
+		Name:            name,
+		Created:         time.Now().Unix(),
+		IsAlias:         1,
+		AliasFor:        target,
+		TeamId:          TeamID,
+		URL:             s.emoji[index].URL,
+		UserID:          "U0SLACKTEST",
+		UserDisplayName: "slacktest",
+	}
+	s.emoji = append(s.emoji, alias)
+	return alias, true
+}
+
+// find expects the lock to be held
+func (s *Server) find(name string) int {
+	return slices.IndexFunc(s.emoji, func(e slack.Emoji) bool {
+		return e.Name == name
+	})
+}
+
+func imagePath(uri string) string {
+	_, after, _ := strings.Cut(strings.TrimPrefix(uri, "http://"), "/")
+	return "/" + after
+}
+
+func writeJSON(w http.ResponseWriter, data any) {
+	apitest.WriteJSON(w, http.StatusOK, data)
+}
+
+func writeError(w http.ResponseWriter, code string) {
+	writeJSON(w, map[string]any{"ok": false, "error": code})
+}
@@ -0,0 +1,300 @@
+/*
+Package storage moves exports in and out of places other than a plain
+directory: zip and tar.gz archives, and object stores.
+*/
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Export formats
+const (
+	FormatDir   = "dir"
+	FormatZip   = "zip"
+	FormatTarGz = "tar.gz"
+	FormatS3    = "s3"
+)
+
+// ArchiveFormat works out an archive's format from its file name, or returns an empty string
+func ArchiveFormat(fPath string) string {
+	switch {
+	case strings.HasSuffix(fPath, ".zip"):
+		return FormatZip
+	case strings.HasSuffix(fPath, ".tar.gz"), strings.HasSuffix(fPath, ".tgz"):
+		return FormatTarGz
+	}
+	return ""
+}
+
+// archiveWriter serializes puts from the download workers into a single archive stream
+type archiveWriter struct {
+	mu     sync.Mutex
+	fp     *os.File
+	put    func(name string, data []byte) error
+	closed func() error
+}
+
+/*
+CreateArchive
+
+Creates a zip or tar.gz archive at fPath that files are streamed into as
+they're put, nothing is staged on disk. The archive isn't complete until
+Close is called.
+*/
+func CreateArchive(fPath, format string) (Writer, error) {
+	fp, err := os.Create(fPath)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &archiveWriter{fp: fp}
+	now := time.Now()
+	switch format {
+	case FormatZip:
+		zw := zip.NewWriter(fp)
+		w.put = func(name string, data []byte) error {
+			entry, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+			if err != nil {
+				return err
+			}
+			_, err = entry.Write(data)
+			return err
+		}
+		w.closed = zw.Close
+	case FormatTarGz:
+		gw := gzip.NewWriter(fp)
+		tw := tar.NewWriter(gw)
+		w.put = func(name string, data []byte) error {
+			header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: now, Typeflag: tar.TypeReg}
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			_, err := tw.Write(data)
+			return err
+		}
+		w.closed = func() error {
+			return errors.Join(tw.Close(), gw.Close())
+		}
+	default:
+		fp.Close()
+		os.Remove(fPath)
+		return nil, fmt.Errorf("unknown archive format %q", format)
+	}
+	return w, nil
+}
+
+func (w *archiveWriter) Put(ctx context.Context, name string, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.put(name, data)
+}
+
+func (w *archiveWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return errors.Join(w.closed(), w.fp.Close())
+}
+
+// MaxFileSize is the largest file read out of an archive, far bigger than any emoji or manifest
+const MaxFileSize = 64 * 1024 * 1024
+
+// archiveReader reads files straight out of a zip or tar.gz archive as they're asked for, nothing is held in memory
+type archiveReader struct {
+	fPath  string
+	format string
+}
+
+// OpenArchive opens a zip or tar.gz archive for import
+func OpenArchive(fPath string) (Reader, error) {
+	r := &archiveReader{fPath: fPath, format: ArchiveFormat(fPath)}
+	if r.format == "" {
+		return nil, fmt.Errorf("%s isn't a .zip, .tar.gz or .tgz archive", fPath)
+	}
+	// a walk that stops straight away checks the archive can be read
+	if err := r.walk(context.Background(), func(string, io.Reader) error { return errStopWalk }); err != nil && !errors.Is(err, errStopWalk) {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *archiveReader) List(ctx context.Context) ([]string, error) {
+	names := make([]string, 0)
+	err := r.walk(ctx, func(name string, _ io.Reader) error {
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// Get finds a file by reading through the archive, Extract reads everything in one pass instead
+func (r *archiveReader) Get(ctx context.Context, name string) ([]byte, error) {
+	var data []byte
+	err := r.walk(ctx, func(entry string, body io.Reader) error {
+		if entry != name {
+			return nil
+		}
+		var err error
+		data, err = readLimited(entry, body)
+		if err != nil {
+			return err
+		}
+		return errStopWalk
+	})
+	if errors.Is(err, errStopWalk) {
+		return data, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%s isn't in the archive", name)
+}
+
+var errStopWalk = errors.New("stop walking the archive")
+
+// walk calls fn with each regular file in the archive in the order they're stored, stopping at the first error
+func (r *archiveReader) walk(ctx context.Context, fn func(name string, body io.Reader) error) error {
+	switch r.format {
+	case FormatZip:
+		zr, err := zip.OpenReader(r.fPath)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		for _, file := range zr.File {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if file.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := file.Open()
+			if err != nil {
+				return errors.Join(fmt.Errorf("unable to read %s", file.Name), err)
+			}
+			err = fn(file.Name, rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+	case FormatTarGz:
+		fp, err := os.Open(r.fPath)
+		if err != nil {
+			return err
+		}
+		defer fp.Close()
+		gr, err := gzip.NewReader(fp)
+		if err != nil {
+			return err
+		}
+		tr := tar.NewReader(gr)
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			header, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if header.Typeflag != tar.TypeReg {
+				continue
+			}
+			if err := fn(header.Name, tr); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func readLimited(name string, body io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(body, MaxFileSize+1))
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("unable to read %s", name), err)
+	}
+	if len(data) > MaxFileSize {
+		return nil, fmt.Errorf("%s is over the %dMB limit for a file in an archive", name, MaxFileSize/1024/1024)
+	}
+	return data, nil
+}
+
+/*
+Extract
+
+Copies every file from a reader into dir so an export held elsewhere can
+go through the same import steps as a local directory. Archives are read
+in a single pass, one file at a time. Names that would land outside of
+dir are rejected.
+*/
+func Extract(ctx context.Context, r Reader, dir string) (int, error) {
+	if archive, ok := r.(*archiveReader); ok {
+		count := 0
+		err := archive.walk(ctx, func(name string, body io.Reader) error {
+			data, err := readLimited(name, body)
+			if err != nil {
+				return err
+			}
+			if err := writeExtracted(dir, name, data); err != nil {
+				return err
+			}
+			count++
+			return nil
+		})
+		return count, err
+	}
+
+	names, err := r.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for i, name := range names {
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+		if !filepath.IsLocal(name) {
+			return i, fmt.Errorf("refusing to extract %q outside of the import directory", name)
+		}
+		data, err := r.Get(ctx, name)
+		if err != nil {
+			return i, err
+		}
+		if err := writeExtracted(dir, name, data); err != nil {
+			return i, err
+		}
+	}
+	return len(names), nil
+}
+
+func writeExtracted(dir, name string, data []byte) error {
+	if !filepath.IsLocal(name) {
+		return fmt.Errorf("refusing to extract %q outside of the import directory", name)
+	}
+	fPath := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(fPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(fPath, data, 0644)
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/neko"
+)
+
+func TestArchive(t *testing.T) {
+	tests := neko.Modern(t)
+
+	for _, format := range []string{FormatZip, FormatTarGz} {
+		tests.It("round trips a "+format+" archive", func(t *testing.T) {
+			fPath := filepath.Join(t.TempDir(), "export."+format)
+			assert.Equal(t, format, ArchiveFormat(fPath))
+
+			w, err := CreateArchive(fPath, format)
+			require.Nil(t, err)
+			require.Nil(t, w.Put(t.Context(), "blob.png", []byte("blob")))
+			require.Nil(t, w.Put(t.Context(), "manifest.json", []byte("{}")))
+			require.Nil(t, w.Close())
+
+			r, err := OpenArchive(fPath)
+			require.Nil(t, err)
+			names, err := r.List(t.Context())
+			require.Nil(t, err)
+			assert.Equal(t, []string{"blob.png", "manifest.json"}, names)
+
+			dir := t.TempDir()
+			count, err := Extract(t.Context(), r, dir)
+			require.Nil(t, err)
+			assert.Equal(t, 2, count)
+			data, err := os.ReadFile(filepath.Join(dir, "blob.png"))
+			require.Nil(t, err)
+			assert.Equal(t, "blob", string(data))
+		})
+	}
+
+	tests.It("refuses to extract outside of the directory", func(t *testing.T) {
+		fPath := filepath.Join(t.TempDir(), "evil.zip")
+		fp, err := os.Create(fPath)
+		require.Nil(t, err)
+		zw := zip.NewWriter(fp)
+		entry, err := zw.Create("../evil.png")
+		require.Nil(t, err)
+		entry.Write([]byte("evil"))
+		require.Nil(t, zw.Close())
+		require.Nil(t, fp.Close())
+
+		r, err := OpenArchive(fPath)
+		require.Nil(t, err)
+		_, err = Extract(t.Context(), r, t.TempDir())
+		assert.NotNil(t, err)
+	})
+
+	tests.It("refuses files over the size limit", func(t *testing.T) {
+		fPath := filepath.Join(t.TempDir(), "big.zip")
+		fp, err := os.Create(fPath)
+		require.Nil(t, err)
+		zw := zip.NewWriter(fp)
+		entry, err := zw.Create("big.png")
+		require.Nil(t, err)
+		_, err = entry.Write(make([]byte, MaxFileSize+1))
+		require.Nil(t, err)
+		require.Nil(t, zw.Close())
+		require.Nil(t, fp.Close())
+
+		r, err := OpenArchive(fPath)
+		require.Nil(t, err)
+		_, err = Extract(t.Context(), r, t.TempDir())
+		assert.ErrorContains(t, err, "over the 64MB limit")
+	})
+
+	tests.It("rejects unknown formats", func(t *testing.T) {
+		_, err := CreateArchive(filepath.Join(t.TempDir(), "export.rar"), "rar")
+		assert.NotNil(t, err)
+		_, err = OpenArchive("export.rar")
+		assert.NotNil(t, err)
+	})
+
+	tests.Run()
+}
@@ -0,0 +1,295 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+	s3Service       = "s3"
+	amzDateLayout   = "20060102T150405Z"
+)
+
+// ErrNotFound is returned by Get when an object doesn't exist
+var ErrNotFound = errors.New("not found")
+
+// S3Config describes where in an S3 compatible object store an export lives
+type S3Config struct {
+	// Endpoint is the store's base url, AWS is used when it's empty. Custom
+	// endpoints like MinIO are addressed path style, AWS virtual host style.
+	Endpoint     string
+	Region       string
+	Bucket       string
+	Prefix       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// S3 reads and writes an export as objects under a prefix in a bucket
+type S3 struct {
+	config     S3Config
+	base       *url.URL
+	pathStyle  bool
+	HTTPClient *http.Client
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key  string `xml:"Key"`
+		Size int64  `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// ParseS3URL splits an s3://bucket/prefix url into its bucket and prefix
+func ParseS3URL(uri string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(uri, "s3://")
+	if !ok {
+		return "", "", false
+	}
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	return bucket, strings.Trim(prefix, "/"), bucket != ""
+}
+
+// NewS3 checks the config and returns a client for it
+func NewS3(config S3Config, httpClient *http.Client) (*S3, error) {
+	if config.Bucket == "" {
+		return nil, errors.New("an s3 bucket is required")
+	}
+	if config.AccessKey == "" || config.SecretKey == "" {
+		return nil, errors.New("s3 credentials are required")
+	}
+	if config.Region == "" {
+		config.Region = defaultS3Region
+	}
+	config.Prefix = strings.Trim(config.Prefix, "/")
+
+	endpoint := config.Endpoint
+	pathStyle := endpoint != ""
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", config.Region)
+	}
+	base, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("invalid s3 endpoint"), err)
+	}
+	if !pathStyle {
+		base.Host = config.Bucket + "." + base.Host
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &S3{config: config, base: base, pathStyle: pathStyle, HTTPClient: httpClient}, nil
+}
+
+// List returns the names of every object under the prefix, relative to it
+func (s *S3) List(ctx context.Context) ([]string, error) {
+	names := make([]string, 0)
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if s.config.Prefix != "" {
+			query.Set("prefix", s.config.Prefix+"/")
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		result := s3ListResult{}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("unable to parse s3 listing"), err)
+		}
+
+		for _, object := range result.Contents {
+			name := strings.TrimPrefix(object.Key, s.config.Prefix+"/")
+			if s.config.Prefix == "" {
+				name = object.Key
+			}
+			if name != "" && !strings.HasSuffix(name, "/") {
+				names = append(names, name)
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// Get reads an object under the prefix
+func (s *S3) Get(ctx context.Context, name string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, name, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// Put writes an object under the prefix
+func (s *S3) Put(ctx context.Context, name string, data []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, name, nil, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Close is a no-op, every put is complete once it returns
+func (s *S3) Close() error {
+	return nil
+}
+
+func (s *S3) do(ctx context.Context, method, name string, query url.Values, body []byte) (*http.Response, error) {
+	uri := *s.base
+	segments := []string{}
+	if s.pathStyle {
+		segments = append(segments, s.config.Bucket)
+	}
+	if name != "" {
+		segments = append(segments, strings.Split(path.Join(s.config.Prefix, name), "/")...)
+	}
+	// the path is escaped by hand so what's sent matches what's signed
+	uri.Path = "/" + strings.Join(segments, "/")
+	uri.RawPath = "/" + strings.Join(lo.Map(segments, func(segment string, index int) string {
+		return awsEscape(segment)
+	}), "/")
+	uri.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, uri.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	apiErr := s3Error{}
+	xml.NewDecoder(resp.Body).Decode(&apiErr)
+	err = fmt.Errorf("s3 %s %s failed with %d %s: %s", method, uri.Path, resp.StatusCode, apiErr.Code, apiErr.Message)
+	if resp.StatusCode == http.StatusNotFound {
+		err = errors.Join(err, ErrNotFound)
+	}
+	return nil, err
+}
+
+/*
+sign
+
+Adds an AWS signature version 4 Authorization header to the request,
+see https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+*/
+func (s *S3) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.Format(amzDateLayout)
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	if s.config.SessionToken != "" {
+		req.Header.Set("x-amz-security-token", s.config.SessionToken)
+	}
+
+	headers := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if s.config.SessionToken != "" {
+		headers = append(headers, "x-amz-security-token")
+	}
+	canonicalHeaders := ""
+	for _, header := range headers {
+		value := req.Header.Get(header)
+		if header == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders += header + ":" + strings.TrimSpace(value) + "\n"
+	}
+	signedHeaders := strings.Join(headers, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{now.Format("20060102"), s.config.Region, s3Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), now.Format("20060102"))
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, s3Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query parameters sorted by key the way sigv4 expects
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range query[key] {
+			pairs = append(pairs, awsEscape(key)+"="+awsEscape(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape percent encodes everything but unreserved characters, spaces become %20 rather than +
+func awsEscape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,15 @@
+package storage
+
+import "context"
+
+// Reader lists and reads the files of an export held somewhere other than a local directory
+type Reader interface {
+	List(ctx context.Context) ([]string, error)
+	Get(ctx context.Context, name string) ([]byte, error)
+}
+
+// Writer stores the files of an export as they're downloaded, it must be safe for use from workers
+type Writer interface {
+	Put(ctx context.Context, name string, data []byte) error
+	Close() error
+}
@@ -2,6 +2,7 @@
 // sources:
 // templates/doc_index.md.gotmpl (107B)
 // templates/doc_page.md.gotmpl (519B)
+// templates/gallery.html.gotmpl (4.569kB)
 // templates/header.md.gotmpl (66B)
 // templates/ranks.md.gotmpl (157B)
 
@@ -111,6 +112,26 @@ func templatesDoc_pageMdGotmpl() (*asset, error) {
 	return a, nil
 }
 
+var _templatesGalleryHtmlGotmpl = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xa4\x58\x6d\x8f\xdb\xb8\xf1\x7f\xaf\x4f\x31\x51\x82\xc0\x0b\xac\x64\xef\xe2\x36\x77\x7f\x59\xd2\x1f\x41\x9a\x20\x87\xa6\x87\xf4\xb2\xd7\x02\x7d\x47\x8b\x23\x9b\x17\x8a\xd4\x91\x94\xd7\x8e\xc0\xef\x5e\x0c\x25\xf9\x61\xd7\x9b\x6b\xd1\x37\x5e\x71\x38\xf3\xfb\xcd\x13\x87\x4c\xfa\x3e\x01\x8e\xb5\x50\x08\xf1\x06\x19\x8f\x21\xf1\x3e\xca\x5f\x70\x5d\xb9\x7d\x8b\xb0\x71\x8d\x2c\xa3\x9c\xfe\x80\x64\x6a\x5d\xc4\xa8\x62\x12\x20\xe3\x65\x94\x37\xe8\x18\x54\x1b\x66\x2c\xba\x22\xee\x5c\x9d\xfc\x14\x4f\x62\xc5\x1a\x2c\xe2\xad\xc0\x87\x56\x1b\x17\x43\xa5\x95\x43\xe5\x8a\xf8\x41\x70\xb7\x29\x38\x6e\x45\x85\x49\x58\x5c\x83\x50\xc2\x09\x26\x13\x5b\x31\x89\xc5\x0d\x81\x38\xe1\x24\x96\x7d\x9f\xde\xd3\x87\xf7\xf9\x7c\x90\x44\xb9\x75\x7b\x89\x65\xb4\xd2\x7c\x0f\x3d\xd4\x5a\xb9\xa4\x66\x8d\x90\xfb\x0c\xec\xde\x3a\x6c\x92\x4e\x5c\x83\x65\xca\x26\x16\x8d\xa8\x97\xd0\x30\xb3\x16\x2a\x83\x05\xb0\xce\x69\x5a\xef\x06\xe6\x0c\x7e\xbc\x35\xd8\x2c\xa1\x65\x9c\x0b\xb5\xce\xe0\x26\x2c\x2b\x2d\xb5\xc9\xe0\xe5\xed\xed\xed\x12\x7c\xc4\xa0\x3f\x88\xee\x56\xb7\xfc\xff\x16\x4b\x70\xb8\x73\x09\xc7\x4a\x1b\xe6\x84\x56\x19\x28\xad\x90\x94\x6b\x6d\x9a\xb4\x16\xd2\xa1\xb1\xd0\x03\x17\xb6\x95\x6c\x9f\x41\x2d\x71\xb7\x0c\xbf\xc9\x83\x61\x6d\x06\xf4\xbb\x84\x35\x7d\xa6\x77\x81\x96\x49\xb1\x56\x89\x70\xd8\xd8\x0c\x50\xf1\xc9\xf3\x64\xa5\x9d\xd3\xcd\xe4\xdd\x23\x0e\xc9\x56\x28\x9f\x61\xe2\xc2\x60\x35\xf8\x57\x69\xd9\x35\x6a\x39\x24\xcc\x8a\x6f\x98\x41\xfa\xd3\x88\xd7\xc9\x74\xcd\xa4\x44\xb3\x3f\xc5\x59\x1b\xc1\x97\xe1\x37\x71\xd8\xb4\x92\x39\x4c\x06\x14\x9b\x81\xc1\x16\x99\x9b\x51\x42\x93\x5a\x48\x79\x0d\x8d\x50\x0d\xdb\xcd\x08\xf3\x1a\x6e\x6a\x73\x75\x75\x1e\x9d\x14\xd6\x25\xa1\x78\x53\xb2\x0e\x59\x5f\x3c\x72\x42\x0a\xe8\x61\xa5\x0d\x47\x93\xc1\x4d\xbb\x03\xab\xa5\xe0\xf0\x92\x73\xbe\x1c\xe5\x89\x61\x5c\x74\x36\x83\xf4\xf6\xee\xbc\x84\x23\x5d\x28\x50\xc8\x68\x06\x15\x2a\x87\x66\x09\x7a\x8b\xa6\x96\xfa\x61\xac\x00\x53\xfb\x87\x0d\x1a\x7c\xc4\x2e\x9a\x35\xf4\x30\x36\xc8\x9b\x1f\xda\xdd\x12\x36\x28\xd6\x1b\x37\xad\xf4\xea\x77\xac\x5c\x52\x0b\x47\x69\x55\x8e\x09\x45\x10\xa9\x63\x6b\xe8\xcf\x12\xfc\x63\x70\x65\xc5\xaa\xaf\x6b\xa3\x3b\xc5\x33\x78\x89\x88\x7f\x1e\xc3\xe2\x20\xf2\x51\x5a\xe9\x96\xea\x52\x75\xc6\x52\x57\xb6\x5a\x0c\xd1\x9c\xb5\x7e\xa3\x95\xb6\x2d\xab\x0e\xe0\x53\x92\x4f\xc9\x07\xc9\xd8\xca\x42\x6d\xd0\x08\x77\xca\x7a\xa0\xcb\x36\x94\x2a\xe8\x9f\xb6\x79\xa7\x38\x1a\x29\x08\xc7\x47\x5c\xfe\xc7\xfd\x42\x67\x6e\x9c\x01\xe3\x29\x1c\x7a\x23\x84\x79\x68\x6c\xee\xa6\x04\x3e\x8c\x19\x5f\x69\xc9\x89\x4a\xb1\x6d\xda\xb2\x35\x5e\x38\x53\x01\x68\x42\xc8\xe7\xe3\x7c\xc8\xe7\xe3\x94\xa2\x41\x51\x46\x34\xea\x50\xf1\x30\xe0\xa2\xd3\xb9\x57\x6b\xed\xc6\xb9\x67\x2b\x23\x5a\x57\x46\x5c\x57\x5d\x83\xca\xa5\x8c\xf3\xf7\x5b\x54\xee\x93\xb0\x0e\x15\x9a\x59\x5c\x49\x51\x7d\x8d\xaf\xa1\xee\x54\x38\x57\x30\x43\x52\xb8\x82\x3e\x02\xd8\x32\x03\xab\xce\x39\xad\xa0\x80\x20\x4f\x1d\x33\x6b\x74\x69\x25\xb5\x45\xeb\x66\xf1\xb0\x1d\x72\x1c\x5f\x2d\x23\x00\x51\xc3\xec\xc5\x20\xbd\x82\x1e\x0c\xba\xce\x84\x6e\x1a\xe0\x28\xff\x50\x8c\xa8\x29\x67\x8e\x59\x82\xd3\xed\x7e\x39\x12\x72\xad\x10\x8a\x13\x87\x06\x5f\x86\xcd\x61\x36\x1c\xec\x09\xed\xdd\x50\x04\x32\x87\x0b\x72\x28\x20\xae\x74\x2b\x90\xbf\x88\x07\x1d\x8b\xee\x5e\x34\xa8\x3b\x37\x3b\x23\xb9\x6c\x1c\x18\x97\xe0\xaf\xe1\x66\xb1\x58\x84\x10\xfd\x14\xa7\x62\x5b\xb1\x66\x4e\x9b\xb4\x92\xa2\x5d\x69\x66\xf8\xe4\xec\x85\xad\xf4\xc1\x08\x87\xf7\xb8\x73\x33\xa2\xb8\x4a\xdd\x06\xd5\x8c\xc2\x0d\xa8\x30\xe5\x8a\x18\xc6\x5c\x30\x83\x0c\x0a\x38\xd4\xaf\x32\xc8\x1c\xbe\x97\x48\xab\x59\x4c\x30\xa4\x32\x64\x9e\xbe\xd2\x2d\x93\x1d\xa5\x8f\xb6\x48\x78\x30\xa5\xae\x49\x59\xdb\xa2\xe2\xef\x36\x42\xf2\x19\xa9\x1f\xed\x2c\x4a\xac\xdc\xec\xea\xcc\x06\x77\x58\xbd\xd3\x4d\xc3\x14\x9f\xc5\xc7\x12\x93\x65\x6a\xb0\xd1\x5b\x9c\x0c\x54\xf8\xf2\x57\xcb\x28\x9f\x4f\x5d\x97\xcf\x89\x93\xfe\xd2\x7d\xfb\x9d\x8e\x0d\xc8\x43\xc7\x8e\xdd\x56\x49\x66\x6d\x31\x50\x02\xdd\xdc\xc5\xd8\x68\x31\x50\xcb\x24\xb4\x51\xc4\x59\xdf\xa7\xde\x67\x31\x84\x9b\x74\x50\x07\xa7\xe1\x90\xf1\xb8\x1c\x55\xf2\xf9\x60\xfe\x1d\x27\x84\xe2\xb8\x1b\xce\x4d\xdf\x4f\xc7\x7d\x7a\x45\xa4\xe4\xdb\xe6\x86\xae\xef\x5f\x58\x83\x61\x2e\xd1\x15\xbe\xb9\x29\xa3\x9c\x2e\xaf\xc9\xe3\xf1\x0e\x8b\xa1\x41\xb7\xd1\xbc\x88\xd7\xe8\x62\x60\xa1\x93\x8b\x78\x4e\x0f\x81\xd0\x50\x25\xc1\x40\x2e\x54\xdb\xb9\x31\x40\x8b\xcc\x54\x9b\x78\x7c\x68\xfc\x11\x43\x28\x65\x11\xf7\x7d\xfa\x21\xa0\xa6\x7f\xef\xd0\xec\xbd\x8f\xcb\x7c\x3e\x80\x4c\x60\xbf\xb5\x52\x33\x8e\x26\xca\x87\x32\x8e\x18\xdd\x28\x26\x56\xdd\x92\x0b\x13\x66\x5c\x32\xb5\xd7\x0a\xf3\xf9\x20\x1f\xf2\x62\x98\x5a\x23\xa4\x13\x9a\xa5\xa0\x87\xfd\xbe\x17\x35\xe0\x1f\x90\xc2\xab\xc9\x99\x49\xcb\x7b\x18\x48\x91\xf7\x3d\x2a\xee\x3d\x65\xc9\xfb\x73\xe8\xb0\x41\xbd\x11\x54\xcb\xe8\x71\x04\x1f\x8c\x6e\xce\xd3\xc1\x99\xc3\x29\x19\xb5\xd1\xcd\x85\x7c\x90\xd1\x3f\x28\xa0\x4b\x39\xb9\xd7\xcf\xe3\x39\x7d\x01\xed\x5e\x3f\x8b\xf5\x57\xa1\xf8\xa3\xdc\x7e\x15\x8a\x5f\xcc\xab\x94\x17\x93\x4a\x10\xdf\x4f\x28\x69\xfc\xaf\xc9\x1c\x8f\xcf\xd8\x50\xdd\xaa\x11\x2e\x2e\x87\xf8\x8e\x47\x20\x67\xb0\x31\x58\x87\x76\xac\x24\x32\x93\xcf\x19\x81\x50\x1f\x97\x51\xde\x12\xe5\xbd\x76\x4c\x7a\x0f\xba\x86\xbe\x4f\xdf\xe9\x4e\x39\xef\x01\x1b\xfd\xbb\xc8\xe7\x6d\x19\xe5\x9d\x9c\x5a\x7e\x7c\x65\xc4\x67\xe1\xbe\x27\x4d\x0a\x57\x8a\x53\xc2\x00\x30\x1f\x4f\x51\xa8\x1a\xbd\x4d\xac\xa9\x8a\x78\x2e\x1a\xba\x0d\x69\xf3\x33\x73\x1b\xef\x63\x60\xd2\x15\xf1\x51\x19\xa8\xe1\x04\x3d\xda\x25\xfb\xb6\xa7\x8a\xb3\x32\x5f\x99\xf2\xec\xbc\xd2\x0c\x88\x61\x34\x09\x1e\x89\x1a\xd2\x9f\xed\x5b\x29\x98\xf5\x1e\x72\xdb\xb2\xc3\x7c\x71\x6c\x4d\x15\x13\xcc\xe6\x73\x92\x97\x7d\x8f\xd2\x22\xdd\x61\xe9\x5b\x25\x1a\xe6\x90\x5f\xb6\x19\x37\x8f\x66\x63\x49\xa4\x38\x2f\x51\x47\x55\x51\x6c\x3b\x59\x87\x1b\x7f\x4c\x15\xb1\x7c\x36\xb8\xfd\xcc\xd6\xe8\xfd\x21\x49\x7d\x7f\x22\x8d\xcb\xd7\x92\x19\xb3\x84\xd6\xe0\x56\xe8\xce\x52\xd0\x13\xdd\x04\xf2\x0b\xee\xdc\x53\x90\xa3\x34\x2e\x15\xdd\xba\xaf\x0d\x21\x9d\x02\xe4\x73\xc5\xb6\xe7\xf9\x1b\x5e\x0f\xa9\xf7\xcf\x4f\xca\x50\xc4\xef\x4e\xca\xb6\x3c\x38\x32\x3f\x44\xf0\x78\x76\xb2\x32\x74\x12\xd1\x3c\x08\xb7\x39\xe9\x99\x30\x68\x9f\x29\xe9\x38\x73\xff\x8b\xb6\x29\xa3\x9c\xcb\xf2\x69\x2b\x44\x39\x77\x65\xf8\x86\x5a\x9b\x7c\xce\x5d\x99\x73\x5e\x5e\x68\xd6\xa0\xf4\x41\x1b\x02\x3b\x5b\x0e\x41\x70\x7e\x5a\xf3\x89\x27\x18\xe1\x19\x0f\xda\x03\x4b\xdf\x0f\x07\xe5\x95\xb8\x86\x57\xa1\x03\x21\x2b\x4e\x8c\xc2\xc0\x7d\x25\xbc\xbf\x86\xb1\x58\x4f\xfd\x1a\xec\x06\xa7\xa6\xef\x93\xea\x3e\xe7\xd8\x97\xbd\xd2\x6a\xdf\x4c\x9e\x4d\xcb\xcb\xae\xd9\x61\x37\x38\x77\x34\x7c\xea\x5d\xdf\x4f\xaa\xde\x3f\xc3\x4f\x64\xd3\xa5\x71\x42\x46\x2e\x1d\xef\x92\x63\x94\xff\x3f\xdd\x5e\x45\xdf\x9f\x28\xc4\xe5\xd9\x72\x8c\x57\x5a\xf4\xbe\x53\x5f\x95\x7e\x50\x67\xf4\x44\xfa\x2e\x3c\x9a\xf8\x23\xce\x51\x9a\xfe\x6c\xff\x85\x46\x9f\x5a\x07\xb0\xbe\x3f\x68\x7c\xd0\xa6\x61\x0e\xe2\xdb\xc5\xe2\x4d\xb2\xb8\x49\x16\xb7\x70\x73\x97\x2d\x7e\xc8\x16\x77\xf0\xb7\x2f\xf7\xf1\xa3\x88\x89\xf2\x83\x90\x78\xc2\x37\xb6\xe6\x31\x23\x14\xf4\x00\x3b\x26\x66\x58\x9c\x9a\x4c\xdb\x7d\x7f\x3e\x8d\xae\x61\x1a\x3e\x30\xa3\xa7\x81\xa1\x33\xe5\x3d\xd4\xe1\xe3\xea\x89\x2b\x7f\x11\x0d\x2a\x2b\xb4\x3a\xad\x70\xfa\x4f\xc1\xe9\xb0\xbc\x76\xa2\x41\xbb\xec\xfb\xf4\x63\xf8\xe7\xc9\xe5\xaa\x7d\x11\xdf\xce\xa2\xa1\xb5\xf7\xb0\xda\x3b\xb4\x47\x7d\x72\xf3\xcb\xc7\xb7\xb7\x77\x6f\x26\xb3\x8f\x6f\x93\xdb\xbb\x37\x07\xcb\xbc\xd2\x3c\xfc\x0f\xc8\xa4\x95\xcf\x83\xe4\x31\xe5\x04\xf6\xdb\xaf\x9f\x26\x24\xdd\x99\xea\xe8\xc2\xa1\x49\xa8\x15\x48\x69\x68\x8a\x5f\x3f\x5d\x3e\x91\xf9\x9c\xcb\x73\xfc\x3f\x1b\x77\xff\x1e\x00\x24\x54\x90\x3d\x47\x12\x00\x00")
+
+func templatesGalleryHtmlGotmplBytes() ([]byte, error) {
+	return bindataRead(
+		_templatesGalleryHtmlGotmpl,
+		"templates/gallery.html.gotmpl",
+	)
+}
+
+func templatesGalleryHtmlGotmpl() (*asset, error) {
+	bytes, err := templatesGalleryHtmlGotmplBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "templates/gallery.html.gotmpl", size: 4679, mode: os.FileMode(0644), modTime: time.Unix(1771972135, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xbb, 0xba, 0x27, 0x79, 0x8f, 0xaf, 0x8f, 0x46, 0xd3, 0xa8, 0xf3, 0x90, 0xca, 0xe9, 0x87, 0xc2, 0x31, 0x66, 0x4f, 0xd0, 0xfb, 0x17, 0x79, 0x33, 0xdb, 0x84, 0x54, 0xc7, 0x63, 0x49, 0xae, 0x77}}
+	return a, nil
+}
+
 var _templatesHeaderMdGotmpl = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x52\x56\x56\xb0\x2a\x4e\xaa\xd4\x4d\xd4\xcd\x4b\x2d\xd7\x4d\xcd\xcd\xcf\xca\xb4\x52\x70\x05\x51\x0a\x41\xa9\x39\xa9\x89\xc5\xa9\x0a\x7e\xf9\x25\xa9\xc5\x0a\xd5\xd5\x0a\x7a\xc5\x25\x89\x45\x25\x0a\xb5\xb5\x0a\xba\x60\x6e\x6a\x5e\x8a\x42\x6d\x2d\x20\x00\x00\xff\xff\xd7\x92\xc7\x90\x42\x00\x00\x00")
 
 func templatesHeaderMdGotmplBytes() ([]byte, error) {
@@ -244,6 +265,7 @@ func AssetNames() []string {
 var _bindata = map[string]func() (*asset, error){
 	"templates/doc_index.md.gotmpl": templatesDoc_indexMdGotmpl,
 	"templates/doc_page.md.gotmpl":  templatesDoc_pageMdGotmpl,
+	"templates/gallery.html.gotmpl": templatesGalleryHtmlGotmpl,
 	"templates/header.md.gotmpl":    templatesHeaderMdGotmpl,
 	"templates/ranks.md.gotmpl":     templatesRanksMdGotmpl,
 }
@@ -297,6 +319,7 @@ var _bintree = &bintree{nil, map[string]*bintree{
 	"templates": {nil, map[string]*bintree{
 		"doc_index.md.gotmpl": {templatesDoc_indexMdGotmpl, map[string]*bintree{}},
 		"doc_page.md.gotmpl":  {templatesDoc_pageMdGotmpl, map[string]*bintree{}},
+		"gallery.html.gotmpl": {templatesGalleryHtmlGotmpl, map[string]*bintree{}},
 		"header.md.gotmpl":    {templatesHeaderMdGotmpl, map[string]*bintree{}},
 		"ranks.md.gotmpl":     {templatesRanksMdGotmpl, map[string]*bintree{}},
 	}},
@@ -0,0 +1,221 @@
+/*
+Package zulip talks to a Zulip organization's custom emoji REST API with a
+bot or user's api key, so emoji can be exported from and imported into
+Zulip like a Slack team.
+*/
+package zulip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/erindatkinson/emoji-archiver/internal/httpretry"
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+)
+
+const apiPath = "/api/v1"
+
+var _ platform.Backend = (*Client)(nil)
+
+type Client struct {
+	platform.HTTP
+	Email  string
+	APIKey string
+	// UserID is the api key's user, emoji are uploaded as them
+	UserID int64
+}
+
+/*
+NewClient
+
+Creates a client for the Zulip organization at serverURL with the email
+and api key of a bot or user, checking them by looking the user up
+*/
+func NewClient(ctx context.Context, serverURL, email, apiKey string, opts ...platform.Option) (*Client, error) {
+	if serverURL == "" {
+		return nil, errors.New("a zulip server url is required")
+	}
+	if email == "" || apiKey == "" {
+		return nil, errors.Join(errors.New("a zulip email and api key are required"), slack.ErrAuth)
+	}
+	client := &Client{Email: email, APIKey: apiKey}
+	client.HTTP = platform.HTTP{
+		URL:        strings.TrimSuffix(serverURL, "/"),
+		APIPath:    apiPath,
+		HTTPClient: http.DefaultClient,
+		Logger:     utilities.ContextLogger(ctx),
+		Retry:      newRetrier(),
+		Authorize:  client.authorize,
+		Upload:     client.UploadEmoji,
+	}
+	for _, opt := range opts {
+		opt(&client.HTTP)
+	}
+
+	me := user{}
+	if err := client.GetJSON(ctx, "/users/me", &me); err != nil {
+		return nil, err
+	}
+	client.UserID = me.UserID
+	return client, nil
+}
+
+/*
+ListEmoji
+
+Lists the organization's active custom emoji, sorted by name, and looks up
+who uploaded them. Zulip has no aliases and doesn't record when an emoji
+was added, so none are returned and Created is always zero.
+*/
+func (c *Client) ListEmoji(ctx context.Context) ([]slack.Emoji, error) {
+	listed, err := c.list(ctx)
+	if err != nil {
+		return []slack.Emoji{}, err
+	}
+	names, err := c.fullNames(ctx)
+	if err != nil {
+		return []slack.Emoji{}, err
+	}
+	result := make([]slack.Emoji, 0, len(listed))
+	for _, e := range listed {
+		converted := slack.Emoji{
+			Name: e.Name,
+			URL:  c.imageURL(e),
+		}
+		if e.AuthorID != 0 {
+			converted.UserID = strconv.FormatInt(e.AuthorID, 10)
+			converted.UserDisplayName = names[e.AuthorID]
+		}
+		result = append(result, converted)
+	}
+	return result, nil
+}
+
+// UploadEmoji creates a new emoji from an image held in memory
+func (c *Client) UploadEmoji(ctx context.Context, name, filename string, image []byte) error {
+	c.Logger.Debug("importing emoji", "name", name)
+	path := "/realm/emoji/" + url.PathEscape(name)
+	resp, err := c.Do(ctx, path, func() (*http.Request, error) {
+		body := new(bytes.Buffer)
+		wrapper := multipart.NewWriter(body)
+		part, err := wrapper.CreateFormFile("file", filename)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(image); err != nil {
+			return nil, err
+		}
+		if err := wrapper.Close(); err != nil {
+			return nil, err
+		}
+		req, err := c.NewRequest(ctx, http.MethodPost, path, body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", wrapper.FormDataContentType())
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+/*
+AddAlias
+
+Zulip has no aliases, so the alias is created as a copy of the target's
+image under the new name
+*/
+func (c *Client) AddAlias(ctx context.Context, name, target string) error {
+	c.Logger.Debug("adding alias as a copy", "name", name, "alias_for", target)
+	listed, err := c.list(ctx)
+	if err != nil {
+		return err
+	}
+	index := slices.IndexFunc(listed, func(e emoji) bool {
+		return e.Name == target
+	})
+	if index < 0 {
+		return fmt.Errorf("unable to find %s to copy for alias %s", target, name)
+	}
+	data, filename, err := c.Download(ctx, slack.Emoji{Name: target, URL: c.imageURL(listed[index])})
+	if err != nil {
+		return err
+	}
+	return c.UploadEmoji(ctx, name, filename, data)
+}
+
+//========== Private Methods ==========
+
+// list returns the active realm emoji sorted by name
+func (c *Client) list(ctx context.Context) ([]emoji, error) {
+	body := struct {
+		Emoji map[string]emoji `json:"emoji"`
+	}{}
+	if err := c.GetJSON(ctx, "/realm/emoji", &body); err != nil {
+		return nil, err
+	}
+	listed := make([]emoji, 0, len(body.Emoji))
+	for _, e := range body.Emoji {
+		if !e.Deactivated {
+			listed = append(listed, e)
+		}
+	}
+	slices.SortFunc(listed, func(a, b emoji) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return listed, nil
+}
+
+// fullNames maps the organization's user ids to their full names
+func (c *Client) fullNames(ctx context.Context) (map[int64]string, error) {
+	body := struct {
+		Members []user `json:"members"`
+	}{}
+	if err := c.GetJSON(ctx, "/users", &body); err != nil {
+		return nil, err
+	}
+	names := make(map[int64]string, len(body.Members))
+	for _, member := range body.Members {
+		names[member.UserID] = member.FullName
+	}
+	return names, nil
+}
+
+// imageURL makes an emoji's source url absolute, zulip gives them relative to the server
+func (c *Client) imageURL(e emoji) string {
+	source, err := url.Parse(e.SourceURL)
+	if err != nil || source.IsAbs() {
+		return e.SourceURL
+	}
+	base, err := url.Parse(c.URL + "/")
+	if err != nil {
+		return e.SourceURL
+	}
+	return base.ResolveReference(source).String()
+}
+
+// authorize sends the email and api key with basic auth
+func (c *Client) authorize(req *http.Request) {
+	req.SetBasicAuth(c.Email, c.APIKey)
+}
+
+// newRetrier retries requests the way zulip asks
+func newRetrier() httpretry.Retrier {
+	retry := httpretry.New("zulip")
+	retry.RetryAfter = retryAfter
+	retry.Error = decodeError
+	return retry
+}
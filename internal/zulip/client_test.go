@@ -0,0 +1,127 @@
+package zulip_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/apitest"
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+	"github.com/erindatkinson/emoji-archiver/internal/utilities"
+	"github.com/erindatkinson/emoji-archiver/internal/zulip"
+	"github.com/erindatkinson/emoji-archiver/internal/zulip/zuliptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/neko"
+)
+
+func helpNewClient(t *testing.T, opts ...platform.Option) (*zulip.Client, *zuliptest.Server) {
+	server := zuliptest.NewServer()
+	return apitest.NewClient(t, server, opts...), server
+}
+
+func TestClient(t *testing.T) {
+	tests := neko.Modern(t)
+
+	tests.It("looks up the api key's user", func(t *testing.T) {
+		client, _ := helpNewClient(t)
+		assert.Equal(t, int64(zuliptest.UserID), client.UserID)
+	})
+
+	tests.It("rejects a bad api key", func(t *testing.T) {
+		server := zuliptest.NewServer()
+		defer server.Close()
+		ctx := utilities.ToContext(t.Context(), utilities.NewLogger("error"))
+		_, err := zulip.NewClient(ctx, server.URL, zuliptest.Email, "nope", server.ClientOptions()...)
+		assert.ErrorIs(t, err, slack.ErrAuth)
+	})
+
+	tests.It("lists active emoji by name with their authors", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.AddUser(20, "Erin")
+		server.AddEmoji("zebra", 20, apitest.PNG(t))
+		server.AddEmoji("apple", 0, apitest.PNG(t))
+		server.AddEmoji("gone", 0, apitest.PNG(t))
+		server.Deactivate("gone")
+
+		emoji, err := client.ListEmoji(t.Context())
+		require.Nil(t, err)
+		require.Len(t, emoji, 2)
+		assert.Equal(t, "apple", emoji[0].Name)
+		assert.Equal(t, zuliptest.FullName, emoji[0].UserDisplayName)
+		assert.Equal(t, "20", emoji[1].UserID)
+		assert.Equal(t, "Erin", emoji[1].UserDisplayName)
+		assert.Equal(t, server.URL+"/user_avatars/2/emoji/images/1.png", emoji[1].URL)
+	})
+
+	tests.It("exports images with the extension of their source", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.AddEmoji("moving", 0, apitest.GIF(t))
+
+		emoji, err := client.ListEmoji(t.Context())
+		require.Nil(t, err)
+		dir := t.TempDir()
+		filename, err := client.ExportEmoji(t.Context(), emoji[0], dir)
+		require.Nil(t, err)
+		assert.Equal(t, "moving.gif", filename)
+		data, err := os.ReadFile(filepath.Join(dir, filename))
+		require.Nil(t, err)
+		assert.Equal(t, apitest.GIF(t), data)
+	})
+
+	tests.It("uploads emoji and copies the target's image for an alias", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		fPath := filepath.Join(t.TempDir(), "blob.png")
+		require.Nil(t, os.WriteFile(fPath, apitest.PNG(t), 0644))
+
+		require.Nil(t, client.ImportEmoji(t.Context(), "blob", fPath))
+		require.Nil(t, client.AddAlias(t.Context(), "blob-too", "blob"))
+		emoji := server.Emoji()
+		require.Len(t, emoji, 2)
+		assert.Equal(t, "blob-too", emoji[1].Name)
+		assert.Equal(t, apitest.PNG(t), emoji[1].Image)
+		assert.Equal(t, int64(zuliptest.UserID), emoji[1].AuthorID)
+
+		assert.NotNil(t, client.AddAlias(t.Context(), "missing-too", "missing"))
+	})
+
+	tests.It("maps error messages onto the slack error kinds", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.AddEmoji("blob", 0, apitest.PNG(t))
+
+		err := client.UploadEmoji(t.Context(), "blob", "blob.png", apitest.PNG(t))
+		assert.ErrorIs(t, err, slack.ErrNameTaken)
+		err = client.UploadEmoji(t.Context(), "Not!A!Name", "blob.png", apitest.PNG(t))
+		assert.ErrorIs(t, err, slack.ErrInvalidName)
+		err = client.UploadEmoji(t.Context(), "huge", "huge.png", append(apitest.PNG(t), make([]byte, zuliptest.MaxImageSize)...))
+		assert.ErrorIs(t, err, slack.ErrTooLarge)
+		err = client.UploadEmoji(t.Context(), "text", "text.png", []byte("not an image"))
+		assert.ErrorIs(t, err, slack.ErrBadImage)
+	})
+
+	tests.It("waits out a 429's Retry-After", func(t *testing.T) {
+		client, server := helpNewClient(t)
+		server.InjectFault(zuliptest.MethodListEmoji, zuliptest.Fault{Status: http.StatusTooManyRequests, Code: "RATE_LIMIT_HIT", RetryAfter: 0.2})
+		server.InjectFault(zuliptest.MethodListEmoji, zuliptest.Fault{Status: http.StatusBadGateway})
+
+		started := time.Now()
+		_, err := client.ListEmoji(t.Context())
+		require.Nil(t, err)
+		assert.GreaterOrEqual(t, time.Since(started), 200*time.Millisecond)
+		assert.Equal(t, 3, server.Requests(zuliptest.MethodListEmoji))
+	})
+
+	tests.It("gives up once retries run out", func(t *testing.T) {
+		client, server := helpNewClient(t, platform.WithRetries(1))
+		for range 2 {
+			server.InjectFault(zuliptest.MethodAddEmoji, zuliptest.Fault{Status: http.StatusTooManyRequests})
+		}
+		err := client.UploadEmoji(t.Context(), "blob", "blob.png", apitest.PNG(t))
+		assert.ErrorIs(t, err, slack.ErrRateLimited)
+	})
+
+	tests.Run()
+}
@@ -0,0 +1,69 @@
+package zulip
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
+	"github.com/erindatkinson/emoji-archiver/internal/slack"
+)
+
+// errorCodes maps Zulip's error codes onto the slack error kinds
+var errorCodes = map[string]error{
+	"UNAUTHORIZED":           slack.ErrAuth,
+	"INVALID_API_KEY":        slack.ErrAuth,
+	"USER_DEACTIVATED":       slack.ErrAuth,
+	"REALM_DEACTIVATED":      slack.ErrAuth,
+	"UNAUTHORIZED_PRINCIPAL": slack.ErrAuth,
+	"RATE_LIMIT_HIT":         slack.ErrRateLimited,
+}
+
+// errorMessages picks out kinds from the message, since most emoji errors are just BAD_REQUEST.
+// The first match wins.
+var errorMessages = []struct {
+	match string
+	kind  error
+}{
+	{"already exists", slack.ErrNameTaken},
+	{"emoji name", slack.ErrInvalidName},
+	{"Emoji names must", slack.ErrInvalidName},
+	{"larger than the allowed", slack.ErrTooLarge},
+	{"Invalid image format", slack.ErrBadImage},
+	{"must upload exactly one", slack.ErrBadImage},
+	{"Must be an organization", slack.ErrAuth},
+	{"Insufficient permission", slack.ErrAuth},
+}
+
+// errorKind picks the kind for a zulip error code and message, nil when neither is recognised
+func errorKind(code, message string) error {
+	if kind, ok := errorCodes[code]; ok {
+		return kind
+	}
+	for _, candidate := range errorMessages {
+		if strings.Contains(message, candidate.match) {
+			return candidate.kind
+		}
+	}
+	return nil
+}
+
+// retryAfter reads how long a 429 asks to wait from Retry-After or the retry-after in its body
+func retryAfter(resp *http.Response) time.Duration {
+	body := apiError{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if seconds, err := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64); err == nil {
+		body.RetryAfter = max(body.RetryAfter, seconds)
+	}
+	return time.Duration(math.Ceil(body.RetryAfter*1000)) * time.Millisecond
+}
+
+// decodeError reads the apiError zulip sends with an error status
+func decodeError(path string, resp *http.Response) error {
+	body := apiError{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	return platform.NewAPIError(resp, path, body.Code, body.Msg, errorKind(body.Code, body.Msg))
+}
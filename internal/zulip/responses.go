@@ -0,0 +1,24 @@
+package zulip
+
+// emoji is a realm emoji as the rest api describes it, Zulip doesn't record when it was added
+type emoji struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	SourceURL   string `json:"source_url"`
+	Deactivated bool   `json:"deactivated"`
+	AuthorID    int64  `json:"author_id"`
+}
+
+type user struct {
+	UserID   int64  `json:"user_id"`
+	FullName string `json:"full_name"`
+	Email    string `json:"email"`
+}
+
+// apiError is the body zulip sends with an error status
+type apiError struct {
+	Result     string  `json:"result"`
+	Msg        string  `json:"msg"`
+	Code       string  `json:"code"`
+	RetryAfter float64 `json:"retry-after"`
+}
@@ -0,0 +1,302 @@
+/*
+Package zuliptest runs an in memory stand-in for the custom emoji parts of
+Zulip's rest api, so the zulip client and commands can be tested without
+an organization.
+*/
+package zuliptest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/erindatkinson/emoji-archiver/internal/apitest"
+	"github.com/erindatkinson/emoji-archiver/internal/platform"
+	"github.com/erindatkinson/emoji-archiver/internal/zulip"
+)
+
+const (
+	// Email and APIKey are the credentials the server accepts
+	Email  = "emoji-bot@zuliptest.example.com"
+	APIKey = "zuliptest-api-key"
+	// UserID is the api key's user
+	UserID = 10
+	// FullName is the api key's user's name
+	FullName = "Emoji Bot"
+)
+
+// Endpoints that faults can be injected into
+const (
+	MethodMe        = "users/me"
+	MethodUsers     = "users"
+	MethodListEmoji = "realm/emoji"
+	MethodAddEmoji  = "realm/emoji/create"
+	MethodImage     = "image"
+)
+
+// MaxImageSize is the largest image the server accepts, zulip's default
+const MaxImageSize = 5 * 1024 * 1024
+
+var validName = regexp.MustCompile(`^[0-9a-z.\-_ ]{1,60}$`)
+
+// Fault replaces the next response from an endpoint with an error status
+// and, if Code is set, an error body. RetryAfter sets the Retry-After
+// header in seconds.
+type Fault struct {
+	Status     int
+	Code       string
+	RetryAfter float64
+}
+
+// Emoji is an emoji stored on the server
+type Emoji struct {
+	ID          int
+	Name        string
+	AuthorID    int64
+	Deactivated bool
+	Image       []byte
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	emoji []Emoji
+	users map[int64]string
+	apitest.Faults[Fault]
+	nextID int
+}
+
+// NewServer starts a server, close it when done
+func NewServer() *Server {
+	s := &Server{
+		Faults: apitest.Faults[Fault]{Write: writeFault},
+		users:  map[int64]string{UserID: FullName},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/users/me", s.handleMe)
+	mux.HandleFunc("GET /api/v1/users", s.handleUsers)
+	mux.HandleFunc("GET /api/v1/realm/emoji", s.handleListEmoji)
+	mux.HandleFunc("POST /api/v1/realm/emoji/{name}", s.handleAddEmoji)
+	mux.HandleFunc("GET /user_avatars/2/emoji/images/{file}", s.handleImage)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// ClientOptions points a zulip client at the server
+func (s *Server) ClientOptions() []platform.Option {
+	return []platform.Option{
+		platform.WithHTTPClient(s.Client()),
+	}
+}
+
+// Connect creates a zulip client with the server's email and api key
+func (s *Server) Connect(ctx context.Context, opts ...platform.Option) (*zulip.Client, error) {
+	return zulip.NewClient(ctx, s.URL, Email, APIKey, append(s.ClientOptions(), opts...)...)
+}
+
+// AddUser seeds the server with a user who can be an emoji's author
+func (s *Server) AddUser(id int64, fullName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[id] = fullName
+}
+
+// AddEmoji seeds the server with an emoji, authored by the api key's user if authorID is zero
+func (s *Server) AddEmoji(name string, authorID int64, image []byte) Emoji {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if authorID == 0 {
+		authorID = UserID
+	}
+	return s.addEmoji(name, authorID, image)
+}
+
+// Deactivate marks an emoji as deleted, zulip keeps listing deactivated emoji
+func (s *Server) Deactivate(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index := s.find(name); index >= 0 {
+		s.emoji[index].Deactivated = true
+	}
+}
+
+// Emoji returns the server's emoji
+func (s *Server) Emoji() []Emoji {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.emoji)
+}
+
+//========== Handlers ==========
+
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodMe) || !s.authorized(w, r) {
+		return
+	}
+	apitest.WriteJSON(w, http.StatusOK, map[string]any{"result": "success", "msg": "", "user_id": UserID, "email": Email, "full_name": FullName})
+}
+
+func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodUsers) || !s.authorized(w, r) {
+		return
+	}
+	s.mu.Lock()
+	members := make([]map[string]any, 0, len(s.users))
+	for id, fullName := range s.users {
+		members = append(members, map[string]any{"user_id": id, "full_name": fullName})
+	}
+	s.mu.Unlock()
+	apitest.WriteJSON(w, http.StatusOK, map[string]any{"result": "success", "msg": "", "members": members})
+}
+
+func (s *Server) handleListEmoji(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodListEmoji) || !s.authorized(w, r) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	emoji := make(map[string]any, len(s.emoji))
+	for _, e := range s.emoji {
+		id := strconv.Itoa(e.ID)
+		emoji[id] = map[string]any{
+			"id":          id,
+			"name":        e.Name,
+			"source_url":  fmt.Sprintf("/user_avatars/2/emoji/images/%d%s", e.ID, extension(e.Image)),
+			"deactivated": e.Deactivated,
+			"author_id":   e.AuthorID,
+		}
+	}
+	apitest.WriteJSON(w, http.StatusOK, map[string]any{"result": "success", "msg": "", "emoji": emoji})
+}
+
+func (s *Server) handleAddEmoji(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodAddEmoji) || !s.authorized(w, r) {
+		return
+	}
+	name := r.PathValue("name")
+	if !validName.MatchString(name) {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "Invalid characters in emoji name")
+		return
+	}
+	if err := r.ParseMultipartForm(MaxImageSize * 2); err != nil || r.MultipartForm == nil || len(r.MultipartForm.File) != 1 {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "You must upload exactly one file.")
+		return
+	}
+	var image []byte
+	for _, headers := range r.MultipartForm.File {
+		fp, err := headers[0].Open()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "You must upload exactly one file.")
+			return
+		}
+		image, err = io.ReadAll(fp)
+		fp.Close()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "You must upload exactly one file.")
+			return
+		}
+	}
+	if len(image) > MaxImageSize {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "Uploaded file is larger than the allowed limit of 5 MiB")
+		return
+	}
+	if !strings.HasPrefix(http.DetectContentType(image), "image/") {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "Invalid image format")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.find(name) >= 0 {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "A custom emoji with this name already exists.")
+		return
+	}
+	s.addEmoji(name, UserID, image)
+	apitest.WriteJSON(w, http.StatusOK, map[string]any{"result": "success", "msg": ""})
+}
+
+func (s *Server) handleImage(w http.ResponseWriter, r *http.Request) {
+	if s.Faulted(w, MethodImage) {
+		return
+	}
+	id, _, _ := strings.Cut(r.PathValue("file"), ".")
+	s.mu.Lock()
+	index := slices.IndexFunc(s.emoji, func(e Emoji) bool {
+		return strconv.Itoa(e.ID) == id
+	})
+	var data []byte
+	if index >= 0 {
+		data = s.emoji[index].Image
+	}
+	s.mu.Unlock()
+	if index < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	apitest.WriteImage(w, data)
+}
+
+//========== Helpers ==========
+
+// writeFault sends a fault the way zulip reports errors
+func writeFault(w http.ResponseWriter, fault Fault) {
+	if fault.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.FormatFloat(fault.RetryAfter, 'f', -1, 64))
+	}
+	writeError(w, fault.Status, fault.Code, http.StatusText(fault.Status))
+}
+
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if email, key, ok := r.BasicAuth(); !ok || email != Email || key != APIKey {
+		writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Invalid API key")
+		return false
+	}
+	return true
+}
+
+// addEmoji expects the lock to be held
+func (s *Server) addEmoji(name string, authorID int64, image []byte) Emoji {
+	s.nextID++
+	stored := Emoji{
+		ID:       s.nextID,
+		Name:     name,
+		AuthorID: authorID,
+		Image:    image,
+	}
+	s.emoji = append(s.emoji, stored)
+	return stored
+}
+
+// find returns the active emoji called name, it expects the lock to be held
+func (s *Server) find(name string) int {
+	return slices.IndexFunc(s.emoji, func(e Emoji) bool {
+		return e.Name == name && !e.Deactivated
+	})
+}
+
+func extension(image []byte) string {
+	switch http.DetectContentType(image) {
+	case "image/gif":
+		return ".gif"
+	case "image/jpeg":
+		return ".jpg"
+	}
+	return ".png"
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	body := map[string]any{"result": "error", "msg": message}
+	if code != "" {
+		body["code"] = code
+	}
+	apitest.WriteJSON(w, status, body)
+}